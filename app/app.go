@@ -0,0 +1,437 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"order-management/database"
+	"order-management/domain"
+	"order-management/entity"
+	authDelivery "order-management/features/auth/delivery"
+	authRepository "order-management/features/auth/repository"
+	authUsecase "order-management/features/auth/usecase"
+	categoryDelivery "order-management/features/category/delivery"
+	categoryRepository "order-management/features/category/repository"
+	categoryUsecase "order-management/features/category/usecase"
+	eventRepository "order-management/features/event/repository"
+	eventUsecase "order-management/features/event/usecase"
+	notificationRepository "order-management/features/notification/repository"
+	notificationUsecase "order-management/features/notification/usecase"
+	orderRepository "order-management/features/order/repository"
+	orderUsecase "order-management/features/order/usecase"
+	productDelivery "order-management/features/product/delivery"
+	productRepository "order-management/features/product/repository"
+	productUsecase "order-management/features/product/usecase"
+	shopDelivery "order-management/features/shop/delivery"
+	shopRepository "order-management/features/shop/repository"
+	shopUsecase "order-management/features/shop/usecase"
+	userDelivery "order-management/features/user/delivery"
+	userRepository "order-management/features/user/repository"
+	userUsecase "order-management/features/user/usecase"
+	webhookRepository "order-management/features/webhook/repository"
+	webhookUsecase "order-management/features/webhook/usecase"
+	"order-management/mail"
+	"order-management/middleware"
+	"order-management/ratelimit"
+	"order-management/storage"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	echoMiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type Repositories struct {
+	Auth         domain.LoginAttemptRepository
+	Category     domain.CategoryRepository
+	Event        domain.EventRepository
+	Notification domain.NotificationRepository
+	Order        domain.OrderRepository
+	Product      domain.ProductRepository
+	Setting      domain.SettingRepository
+	Shop         domain.ShopRepository
+	User         domain.UserRepository
+	Webhook      domain.WebhookRepository
+}
+
+type Usecases struct {
+	Auth         domain.LoginGuard
+	Category     domain.CategoryUsecase
+	Events       domain.EventBus
+	MFA          domain.MFAPolicy
+	Notification domain.NotificationUsecase
+	Order        domain.OrderUsecase
+	Product      domain.ProductUsecase
+	Shop         domain.ShopUsecase
+	User         domain.UserUsecase
+	Webhook      domain.WebhookUsecase
+}
+
+type Handlers struct {
+	Auth     *authDelivery.Handler
+	Category *categoryDelivery.Handler
+	Product  *productDelivery.Handler
+	Shop     *shopDelivery.Handler
+	User     *userDelivery.Handler
+}
+
+// Hook is a lifecycle callback. Start hooks run in registration order before
+// the server accepts connections, stop hooks run in reverse order after it
+// has drained.
+type Hook func(ctx context.Context) error
+
+// App owns every long-lived dependency of the API. It is built once from a
+// config and an open database, so tests can point it at a throwaway database
+// and drive App.Echo through httptest without listening on a port.
+type App struct {
+	Config    Config
+	DB        *gorm.DB
+	BlobStore domain.BlobStore
+	Mailer    domain.Mailer
+	// RateLimitStore is nil when rate limiting is off
+	RateLimitStore domain.RateLimitStore
+	Echo           *echo.Echo
+	Repositories   Repositories
+	Usecases       Usecases
+	Handlers       Handlers
+
+	startHooks []Hook
+	stopHooks  []Hook
+
+	jobs        []job
+	stopJobs    context.CancelFunc
+	jobsRunning sync.WaitGroup
+}
+
+func New(cfg Config, db *gorm.DB) (*App, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := utils.InitJWTKeys(); err != nil {
+		return nil, err
+	}
+
+	store, err := newBlobStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitStore, err := newRateLimitStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &App{
+		Config:         cfg,
+		DB:             db,
+		BlobStore:      store,
+		Mailer:         mailer,
+		RateLimitStore: rateLimitStore,
+	}
+
+	a.Repositories = Repositories{
+		Auth:         authRepository.NewLoginAttemptRepository(db),
+		Category:     categoryRepository.NewCategoryRepository(db),
+		Event:        eventRepository.NewEventRepository(db),
+		Notification: notificationRepository.NewNotificationRepository(db),
+		Order:        orderRepository.NewOrderRepository(db),
+		Product:      productRepository.NewProductRepository(db),
+		Setting:      authRepository.NewSettingRepository(db),
+		Shop:         shopRepository.NewShopRepository(db),
+		User:         userRepository.NewUserRepository(db),
+		Webhook:      webhookRepository.NewWebhookRepository(db),
+	}
+
+	// built first, the literal below cannot refer to its own fields
+	guard := authUsecase.NewLoginGuard(a.Repositories.Auth)
+	mfa := authUsecase.NewMFAPolicy(a.Repositories.Setting)
+	tx := database.NewTransactor(db)
+	events := eventUsecase.NewEventBus(a.Repositories.Event)
+
+	a.Usecases = Usecases{
+		Auth:         guard,
+		Category:     categoryUsecase.NewCategoryUsecase(a.Repositories.Category, a.Repositories.Product, a.BlobStore),
+		Events:       events,
+		MFA:          mfa,
+		Notification: notificationUsecase.NewNotificationUsecase(a.Repositories.Notification, a.Repositories.User, a.Mailer),
+		Order:        orderUsecase.NewOrderUsecase(a.Repositories.Order, a.Repositories.Product, tx, events),
+		Product:      productUsecase.NewProductUsecase(a.Repositories.Product, a.BlobStore),
+		Shop:         shopUsecase.NewShopUsecase(a.Repositories.Shop, a.Repositories.Product, a.BlobStore, guard, mfa, tx, events),
+		User:         userUsecase.NewUserUsecase(a.Repositories.User, a.Mailer, guard, []byte(cfg.ActionSecret)),
+		Webhook:      webhookUsecase.NewWebhookUsecase(a.Repositories.Webhook, a.Repositories.Order, a.Repositories.Product),
+	}
+
+	events.Subscribe("notifications", a.Usecases.Notification.HandleOrderStatusChanged, entity.EventOrderStatusChanged)
+	events.Subscribe("webhooks", a.Usecases.Webhook.HandleEvent, entity.EventOrderPlaced, entity.EventOrderStatusChanged, entity.EventProductUpdated)
+
+	a.Echo = a.newEcho()
+
+	a.Schedule("purge-deleted-products", cfg.ProductPurgeInterval, func(ctx context.Context) error {
+		purged, err := a.Usecases.Product.PurgeDeletedProducts(ctx)
+		if err != nil {
+			return err
+		}
+		log.WithField("purged", purged).Info("Purged deleted products")
+		return nil
+	})
+
+	a.Schedule("purge-login-attempts", cfg.LoginPurgeInterval, func(ctx context.Context) error {
+		purged, err := a.Usecases.Auth.PurgeLoginAttempts(ctx)
+		if err != nil {
+			return err
+		}
+		log.WithField("purged", purged).Debug("Purged expired login attempts")
+		return nil
+	})
+
+	// Subscribers register on a.Usecases.Events before Start
+	a.Schedule("dispatch-events", cfg.EventDispatchInterval, func(ctx context.Context) error {
+		delivered, err := a.Usecases.Events.Dispatch(ctx)
+		if delivered > 0 {
+			log.WithField("delivered", delivered).Debug("Delivered events to subscribers")
+		}
+		return err
+	})
+
+	a.Schedule("purge-events", cfg.EventPurgeInterval, func(ctx context.Context) error {
+		purged, err := a.Usecases.Events.PurgeEvents(ctx)
+		if err != nil {
+			return err
+		}
+		log.WithField("purged", purged).Info("Purged old events")
+		return nil
+	})
+
+	a.Schedule("dispatch-webhooks", cfg.WebhookDispatchInterval, func(ctx context.Context) error {
+		delivered, err := a.Usecases.Webhook.DeliverDue(ctx)
+		if delivered > 0 {
+			log.WithField("delivered", delivered).Debug("Sent webhook deliveries")
+		}
+		return err
+	})
+
+	a.Schedule("purge-webhook-events", cfg.WebhookPurgeInterval, func(ctx context.Context) error {
+		purged, err := a.Usecases.Webhook.PurgeWebhookEvents(ctx)
+		if err != nil {
+			return err
+		}
+		log.WithField("purged", purged).Info("Purged old webhook events")
+		return nil
+	})
+
+	return a, nil
+}
+
+// newBlobStore is the place to add a cloud bucket implementation of
+// domain.BlobStore next to the local one
+func newBlobStore(cfg Config) (domain.BlobStore, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return storage.NewLocalStore(cfg.StorageDir, cfg.StorageBaseURL), nil
+	default:
+		return nil, errors.Errorf("[App.New]: unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+func newMailer(cfg Config) (domain.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case "file":
+		return mail.NewFileMailer(cfg.MailDir, cfg.MailFrom), nil
+	case "log":
+		return mail.NewLogMailer(cfg.MailFrom), nil
+	default:
+		return nil, errors.Errorf("[App.New]: unknown mail driver %q", cfg.MailDriver)
+	}
+}
+
+// newRateLimitStore is the place to add a shared store such as Redis, so
+// several instances enforce one limit
+func newRateLimitStore(cfg Config) (domain.RateLimitStore, error) {
+	switch cfg.RateLimitDriver {
+	case "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, errors.Errorf("[App.New]: unknown rate limit driver %q", cfg.RateLimitDriver)
+	}
+}
+
+func (a *App) newEcho() *echo.Echo {
+	e := echo.New()
+
+	// Failed logins are counted per client IP, so only trust forwarding
+	// headers when a proxy in front of the server sets them
+	switch a.Config.IPExtractor {
+	case "xff":
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	case "realip":
+		e.IPExtractor = echo.ExtractIPFromRealIPHeader()
+	default:
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+
+	// Configure CORS
+	e.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
+		AllowOrigins:     a.Config.AllowOrigins,
+		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderXCSRFToken},
+		AllowCredentials: true,
+		ExposeHeaders: []string{
+			echo.HeaderAuthorization,
+			echo.HeaderXCSRFToken,
+			echo.HeaderRetryAfter,
+			middleware.HeaderRateLimitLimit,
+			middleware.HeaderRateLimitRemaining,
+			middleware.HeaderRateLimitReset,
+			middleware.HeaderRateLimitPolicy,
+		},
+	}))
+
+	e.Use(echoMiddleware.Recover())
+	if a.RateLimitStore != nil {
+		e.Use(middleware.RateLimit(a.RateLimitStore, a.Config.RateLimitPolicies))
+	}
+	e.Use(middleware.QueryTimeout(a.Config.QueryTimeout))
+
+	// Unauthenticated route
+	e.GET("/", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{"success": true})
+	})
+
+	// Public halves of the token signing keys, for services verifying our
+	// tokens. Short cache so a newly added key is picked up well before it
+	// starts signing.
+	e.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		jwks, err := utils.JWKS()
+		if err != nil {
+			log.WithError(err).Error("Failed to list JWT keys")
+			return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+		return c.JSON(http.StatusOK, jwks)
+	})
+
+	// Uploaded files, only when the base URL points back at this server
+	if a.Config.StorageDriver == "local" {
+		if u, err := url.Parse(a.Config.StorageBaseURL); err == nil && u.Host == "" {
+			e.Static(u.Path, a.Config.StorageDir)
+		}
+	}
+
+	a.Handlers = Handlers{
+		Auth:     authDelivery.NewHandler(e.Group("/auth"), a.Usecases.Auth, a.Usecases.MFA),
+		Category: categoryDelivery.NewHandler(e.Group("/categories"), a.Usecases.Category),
+		Shop:     shopDelivery.NewHandler(e.Group("/shops"), a.Usecases.Shop, a.Usecases.Order, a.Usecases.Webhook, middleware.APIKeyRateLimit(a.RateLimitStore, a.Config.APIKeyRateLimit)),
+		Product:  productDelivery.NewHandler(e.Group("/products"), a.Usecases.Product),
+		User:     userDelivery.NewHandler(e.Group("/users"), a.Usecases.User, a.Usecases.Order, a.Usecases.Notification),
+	}
+
+	return e
+}
+
+func (a *App) OnStart(hook Hook) {
+	a.startHooks = append(a.startHooks, hook)
+}
+
+func (a *App) OnStop(hook Hook) {
+	a.stopHooks = append(a.stopHooks, hook)
+}
+
+// Start runs the start hooks and begins serving in the background. It returns
+// once the listener is bound, so a bad port is reported to the caller.
+func (a *App) Start(ctx context.Context) error {
+	for _, hook := range a.startHooks {
+		if err := hook(ctx); err != nil {
+			return errors.Wrap(err, "[App.Start]: start hook failed")
+		}
+	}
+
+	a.startJobs()
+
+	ln, err := net.Listen("tcp", a.Config.HTTPPort)
+	if err != nil {
+		return errors.Wrap(err, "[App.Start]: failed to listen")
+	}
+	a.Echo.Listener = ln
+
+	log.WithField("address", ln.Addr().String()).Info("Starting server")
+
+	go func() {
+		if err := a.Echo.Start(""); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("Server stopped unexpectedly")
+		}
+	}()
+
+	return nil
+}
+
+// Stop drains the HTTP server, stops the scheduled jobs and runs the stop
+// hooks. Every hook runs even if an earlier one fails, the first error is
+// returned.
+func (a *App) Stop(ctx context.Context) error {
+	var firstErr error
+	if a.Echo.Listener != nil {
+		if err := a.Echo.Shutdown(ctx); err != nil {
+			firstErr = errors.Wrap(err, "[App.Stop]: failed to shut down server")
+		}
+	}
+
+	if err := a.waitJobs(ctx); err != nil && firstErr == nil {
+		firstErr = errors.Wrap(err, "[App.Stop]: failed to stop jobs")
+	}
+
+	for i := len(a.stopHooks) - 1; i >= 0; i-- {
+		if err := a.stopHooks[i](ctx); err != nil {
+			log.WithError(err).Error("Stop hook failed")
+			if firstErr == nil {
+				firstErr = errors.Wrap(err, "[App.Stop]: stop hook failed")
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// Run starts the app and blocks until an interrupt, then stops it within the
+// configured shutdown timeout.
+func (a *App) Run() error {
+	if err := a.Start(context.Background()); err != nil {
+		return err
+	}
+
+	// Wait for interrupt signal to gracefully shutdown the server with a timeout
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	log.Info("Server shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+	return a.Stop(ctx)
+}
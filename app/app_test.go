@@ -0,0 +1,168 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/viper"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testActionSecret = "test-action-secret-0123456789abcdef"
+
+// newTestApp builds the app with the local defaults on a mocked database.
+// Nothing is listening, requests go through App.Echo directly.
+func newTestApp(t *testing.T) (*App, sqlmock.Sqlmock) {
+	t.Helper()
+
+	t.Setenv("RUN_ENV", "local")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("user.actionsecret", testActionSecret)
+	viper.Set("storage.dir", t.TempDir())
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(LoadConfig(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, mock
+}
+
+func request(a *App, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.Echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAppServesRoutes(t *testing.T) {
+	a, mock := newTestApp(t)
+
+	if rec := request(a, http.MethodGet, "/", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET / = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec := request(a, http.MethodGet, "/.well-known/jwks.json", "")
+	jwks := entity.JWKS{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &jwks); err != nil || rec.Code != http.StatusOK || len(jwks.Keys) != 1 {
+		t.Errorf("GET /.well-known/jwks.json = %d %s, want one key", rec.Code, rec.Body)
+	}
+
+	mock.ExpectQuery(`FROM "users" WHERE id = \$1`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "address"}).AddRow(7, "alice@example.com", "Bangkok"))
+	rec = request(a, http.MethodGet, "/users/7", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "alice@example.com") {
+		t.Errorf("GET /users/7 = %d %s, want the user", rec.Code, rec.Body)
+	}
+
+	if rec := request(a, http.MethodGet, "/users/notifications", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /users/notifications without token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	token, err := utils.GenerateJWT(map[string]interface{}{
+		"id":    7,
+		"email": "alice@example.com",
+		"role":  entity.CUSTOMER,
+	}, entity.UserTokenAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "notifications" WHERE user_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "event_id", "type", "order_id", "title", "body"}).
+			AddRow(1, 7, 42, entity.NotificationOrderStatus, 3, "Your order #3 has shipped", "Good news"))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "notifications"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	rec = request(a, http.MethodGet, "/users/notifications", token)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"unread":1`) {
+		t.Errorf("GET /users/notifications = %d %s, want the feed", rec.Code, rec.Body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewRefusesUnsafeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		secret string
+		driver string
+	}{
+		{"no action secret", "local", "", ""},
+		{"short action secret", "local", "too-short", ""},
+		{"no mail driver outside local", "production", testActionSecret, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RUN_ENV", tt.env)
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set("user.actionsecret", tt.secret)
+			viper.Set("mail.driver", tt.driver)
+
+			if _, err := New(LoadConfig(), nil); err == nil {
+				t.Error("New succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestAPIKeyOfDeactivatedShopIsForbidden(t *testing.T) {
+	a, mock := newTestApp(t)
+
+	key := "omk_deactivatedshopkey"
+	sum := sha256.Sum256([]byte(key))
+	mock.ExpectQuery(`SELECT \* FROM "shop_api_keys" WHERE key_hash = \$1`).
+		WithArgs(hex.EncodeToString(sum[:]), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "name", "scopes"}).
+			AddRow(3, 5, "fulfilment", string(entity.OrdersRead)))
+	mock.ExpectQuery(`SELECT "id","name","description","deactivated_at" FROM "shops"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "deactivated_at"}).
+			AddRow(5, "Closed shop", "", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "shop_api_keys"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodGet, "/shops/orders", nil)
+	req.Header.Set("Authorization", "ApiKey "+key)
+	rec := httptest.NewRecorder()
+	a.Echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET /shops/orders with the key of a deactivated shop = %d %s, want %d", rec.Code, rec.Body, http.StatusForbidden)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
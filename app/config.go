@@ -0,0 +1,223 @@
+package app
+
+import (
+	"os"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	HTTPPort        string
+	AllowOrigins    []string
+	ShutdownTimeout time.Duration
+	QueryTimeout    time.Duration
+
+	// IPExtractor tells how the client IP is found: "direct" uses the
+	// connection, "xff" and "realip" trust X-Forwarded-For or X-Real-IP
+	// from proxies in private networks.
+	IPExtractor string
+
+	// ProductPurgeInterval is how often soft deleted products are purged,
+	// a negative value disables the job.
+	ProductPurgeInterval time.Duration
+
+	// LoginPurgeInterval is how often forgotten failed login counts are
+	// deleted, a negative value disables the job.
+	LoginPurgeInterval time.Duration
+
+	// EventDispatchInterval is how often published domain events are handed
+	// to their subscribers, EventPurgeInterval how often old ones are
+	// deleted. A negative value disables the job.
+	EventDispatchInterval time.Duration
+	EventPurgeInterval    time.Duration
+
+	// WebhookDispatchInterval is how often due webhook deliveries are sent,
+	// WebhookPurgeInterval how often old events and their delivery log are
+	// deleted. A negative value disables the job.
+	WebhookDispatchInterval time.Duration
+	WebhookPurgeInterval    time.Duration
+
+	// StorageDriver picks the blob store for uploads, only "local" exists
+	// so far. The local store keeps files in StorageDir and serves them
+	// under StorageBaseURL.
+	StorageDriver  string
+	StorageDir     string
+	StorageBaseURL string
+
+	// ActionSecret signs the links of verification, reset and unlock
+	// emails. It is required and at least utils.MinActionSecretSize bytes,
+	// see rotate-secrets.
+	ActionSecret string
+
+	// MailDriver picks how emails leave the server: "smtp", "file" writes
+	// them to MailDir for local development and "log" only logs them. It
+	// defaults to "log" locally and is required elsewhere.
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// RateLimitDriver picks the store of the rate limit buckets, "memory"
+	// or "none" to turn rate limiting off.
+	RateLimitDriver   string
+	RateLimitPolicies []entity.RateLimitPolicy
+
+	// APIKeyRateLimit is the requests per minute of shop API keys that set
+	// no limit of their own
+	APIKeyRateLimit int
+}
+
+// defaultRateLimitPolicies apply when ratelimit.policies is not configured
+var defaultRateLimitPolicies = []entity.RateLimitPolicy{
+	{
+		Name:   "auth",
+		Limit:  10,
+		Window: time.Minute,
+		Routes: []string{
+			"POST /users/register",
+			"POST /users/login",
+			"POST /users/verify-email",
+			"POST /users/password/forgot",
+			"POST /users/password/reset",
+			"POST /users/unlock",
+			"POST /shops/register",
+			"POST /shops/login",
+			"POST /shops/login/mfa",
+			"POST /shops/login/mfa/enrol",
+		},
+	},
+	{
+		Name:   "default",
+		Limit:  300,
+		Window: time.Minute,
+	},
+}
+
+// LoadConfig reads the application settings from viper, so utils.InitViper
+// must have been called first. Missing keys fall back to the values the
+// server has always used.
+func LoadConfig() Config {
+	cfg := Config{
+		HTTPPort:        viper.GetString("http.port"),
+		AllowOrigins:    viper.GetStringSlice("http.alloworigins"),
+		ShutdownTimeout: viper.GetDuration("http.shutdowntimeout"),
+		QueryTimeout:    viper.GetDuration("postgres.querytimeout"),
+		IPExtractor:     viper.GetString("http.ipextractor"),
+
+		ProductPurgeInterval: viper.GetDuration("product.purgeinterval"),
+		LoginPurgeInterval:   viper.GetDuration("login.purgeinterval"),
+
+		EventDispatchInterval: viper.GetDuration("events.dispatchinterval"),
+		EventPurgeInterval:    viper.GetDuration("events.purgeinterval"),
+
+		WebhookDispatchInterval: viper.GetDuration("webhook.dispatchinterval"),
+		WebhookPurgeInterval:    viper.GetDuration("webhook.purgeinterval"),
+
+		StorageDriver:  viper.GetString("storage.driver"),
+		StorageDir:     viper.GetString("storage.dir"),
+		StorageBaseURL: viper.GetString("storage.baseurl"),
+
+		ActionSecret: viper.GetString("user.actionsecret"),
+
+		MailDriver:   viper.GetString("mail.driver"),
+		MailFrom:     viper.GetString("mail.from"),
+		MailDir:      viper.GetString("mail.dir"),
+		SMTPHost:     viper.GetString("mail.smtp.host"),
+		SMTPPort:     viper.GetInt("mail.smtp.port"),
+		SMTPUsername: viper.GetString("mail.smtp.username"),
+		SMTPPassword: viper.GetString("mail.smtp.password"),
+
+		RateLimitDriver: viper.GetString("ratelimit.driver"),
+		APIKeyRateLimit: viper.GetInt("apikey.ratelimit"),
+	}
+
+	if err := viper.UnmarshalKey("ratelimit.policies", &cfg.RateLimitPolicies); err != nil {
+		log.WithError(err).Warn("Invalid ratelimit.policies, using the default policies")
+		cfg.RateLimitPolicies = nil
+	}
+
+	if port := os.Getenv("HTTP_PORT"); port != "" {
+		cfg.HTTPPort = port
+	}
+	if len(cfg.AllowOrigins) == 0 {
+		cfg.AllowOrigins = []string{"http://localhost:3000"}
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	if cfg.ProductPurgeInterval == 0 {
+		cfg.ProductPurgeInterval = 24 * time.Hour
+	}
+	if cfg.IPExtractor == "" {
+		cfg.IPExtractor = "direct"
+	}
+	if cfg.LoginPurgeInterval == 0 {
+		cfg.LoginPurgeInterval = time.Hour
+	}
+	if cfg.EventDispatchInterval == 0 {
+		cfg.EventDispatchInterval = 2 * time.Second
+	}
+	if cfg.EventPurgeInterval == 0 {
+		cfg.EventPurgeInterval = 24 * time.Hour
+	}
+	if cfg.WebhookDispatchInterval == 0 {
+		cfg.WebhookDispatchInterval = 5 * time.Second
+	}
+	if cfg.WebhookPurgeInterval == 0 {
+		cfg.WebhookPurgeInterval = 24 * time.Hour
+	}
+	if cfg.StorageDriver == "" {
+		cfg.StorageDriver = "local"
+	}
+	if cfg.StorageDir == "" {
+		cfg.StorageDir = "uploads"
+	}
+	if cfg.StorageBaseURL == "" {
+		cfg.StorageBaseURL = "/uploads"
+	}
+	if cfg.RateLimitDriver == "" {
+		cfg.RateLimitDriver = "memory"
+	}
+	if len(cfg.RateLimitPolicies) == 0 {
+		cfg.RateLimitPolicies = defaultRateLimitPolicies
+	}
+	if cfg.APIKeyRateLimit == 0 {
+		cfg.APIKeyRateLimit = 120
+	}
+	if cfg.MailDriver == "" && utils.IsLocal() {
+		cfg.MailDriver = "log"
+	}
+	if cfg.MailFrom == "" {
+		cfg.MailFrom = "Order Management <no-reply@localhost>"
+	}
+	if cfg.MailDir == "" {
+		cfg.MailDir = "outbox"
+	}
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 587
+	}
+
+	return cfg
+}
+
+// validate refuses settings the server cannot run safely with
+func (cfg Config) validate() error {
+	if err := utils.CheckActionSecret([]byte(cfg.ActionSecret)); err != nil {
+		return errors.Wrap(err, "[App.validate]: invalid user.actionsecret")
+	}
+	// Emails carry live reset and unlock links, which must not end up
+	// in the logs of a real deployment
+	if cfg.MailDriver == "" {
+		return errors.New("[App.validate]: mail.driver is required outside of local development")
+	}
+	return nil
+}
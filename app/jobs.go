@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Schedule registers a background job that runs every interval while the app
+// is started. Jobs start after the start hooks and are stopped before the stop
+// hooks, so they can rely on the database being open.
+func (a *App) Schedule(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		log.WithField("job", name).Info("Job disabled")
+		return
+	}
+	a.jobs = append(a.jobs, job{name: name, interval: interval, run: run})
+}
+
+func (a *App) startJobs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopJobs = cancel
+
+	for _, j := range a.jobs {
+		a.jobsRunning.Add(1)
+		go func(j job) {
+			defer a.jobsRunning.Done()
+
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := j.run(ctx); err != nil {
+						log.WithField("job", j.name).WithError(err).Error("Scheduled job failed")
+					}
+				}
+			}
+		}(j)
+	}
+}
+
+// waitJobs cancels the jobs and waits for the running ones to return, or for
+// ctx to expire.
+func (a *App) waitJobs(ctx context.Context) error {
+	if a.stopJobs == nil {
+		return nil
+	}
+	a.stopJobs()
+
+	done := make(chan struct{})
+	go func() {
+		a.jobsRunning.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
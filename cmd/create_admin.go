@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+
+	"order-management/entity"
+	authRepository "order-management/features/auth/repository"
+	authUsecase "order-management/features/auth/usecase"
+	userRepository "order-management/features/user/repository"
+	userUsecase "order-management/features/user/usecase"
+	"order-management/mail"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var createAdminCommand = Command{
+	Name:  "create-admin",
+	Usage: "Create an administrator account",
+	Run:   runCreateAdmin,
+}
+
+func runCreateAdmin(args []string) error {
+	fs := newFlagSet("create-admin")
+	email := fs.String("email", "", "admin email (required)")
+	password := fs.String("password", "", "admin password (required)")
+	address := fs.String("address", "", "admin address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" || *password == "" {
+		return errors.New("[Cmd.CreateAdmin]: email and password are required")
+	}
+
+	db, err := connect()
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.CreateAdmin]: failed to connect to database")
+	}
+
+	// Admins are created verified, nothing is mailed or signed
+	guard := authUsecase.NewLoginGuard(authRepository.NewLoginAttemptRepository(db))
+	usecase := userUsecase.NewUserUsecase(userRepository.NewUserRepository(db), mail.NewLogMailer(""), guard, nil)
+	if err := usecase.CreateAdmin(context.Background(), entity.User{
+		Email:    *email,
+		Password: *password,
+		Address:  *address,
+	}); err != nil {
+		return errors.Wrap(err, "[Cmd.CreateAdmin]: failed to create admin")
+	}
+
+	log.WithField("email", *email).Info("Admin created")
+	return nil
+}
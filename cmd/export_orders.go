@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+
+	orderRepository "order-management/features/order/repository"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var exportOrdersCommand = Command{
+	Name:  "export-orders",
+	Usage: "Export every order line as CSV",
+	Run:   runExportOrders,
+}
+
+func runExportOrders(args []string) error {
+	fs := newFlagSet("export-orders")
+	out := fs.String("out", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.ExportOrders]: failed to connect to database")
+	}
+
+	orders, err := orderRepository.NewOrderRepository(db).GetAllOrders(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.ExportOrders]: failed to get orders")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return errors.Wrap(err, "[Cmd.ExportOrders]: failed to create output file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// price is the unit price of the line when the order was placed
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"order_id", "user_id", "status", "courier", "total", "product_id", "product_name", "variant_id", "sku", "price", "amount"}); err != nil {
+		return errors.Wrap(err, "[Cmd.ExportOrders]: failed to write csv")
+	}
+	for _, order := range orders {
+		for _, op := range order.OrderProducts {
+			sku := op.Variant.SKU
+			if op.VariantID == 0 && op.Product.SKU != nil {
+				sku = *op.Product.SKU
+			}
+			if err := cw.Write([]string{
+				strconv.FormatUint(uint64(order.ID), 10),
+				strconv.FormatUint(uint64(order.UserID), 10),
+				string(order.Status),
+				order.Courier,
+				strconv.FormatFloat(float64(order.Total), 'f', 2, 32),
+				strconv.FormatUint(uint64(op.ProductID), 10),
+				op.Product.Name,
+				strconv.FormatUint(uint64(op.VariantID), 10),
+				sku,
+				strconv.FormatUint(uint64(op.Price), 10),
+				strconv.FormatUint(uint64(op.Amount), 10),
+			}); err != nil {
+				return errors.Wrap(err, "[Cmd.ExportOrders]: failed to write csv")
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return errors.Wrap(err, "[Cmd.ExportOrders]: failed to write csv")
+	}
+
+	log.WithField("orders", len(orders)).Info("Orders exported")
+	return nil
+}
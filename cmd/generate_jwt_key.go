@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var generateJWTKeyCommand = Command{
+	Name:  "generate-jwt-key",
+	Usage: "Generate a token signing key to add to jwt.keys",
+	Run:   runGenerateJWTKey,
+}
+
+// The key is only printed, as a jwt.keys entry to paste into the config (or
+// Secret Manager outside of local). See "Rotating signing keys" in the README
+// for when to make it the signing key.
+func runGenerateJWTKey(args []string) error {
+	fs := newFlagSet("generate-jwt-key")
+	alg := fs.String("alg", "EdDSA", "signing algorithm, EdDSA or RS256")
+	kid := fs.String("kid", time.Now().UTC().Format("2006-01-02"), "key id")
+	bits := fs.Int("bits", 3072, "RSA key size")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var private crypto.Signer
+	var err error
+	switch *alg {
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		if *bits < 2048 {
+			return errors.New("[Cmd.GenerateJWTKey]: RSA keys must have at least 2048 bits")
+		}
+		private, err = rsa.GenerateKey(rand.Reader, *bits)
+	default:
+		return errors.Errorf("[Cmd.GenerateJWTKey]: unknown algorithm %q", *alg)
+	}
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.GenerateJWTKey]: failed to generate key")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.GenerateJWTKey]: failed to encode key")
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	fmt.Println("jwt:")
+	fmt.Println("  keys:")
+	fmt.Printf("    - kid: %q\n", *kid)
+	fmt.Println("      privatekey: |")
+	for _, line := range strings.Split(strings.TrimSpace(string(block)), "\n") {
+		fmt.Println("        " + line)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"order-management/database"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var migrateCommand = Command{
+	Name:  "migrate",
+	Usage: "Apply database migrations and exit",
+	Run:   runMigrate,
+}
+
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.Migrate]: failed to connect to database")
+	}
+
+	if err := database.Migrate(db); err != nil {
+		return errors.Wrap(err, "[Cmd.Migrate]: failed to migrate database")
+	}
+
+	log.Info("Database migrated")
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+
+	"order-management/app"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var purgeProductsCommand = Command{
+	Name:  "purge-products",
+	Usage: "Permanently delete soft deleted products that were never ordered",
+	Run:   runPurgeProducts,
+}
+
+func runPurgeProducts(args []string) error {
+	fs := newFlagSet("purge-products")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.PurgeProducts]: failed to connect to database")
+	}
+
+	a, err := app.New(app.LoadConfig(), db)
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.PurgeProducts]: failed to build app")
+	}
+
+	purged, err := a.Usecases.Product.PurgeDeletedProducts(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.PurgeProducts]: failed to purge products")
+	}
+
+	log.WithField("purged", purged).Info("Purged deleted products")
+	return nil
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/pkg/errors"
+)
+
+var reindexSearchCommand = Command{
+	Name:  "reindex-search",
+	Usage: "Rebuild the product search index",
+	Run:   runReindexSearch,
+}
+
+// There is no search backend yet, products are listed straight from
+// Postgres. The command is registered so deploy scripts can already call it,
+// and fails loudly instead of pretending to have done something.
+func runReindexSearch(args []string) error {
+	fs := newFlagSet("reindex-search")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return errors.New("[Cmd.ReindexSearch]: no search backend is configured")
+}
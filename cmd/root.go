@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"order-management/database"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Command is a single subcommand of the binary. Run receives the arguments
+// that follow the command name.
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = []Command{
+	serveCommand,
+	migrateCommand,
+	seedCommand,
+	createAdminCommand,
+	reindexSearchCommand,
+	exportOrdersCommand,
+	purgeProductsCommand,
+	rotateSecretsCommand,
+	generateJWTKeyCommand,
+}
+
+// Execute runs the command named by args[0], falling back to serve when no
+// command is given so the container entrypoint keeps working unchanged.
+func Execute(args []string) error {
+	if len(args) == 0 {
+		return serveCommand.Run(nil)
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+
+	for _, c := range commands {
+		if c.Name == name {
+			if err := c.Run(args[1:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return nil
+		}
+	}
+
+	printUsage(os.Stderr)
+	return fmt.Errorf("[Cmd.Execute]: unknown command %q", name)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: order-management <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Usage)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'order-management <command> -h' for the flags of a command.")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+func loadConfig() {
+	utils.InitViper(utils.RunEnv())
+}
+
+// connect loads the configuration and opens the database, for commands that
+// need one.
+func connect() (*gorm.DB, error) {
+	loadConfig()
+
+	db, err := database.Connect()
+	if err != nil {
+		return nil, errors.Wrap(err, "[Cmd.connect]: failed to connect to database")
+	}
+	return db, nil
+}
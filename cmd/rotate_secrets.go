@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+)
+
+var rotateSecretsCommand = Command{
+	Name:  "rotate-secrets",
+	Usage: "Generate a new secret for email action tokens",
+	Run:   runRotateSecrets,
+}
+
+// The secret lives in the config file (or Secret Manager outside of local),
+// so this only generates a fresh value to paste in. It signs the links of
+// verification, reset and unlock emails, which all stop working once the new
+// secret is deployed. Access tokens are signed with jwt.keys instead, see
+// generate-jwt-key.
+func runRotateSecrets(args []string) error {
+	fs := newFlagSet("rotate-secrets")
+	size := fs.Int("bytes", 32, "number of random bytes per secret")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	actionSecret, err := randomSecret(*size)
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.RotateSecrets]: failed to generate action secret")
+	}
+
+	fmt.Println("user:")
+	fmt.Printf("  actionsecret: %q\n", actionSecret)
+	return nil
+}
+
+func randomSecret(size int) (string, error) {
+	if size < utils.MinActionSecretSize {
+		return "", errors.Errorf("[Cmd.randomSecret]: secret must be at least %d bytes", utils.MinActionSecretSize)
+	}
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"order-management/database"
+	"order-management/seeders"
+
+	"github.com/pkg/errors"
+)
+
+var seedCommand = Command{
+	Name:  "seed",
+	Usage: "Reset the database and load the sample data set",
+	Run:   runSeed,
+}
+
+func runSeed(args []string) error {
+	fs := newFlagSet("seed")
+	clean := fs.Bool("clean-only", false, "only delete existing data, do not insert samples")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.Seed]: failed to connect to database")
+	}
+
+	// Seeding writes into every table, so make sure they exist first
+	if err := database.Migrate(db); err != nil {
+		return errors.Wrap(err, "[Cmd.Seed]: failed to migrate database")
+	}
+
+	seeder := seeders.NewSeeder(db)
+	if *clean {
+		if err := seeder.Clean(); err != nil {
+			return errors.Wrap(err, "[Cmd.Seed]: failed to clean database")
+		}
+		return nil
+	}
+
+	if err := seeder.Seed(); err != nil {
+		return errors.Wrap(err, "[Cmd.Seed]: failed to seed database")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+
+	"order-management/app"
+	"order-management/database"
+	"order-management/seeders"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var serveCommand = Command{
+	Name:  "serve",
+	Usage: "Start the HTTP API server (default)",
+	Run:   runServe,
+}
+
+func runServe(args []string) error {
+	fs := newFlagSet("serve")
+	migrate := fs.Bool("migrate", true, "run database migrations before serving")
+	seed := fs.Bool("seed", false, "reset and seed the database before serving")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.Serve]: failed to connect to database")
+	}
+
+	a, err := app.New(app.LoadConfig(), db)
+	if err != nil {
+		return errors.Wrap(err, "[Cmd.Serve]: failed to build app")
+	}
+
+	if *migrate {
+		a.OnStart(func(ctx context.Context) error {
+			return database.Migrate(a.DB)
+		})
+	}
+
+	if *seed {
+		a.OnStart(func(ctx context.Context) error {
+			if err := seeders.NewSeeder(a.DB).Seed(); err != nil {
+				log.Warn("Failed to seed database:", err)
+			}
+			return nil
+		})
+	}
+
+	a.OnStop(func(ctx context.Context) error {
+		sqlDB, err := a.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+
+	if err := a.Run(); err != nil {
+		return errors.Wrap(err, "[Cmd.Serve]: server error")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func Connect() (*gorm.DB, error) {
+	connectionString := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s",
+		utils.ViperGetString("postgres.host"),
+		utils.ViperGetString("postgres.user"),
+		utils.ViperGetString("postgres.password"),
+		utils.ViperGetString("postgres.dbname"),
+		utils.ViperGetString("postgres.port"))
+
+	log.Info("Connecting to database")
+	db, err := gorm.Open(postgres.Open(connectionString), &gorm.Config{
+		TranslateError: true,
+		Logger: logger.New(
+			log.StandardLogger(),
+			logger.Config{
+				SlowThreshold:             time.Second,
+				LogLevel:                  logger.Silent,
+				IgnoreRecordNotFoundError: true,
+			},
+		),
+	})
+	if err != nil {
+		log.Error("Failed to connect to database")
+		return nil, errors.Wrap(err, "[Database.Connect]: failed to connect to database")
+	}
+
+	log.Info("Database connected")
+	return db, nil
+}
+
+func Migrate(db *gorm.DB) error {
+	log.Info("Migrating database")
+	if err := db.AutoMigrate(
+		&entity.User{},
+		&entity.Order{},
+		&entity.Category{},
+		&entity.Tag{},
+		&entity.Product{},
+		&entity.Shop{},
+		&entity.ProductOptionType{},
+		&entity.ProductOptionValue{},
+		&entity.ProductVariant{},
+		&entity.OrderProduct{},
+		&entity.ProductImage{},
+		&entity.ProductImageThumbnail{},
+		&entity.ProductReview{},
+		&entity.ShopMember{},
+		&entity.LoginAttempt{},
+		&entity.ShopRecoveryCode{},
+		&entity.Setting{},
+		&entity.ShopAPIKey{},
+		&entity.Webhook{},
+		&entity.WebhookEvent{},
+		&entity.WebhookDelivery{},
+		&entity.DomainEvent{},
+		&entity.EventDelivery{},
+		&entity.Notification{},
+		&entity.NotificationPreference{},
+	); err != nil {
+		return errors.Wrap(err, "[Database.Migrate]: failed to migrate database")
+	}
+
+	if err := widenOrderProductKey(db); err != nil {
+		return errors.Wrap(err, "[Database.Migrate]: failed to widen order_products primary key")
+	}
+
+	if err := backfillShopSlugs(db); err != nil {
+		return errors.Wrap(err, "[Database.Migrate]: failed to backfill shop slugs")
+	}
+	return nil
+}
+
+// Tables created before product variants keyed order lines on
+// (order_id, product_id). AutoMigrate adds the variant_id column but never
+// touches an existing primary key, so widen it once here.
+func widenOrderProductKey(db *gorm.DB) error {
+	var columns int64
+	if err := db.Raw(`SELECT count(*) FROM information_schema.key_column_usage
+		WHERE table_name = 'order_products' AND constraint_name = 'order_products_pkey'`).
+		Scan(&columns).Error; err != nil {
+		return err
+	}
+	if columns != 2 {
+		return nil
+	}
+
+	log.Info("Adding variant_id to the order_products primary key")
+	return db.Exec(`ALTER TABLE order_products
+		DROP CONSTRAINT order_products_pkey,
+		ADD PRIMARY KEY (order_id, product_id, variant_id)`).Error
+}
+
+// Shops registered before storefronts have no slug yet, derive one from the
+// name the same way registration does. Shops whose slug was reserved after
+// they took it get a new one too.
+func backfillShopSlugs(db *gorm.DB) error {
+	reserved := make([]string, 0, len(entity.ReservedShopSlugs))
+	for slug := range entity.ReservedShopSlugs {
+		reserved = append(reserved, slug)
+	}
+
+	var shops []entity.Shop
+	if err := db.Select("id", "name").Where("slug IS NULL OR slug = '' OR slug IN ?", reserved).Order("id").Find(&shops).Error; err != nil {
+		return err
+	}
+	if len(shops) == 0 {
+		return nil
+	}
+
+	var existing []string
+	if err := db.Model(&entity.Shop{}).Where("slug <> ''").Pluck("slug", &existing).Error; err != nil {
+		return err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, slug := range existing {
+		taken[slug] = true
+	}
+
+	log.WithField("shops", len(shops)).Info("Generating shop slugs")
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, shop := range shops {
+			base := utils.Slugify(shop.Name)
+			if base == "" {
+				base = "shop"
+			}
+			slug := utils.UniqueSlug(base, func(slug string) bool {
+				return taken[slug] || entity.ReservedShopSlugs[slug]
+			})
+			taken[slug] = true
+			if err := tx.Model(&entity.Shop{}).Where("id = ?", shop.ID).Update("slug", slug).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
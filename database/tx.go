@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+
+	"order-management/domain"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+type transactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor lets usecases group repository calls into one transaction,
+// see Conn
+func NewTransactor(db *gorm.DB) domain.Transactor {
+	return &transactor{db: db}
+}
+
+// WithinTransaction commits when fn returns nil and rolls back otherwise.
+// Nested calls join the outer transaction.
+func (t *transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// Conn is what repositories query through: the transaction ctx runs in, or
+// db when there is none. Transactions a repository starts on it become
+// savepoints of the outer one.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
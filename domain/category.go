@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"context"
+
+	"order-management/entity"
+)
+
+type CategoryUsecase interface {
+	GetCategories(ctx context.Context) ([]entity.CategoryResponse, error)
+	GetCategoryProducts(ctx context.Context, slug string, tag string) (entity.CategoryProducts, error)
+	CreateCategory(ctx context.Context, req entity.CategoryRequest) (entity.Category, error)
+	UpdateCategory(ctx context.Context, id uint32, req entity.CategoryRequest) (entity.Category, error)
+	DeleteCategory(ctx context.Context, id uint32) error
+}
+
+type CategoryRepository interface {
+	GetAllCategories(ctx context.Context) ([]entity.Category, error)
+	GetCategoryByID(ctx context.Context, id uint32) (entity.Category, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (entity.Category, error)
+	CreateCategory(ctx context.Context, category *entity.Category) error
+	UpdateCategory(ctx context.Context, category *entity.Category) error
+	DeleteCategory(ctx context.Context, id uint32) error
+	CountProductsByCategory(ctx context.Context) ([]entity.CategoryProductCount, error)
+	GetProductsInCategoryTree(ctx context.Context, categoryID uint32, tag string) ([]entity.ProductWithOutShop, error)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"order-management/entity"
+)
+
+// Transactor runs fn in one database transaction. Repositories called with
+// the ctx fn receives take part in it, so a usecase can write a change and
+// the events reporting it atomically.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// EventPublisher writes an event to the outbox, in the transaction of ctx
+// when there is one. payload is the event's struct in entity.
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType entity.EventType, aggregateID uint32, payload interface{}) error
+}
+
+// EventHandler reacts to an event. An error, or a panic, makes the bus try
+// the event again later.
+type EventHandler func(ctx context.Context, event entity.DomainEvent) error
+
+// EventBus hands published events to the in-process subscribers. Subscribe
+// before the first Dispatch, events published while a type has no
+// subscriber are not kept for later ones.
+type EventBus interface {
+	EventPublisher
+	// Subscribe registers handler for the types under a name that stays the
+	// same across releases, deliveries are tracked by it
+	Subscribe(name string, handler EventHandler, types ...entity.EventType)
+	// Dispatch fans new events out to their subscribers and runs the
+	// deliveries that are due, it returns how many ran
+	Dispatch(ctx context.Context) (int, error)
+	PurgeEvents(ctx context.Context) (int64, error)
+}
+
+type EventRepository interface {
+	AddEvent(ctx context.Context, event *entity.DomainEvent) error
+	// FanOutEvents creates a delivery of up to limit undispatched events for
+	// each subscriber of their type
+	FanOutEvents(ctx context.Context, subscribers map[entity.EventType][]string, limit int) (int, error)
+	ClaimDueDeliveries(ctx context.Context, lease time.Duration, limit int) ([]entity.EventDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *entity.EventDelivery) error
+	PurgeEvents(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"order-management/entity"
+)
+
+// LoginGuard limits password guessing. Callers check the account and client
+// IP keys before comparing a password and report the outcome afterwards.
+type LoginGuard interface {
+	// Check returns how long the caller has to wait before any of keys may
+	// try again, zero when all of them may.
+	Check(ctx context.Context, keys ...string) (time.Duration, error)
+	// Failed counts a failure for every key and returns the attempts that
+	// just got locked.
+	Failed(ctx context.Context, keys ...string) ([]entity.LoginAttempt, error)
+	// Succeeded forgets the failures of an account key. IP keys are left
+	// alone so a valid account cannot reset the counter of its client.
+	Succeeded(ctx context.Context, key string) error
+	GetLoginAttempt(ctx context.Context, key string) (entity.LoginAttempt, error)
+	GetLockouts(ctx context.Context) ([]entity.LoginAttempt, error)
+	Unlock(ctx context.Context, key string) error
+	PurgeLoginAttempts(ctx context.Context) (int64, error)
+}
+
+type LoginAttemptRepository interface {
+	GetLoginAttempts(ctx context.Context, keys []string) ([]entity.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (entity.LoginAttempt, error)
+	SetLoginBlocked(ctx context.Context, key string, until time.Time, locked bool) error
+	DeleteLoginAttempt(ctx context.Context, key string) error
+	GetLockedLoginAttempts(ctx context.Context, now time.Time) ([]entity.LoginAttempt, error)
+	DeleteExpiredLoginAttempts(ctx context.Context, before time.Time, now time.Time) (int64, error)
+}
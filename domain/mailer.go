@@ -0,0 +1,13 @@
+package domain
+
+import (
+	"context"
+
+	"order-management/entity"
+)
+
+// Mailer delivers emails to users. Implementations are picked by mail.driver,
+// see app.newMailer.
+type Mailer interface {
+	Send(ctx context.Context, email entity.Email) error
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+
+	"order-management/entity"
+)
+
+type NotificationUsecase interface {
+	GetNotifications(ctx context.Context, userID uint32, filter entity.NotificationFilter) (entity.NotificationFeed, error)
+	MarkRead(ctx context.Context, userID uint32, notificationID uint64) error
+	MarkAllRead(ctx context.Context, userID uint32) (int64, error)
+	GetPreferences(ctx context.Context, userID uint32) ([]entity.NotificationPreferenceItem, error)
+	UpdatePreferences(ctx context.Context, userID uint32, req entity.NotificationPreferencesRequest) ([]entity.NotificationPreferenceItem, error)
+	// HandleOrderStatusChanged is the event bus subscriber that notifies the
+	// buyer of an order
+	HandleOrderStatusChanged(ctx context.Context, event entity.DomainEvent) error
+}
+
+type NotificationRepository interface {
+	// AddNotification keeps the stored notification when the user already
+	// has one of the event, and loads it into notification
+	AddNotification(ctx context.Context, notification *entity.Notification) error
+	SetEmailed(ctx context.Context, notificationID uint64) error
+	GetNotifications(ctx context.Context, userID uint32, filter entity.NotificationFilter) ([]entity.Notification, error)
+	CountUnread(ctx context.Context, userID uint32) (int64, error)
+	MarkRead(ctx context.Context, userID uint32, notificationID uint64) error
+	MarkAllRead(ctx context.Context, userID uint32) (int64, error)
+	GetPreferences(ctx context.Context, userID uint32) ([]entity.NotificationPreference, error)
+	SavePreferences(ctx context.Context, preferences []entity.NotificationPreference) error
+}
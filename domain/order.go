@@ -1,22 +1,31 @@
 package domain
 
-import "order-management/entity"
+import (
+	"context"
+
+	"order-management/entity"
+)
 
 type OrderUsecase interface {
-	GetAllOrders() ([]entity.Order, error)
-	GetOrder(orderID uint32) (entity.OrderResponse, error)
-	GetOrdersByUserID(userID uint32) ([]entity.OrderResponse, error)
-	GetOrdersByShopID(shopID uint32) ([]entity.OrderResponse, error)
-	CreateOrder(orderRequest entity.OrderRequest, userID uint32) error
+	GetAllOrders(ctx context.Context) ([]entity.Order, error)
+	GetOrder(ctx context.Context, orderID uint32) (entity.OrderResponse, error)
+	GetOrdersByUserID(ctx context.Context, userID uint32, filter entity.OrderFilter) (entity.OrderPage, error)
+	GetOrdersByShopID(ctx context.Context, shopID uint32, filter entity.OrderFilter) (entity.OrderPage, error)
+	CreateOrder(ctx context.Context, orderRequest entity.OrderRequest, userID uint32) error
+	// UpdateOrderStatus moves an order containing products of the shop on:
+	// PENDING to SHIPPING or CANCELLED, SHIPPING to COMPLETED
+	UpdateOrderStatus(ctx context.Context, shopID uint32, orderID uint32, status entity.Status) (entity.OrderInfo, error)
 }
 
 type OrderRepository interface {
-	CreateOrder(order entity.Order) error
-	UpdateOrder(order entity.Order) error
-	DeleteOrder(orderID uint32) error
-	GetOrder(orderID uint32) (entity.Order, error)
-	GetOrdersByUserID(userID uint32) ([]uint32, error)
-	GetOrdersByShopID(shopID uint32) ([]uint32, error)
-	GetAllOrders() ([]entity.Order, error)
-	GetProductOrderAmount(orderID uint32, productID uint32) (uint32, error)
+	CreateOrder(ctx context.Context, order *entity.Order) error
+	UpdateOrder(ctx context.Context, order entity.Order) error
+	DeleteOrder(ctx context.Context, orderID uint32) error
+	GetOrder(ctx context.Context, orderID uint32) (entity.Order, error)
+	GetOrderForUpdate(ctx context.Context, orderID uint32) (entity.Order, error)
+	// ReleaseStock gives the variant stock reserved by the order's lines back
+	ReleaseStock(ctx context.Context, order entity.Order) error
+	GetOrdersByUserID(ctx context.Context, userID uint32, filter entity.OrderFilter) ([]entity.Order, error)
+	GetOrdersByShopID(ctx context.Context, shopID uint32, filter entity.OrderFilter) ([]entity.Order, error)
+	GetAllOrders(ctx context.Context) ([]entity.Order, error)
 }
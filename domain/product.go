@@ -1,21 +1,53 @@
 package domain
 
 import (
+	"context"
+	"time"
+
 	"order-management/entity"
 )
 
 type ProductUsecase interface {
-	GetAllProducts() ([]entity.ProductWithOutShop, error)
-	GetProductPrice(productID uint32) (float64, error)
-	GetProductByID(productID uint32) (entity.Product, error)
+	GetAllProducts(ctx context.Context) ([]entity.ProductWithOutShop, error)
+	GetProductPrice(ctx context.Context, productID uint32) (float64, error)
+	GetProductByID(ctx context.Context, productID uint32) (entity.Product, error)
+	PurgeDeletedProducts(ctx context.Context) (int64, error)
+	GetVariants(ctx context.Context, productID uint32) ([]entity.VariantResponse, error)
+	CreateReview(ctx context.Context, userID uint32, productID uint32, req entity.ReviewRequest) (entity.ReviewResponse, error)
+	GetReviews(ctx context.Context, productID uint32) (entity.ProductReviews, error)
 }
 
 type ProductRepository interface {
-	CreateProduct(product entity.Product, shopID uint32) error
-	GetProductsByShopID(shopID uint32) ([]entity.ProductWithOutShop, error)
-	UpdateProduct(req *entity.ProductManagementRequest, product *entity.Product) error
-	GetProductByID(productID uint32) (entity.Product, error)
-	DeleteProduct(req *entity.ProductManagementRequest) error
-	GetAllProducts() ([]entity.ProductWithOutShop, error)
-	GetProductPrice(productID uint32) (float64, error)
+	CreateProduct(ctx context.Context, product entity.Product, shopID uint32) error
+	GetProductsByShopID(ctx context.Context, shopID uint32) ([]entity.ProductWithOutShop, error)
+	UpdateProduct(ctx context.Context, req *entity.ProductManagementRequest, product *entity.Product) error
+	GetProductByID(ctx context.Context, productID uint32) (entity.Product, error)
+	GetActiveProductByID(ctx context.Context, productID uint32) (entity.Product, error)
+	DeleteProduct(ctx context.Context, req *entity.ProductManagementRequest) error
+	RestoreProduct(ctx context.Context, req *entity.ProductManagementRequest) error
+	GetDeletedProductsByShopID(ctx context.Context, shopID uint32) ([]entity.ProductWithOutShop, error)
+	PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) (int64, []entity.ProductImage, error)
+	GetAllProducts(ctx context.Context) ([]entity.ProductWithOutShop, error)
+	GetProductPrice(ctx context.Context, productID uint32) (float64, error)
+	GetVariantsByProductID(ctx context.Context, productID uint32) ([]entity.ProductVariant, error)
+	GetVariantByID(ctx context.Context, variantID uint32) (entity.ProductVariant, error)
+	HasVariants(ctx context.Context, productID uint32) (bool, error)
+	CreateVariant(ctx context.Context, productID uint32, req entity.VariantRequest) (entity.ProductVariant, error)
+	UpdateVariant(ctx context.Context, req *entity.VariantManagementRequest, variant entity.VariantRequest) error
+	DeleteVariant(ctx context.Context, req *entity.VariantManagementRequest) error
+	CreateProductImage(ctx context.Context, image *entity.ProductImage) error
+	CountProductImages(ctx context.Context, productID uint32) (int64, error)
+	GetImagesByProductIDs(ctx context.Context, productIDs []uint32) ([]entity.ProductImage, error)
+	DeleteProductImage(ctx context.Context, req *entity.ProductImageManagementRequest) (entity.ProductImage, error)
+	// GetProductPrices maps the ids of the shop's products to their price
+	GetProductPrices(ctx context.Context, shopID uint32, productIDs []uint32) (map[uint32]uint32, error)
+	GetProductIDsBySKUs(ctx context.Context, shopID uint32, skus []string) (map[string]uint32, error)
+	GetCategoryIDsBySlugs(ctx context.Context, slugs []string) (map[string]uint32, error)
+	ImportProducts(ctx context.Context, shopID uint32, products []entity.Product) error
+	ExportProducts(ctx context.Context, shopID uint32, fn func([]entity.Product) error) error
+	GetProductPageByShopID(ctx context.Context, shopID uint32, limit int, afterID uint32) ([]entity.ProductWithOutShop, error)
+	HasCompletedOrder(ctx context.Context, userID uint32, productID uint32) (bool, error)
+	CreateReview(ctx context.Context, review *entity.ProductReview) error
+	GetReviewsByProductID(ctx context.Context, productID uint32) ([]entity.ProductReview, error)
+	GetRatingCounts(ctx context.Context, productID uint32) ([]entity.RatingCount, error)
 }
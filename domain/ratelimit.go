@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"context"
+
+	"order-management/entity"
+)
+
+// RateLimitStore keeps the token buckets of middleware.RateLimit. The
+// in-memory store limits each instance on its own, a store shared by all
+// instances (e.g. Redis) enforces one limit across them.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy entity.RateLimitPolicy) (entity.RateLimitResult, error)
+}
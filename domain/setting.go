@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"context"
+
+	"order-management/entity"
+)
+
+type SettingRepository interface {
+	GetSetting(ctx context.Context, key string) (entity.Setting, error)
+	PutSetting(ctx context.Context, key string, value string) error
+}
+
+// MFAPolicy holds the admin's two-factor authentication requirements
+type MFAPolicy interface {
+	GetMFAPolicy(ctx context.Context) (entity.MFAPolicy, error)
+	SetMFAPolicy(ctx context.Context, policy entity.MFAPolicy) error
+}
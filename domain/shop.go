@@ -1,25 +1,100 @@
 package domain
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"order-management/entity"
 )
 
 type ShopUsecase interface {
-	CreateProduct(product entity.Product, shopID uint32) error
-	CreateShop(shop entity.Shop) error
-	GetAllShopsWithProducts() ([]entity.ShopWithProducts, error)
-	GetAllShops() ([]entity.Shop, error)
-	GetShopByName(name string) (entity.ShopWithProducts, error)
-	Login(name string, password string) (string, error)
-	GetProductsByShopID(id uint32) ([]entity.Product, error)
-	UpdateProduct(req *entity.ProductManagementRequest, product *entity.Product) error
-	DeleteProduct(req *entity.ProductManagementRequest) error
+	CreateProduct(ctx context.Context, product entity.Product, shopID uint32) error
+	CreateShop(ctx context.Context, shop entity.Shop) error
+	GetAllShopsWithProducts(ctx context.Context) ([]entity.ShopWithProducts, error)
+	GetAllShops(ctx context.Context) ([]entity.Shop, error)
+	GetShopByName(ctx context.Context, name string) (entity.ShopWithProducts, error)
+	Login(ctx context.Context, name string, password string, ip string) (entity.ShopLogin, error)
+	LoginMFA(ctx context.Context, req entity.MFALoginRequest, ip string) (entity.ShopLogin, error)
+	EnrolMFALogin(ctx context.Context, mfaToken string) (entity.MFAEnrolment, error)
+	GetProductsByShopID(ctx context.Context, id uint32) ([]entity.Product, error)
+	UpdateProduct(ctx context.Context, req *entity.ProductManagementRequest, product *entity.Product) error
+	DeleteProduct(ctx context.Context, req *entity.ProductManagementRequest) error
+	RestoreProduct(ctx context.Context, req *entity.ProductManagementRequest) error
+	GetDeletedProducts(ctx context.Context, shopID uint32) ([]entity.ProductWithOutShop, error)
+	CreateVariant(ctx context.Context, req *entity.ProductManagementRequest, variant entity.VariantRequest) (entity.VariantResponse, error)
+	UpdateVariant(ctx context.Context, req *entity.VariantManagementRequest, variant entity.VariantRequest) error
+	DeleteVariant(ctx context.Context, req *entity.VariantManagementRequest) error
+	UploadProductImage(ctx context.Context, req *entity.ProductManagementRequest, r io.Reader) (entity.ProductImageResponse, error)
+	DeleteProductImage(ctx context.Context, req *entity.ProductImageManagementRequest) error
+	ImportProducts(ctx context.Context, shopID uint32, format string, r io.Reader, dryRun bool) (entity.ProductImportResult, error)
+	ExportProducts(ctx context.Context, shopID uint32, w io.Writer) error
+	GetShopProfile(ctx context.Context, shopID uint32) (entity.ShopWithProducts, error)
+	VerifyToken(ctx context.Context, claims *entity.ShopJWT) (entity.MemberRole, error)
+	UpdateProfile(ctx context.Context, claims *entity.ShopJWT, req entity.ShopUpdateRequest) (entity.ShopProfile, string, error)
+	ChangePassword(ctx context.Context, claims *entity.ShopJWT, req entity.ShopPasswordRequest) (string, error)
+	Deactivate(ctx context.Context, shopID uint32, password string) error
+	Reactivate(ctx context.Context, shopID uint32) error
+	GetStorefront(ctx context.Context, slug string, limit int, cursor string) (entity.ShopStorefront, error)
+	GetMembers(ctx context.Context, shopID uint32) ([]entity.MemberResponse, error)
+	AddMember(ctx context.Context, shopID uint32, req entity.MemberRequest) (entity.MemberResponse, error)
+	UpdateMemberRole(ctx context.Context, req *entity.MemberManagementRequest, role entity.MemberRole) error
+	RemoveMember(ctx context.Context, req *entity.MemberManagementRequest) error
+	GetMemberships(ctx context.Context, userID uint32) ([]entity.MembershipResponse, error)
+	SwitchContext(ctx context.Context, user *entity.UserJWT, shopID uint32) (string, error)
+	LinkShop(ctx context.Context, userID uint32, req entity.LinkShopRequest, ip string) (entity.MembershipResponse, error)
+	CreateOwnedShop(ctx context.Context, userID uint32, req entity.ShopUpdateRequest) (entity.MembershipResponse, error)
+	GetFinances(ctx context.Context, shopID uint32) (entity.ShopFinances, error)
+	GetMFAStatus(ctx context.Context, shopID uint32) (entity.MFAStatus, error)
+	EnrolMFA(ctx context.Context, shopID uint32) (entity.MFAEnrolment, error)
+	ConfirmMFA(ctx context.Context, shopID uint32, code string) ([]string, error)
+	DisableMFA(ctx context.Context, shopID uint32, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, shopID uint32, code string) ([]string, error)
+	CreateAPIKey(ctx context.Context, claims *entity.ShopJWT, req entity.APIKeyRequest) (entity.APIKeyCreated, error)
+	GetAPIKeys(ctx context.Context, shopID uint32) ([]entity.APIKeyResponse, error)
+	RevokeAPIKey(ctx context.Context, req *entity.APIKeyManagementRequest) error
+	APIKeyAuthenticator
+}
+
+// APIKeyAuthenticator turns the key of an "Authorization: ApiKey" header
+// into the shop it acts for, see middleware.ShopAuth
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string, ip string) (*entity.ShopJWT, error)
 }
 
 type ShopRepository interface {
-	CreateShop(shop entity.Shop) error
-	GetAllShops() ([]entity.Shop, error)
-	GetShopByName(name string) (entity.ShopWithOutPassword, error)
-	GetShopByNameWithPassword(name string) (entity.Shop, error)
-	ShopExists(id uint32) (bool, error)
+	CreateShop(ctx context.Context, shop *entity.Shop) error
+	GetAllShops(ctx context.Context) ([]entity.Shop, error)
+	GetShopByName(ctx context.Context, name string) (entity.ShopWithOutPassword, error)
+	GetShopByNameWithPassword(ctx context.Context, name string) (entity.Shop, error)
+	ShopExists(ctx context.Context, id uint32) (bool, error)
+	GetShopByID(ctx context.Context, id uint32) (entity.Shop, error)
+	UpdateShopProfile(ctx context.Context, id uint32, req entity.ShopUpdateRequest) error
+	UpdateShopPassword(ctx context.Context, id uint32, hashedPassword string) (uint32, error)
+	SetShopDeactivated(ctx context.Context, id uint32, at *time.Time) error
+	GetShopBySlug(ctx context.Context, slug string) (entity.Shop, error)
+	GetSlugsLike(ctx context.Context, base string) ([]string, error)
+	GetShopStats(ctx context.Context, shopID uint32) (entity.ShopStats, error)
+	GetMembers(ctx context.Context, shopID uint32) ([]entity.ShopMember, error)
+	GetMember(ctx context.Context, req *entity.MemberManagementRequest) (entity.ShopMember, error)
+	GetMemberByUserID(ctx context.Context, shopID uint32, userID uint32) (entity.ShopMember, error)
+	GetMembershipsByUserID(ctx context.Context, userID uint32) ([]entity.ShopMember, error)
+	CreateMember(ctx context.Context, shopID uint32, req entity.MemberRequest) (entity.ShopMember, error)
+	UpdateMemberRole(ctx context.Context, req *entity.MemberManagementRequest, role entity.MemberRole) error
+	DeleteMember(ctx context.Context, req *entity.MemberManagementRequest) error
+	GetShopFinances(ctx context.Context, shopID uint32) (entity.ShopFinances, error)
+	UpsertOwner(ctx context.Context, shopID uint32, userID uint32) error
+	CreateShopWithOwner(ctx context.Context, shop *entity.Shop, userID uint32) error
+	SetShopTOTPSecret(ctx context.Context, id uint32, secret string) error
+	EnableShopTOTP(ctx context.Context, id uint32, step int64, codeHashes []string) error
+	DisableShopTOTP(ctx context.Context, id uint32) error
+	UseShopTOTPStep(ctx context.Context, id uint32, step int64) error
+	ReplaceShopRecoveryCodes(ctx context.Context, id uint32, codeHashes []string) error
+	UseShopRecoveryCode(ctx context.Context, id uint32, codeHash string) error
+	CountShopRecoveryCodes(ctx context.Context, id uint32) (int64, error)
+	CreateAPIKey(ctx context.Context, key *entity.ShopAPIKey) error
+	GetAPIKeys(ctx context.Context, shopID uint32) ([]entity.ShopAPIKey, error)
+	RevokeAPIKey(ctx context.Context, req *entity.APIKeyManagementRequest) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (entity.ShopAPIKey, error)
+	TouchAPIKey(ctx context.Context, id uint32, ip string, at time.Time) error
 }
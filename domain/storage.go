@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStore keeps uploaded files such as product images. Keys are slash
+// separated paths chosen by the caller, implementations decide where the
+// bytes live and how they are reached over HTTP.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
@@ -1,18 +1,33 @@
 package domain
 
-import "order-management/entity"
+import (
+	"context"
+	"time"
+
+	"order-management/entity"
+)
 
 type UserUsecase interface {
-	CreateUser(user entity.User) error
-	UpdateUser(user entity.UserWithOutPassword) error
-	Login(email string, password string) (string, error)
-	GetUserByID(id uint32) (entity.UserWithOutPassword, error)
+	CreateUser(ctx context.Context, user entity.User) error
+	CreateAdmin(ctx context.Context, user entity.User) error
+	UpdateUser(ctx context.Context, user entity.UserWithOutPassword) error
+	Login(ctx context.Context, email string, password string, ip string) (string, error)
+	GetUserByID(ctx context.Context, id uint32) (entity.UserWithOutPassword, error)
+	SendVerificationEmail(ctx context.Context, userID uint32) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequireVerifiedEmail(ctx context.Context, userID uint32) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+	UnlockLogin(ctx context.Context, token string) error
 }
 
 type UserRepository interface {
-	CreateUser(user entity.User) error
-	GetUserByID(id uint32) (entity.UserWithOutPassword, error)
-	GetUserWithPasswordByEmail(email string) (entity.User, error)
-	UpdateUser(user entity.UserWithOutPassword) error
-	GetUserByEmail(email string) (entity.UserWithOutPassword, error)
+	CreateUser(ctx context.Context, user entity.User) error
+	GetUserByID(ctx context.Context, id uint32) (entity.UserWithOutPassword, error)
+	GetUserWithPasswordByEmail(ctx context.Context, email string) (entity.User, error)
+	UpdateUser(ctx context.Context, user entity.UserWithOutPassword) error
+	GetUserByEmail(ctx context.Context, email string) (entity.UserWithOutPassword, error)
+	GetUserWithPasswordByID(ctx context.Context, id uint32) (entity.User, error)
+	SetEmailVerified(ctx context.Context, id uint32, at time.Time) error
+	UpdatePassword(ctx context.Context, id uint32, current string, password string) error
 }
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"order-management/entity"
+)
+
+type WebhookUsecase interface {
+	CreateWebhook(ctx context.Context, shopID uint32, req entity.WebhookRequest) (entity.WebhookCreated, error)
+	GetWebhooks(ctx context.Context, shopID uint32) ([]entity.WebhookResponse, error)
+	UpdateWebhook(ctx context.Context, req *entity.WebhookManagementRequest, body entity.WebhookRequest) (entity.WebhookResponse, error)
+	DeleteWebhook(ctx context.Context, req *entity.WebhookManagementRequest) error
+	GetDeliveries(ctx context.Context, req *entity.WebhookManagementRequest, filter entity.WebhookDeliveryFilter) ([]entity.WebhookDeliveryResponse, error)
+	Redeliver(ctx context.Context, req *entity.WebhookManagementRequest, deliveryID uint64) error
+	// HandleEvent is the event bus subscriber that turns order and product
+	// events into deliveries
+	HandleEvent(ctx context.Context, event entity.DomainEvent) error
+	// DeliverDue sends the deliveries that are due and returns how many
+	DeliverDue(ctx context.Context) (int, error)
+	PurgeWebhookEvents(ctx context.Context) (int64, error)
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *entity.Webhook) error
+	GetWebhooks(ctx context.Context, shopID uint32) ([]entity.Webhook, error)
+	GetWebhook(ctx context.Context, req *entity.WebhookManagementRequest) (entity.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *entity.Webhook) error
+	DeleteWebhook(ctx context.Context, req *entity.WebhookManagementRequest) error
+	GetDeliveries(ctx context.Context, webhookID uint32, filter entity.WebhookDeliveryFilter) ([]entity.WebhookDelivery, error)
+	ResetDelivery(ctx context.Context, webhookID uint32, deliveryID uint64) error
+	AddEvents(ctx context.Context, events []entity.WebhookEvent) error
+	ClaimDueDeliveries(ctx context.Context, lease time.Duration, limit int) ([]entity.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *entity.WebhookDelivery) error
+	PurgeWebhookEvents(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// Category is a node of the admin managed category tree, a product belongs
+// to at most one category
+type Category struct {
+	ID        uint32    `gorm:"primary_key" json:"id"`
+	ParentID  *uint32   `gorm:"index" json:"parentId,omitempty"`
+	Parent    *Category `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT" json:"-"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"not null;unique" json:"slug"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// Tag is a free form label shops put on their products, tags are shared
+// between shops and created on first use
+type Tag struct {
+	ID   uint32 `gorm:"primary_key" json:"id"`
+	Name string `gorm:"not null" json:"name"`
+	Slug string `gorm:"not null;unique" json:"slug"`
+}
+
+type CategoryRequest struct {
+	Name     string  `json:"name"`
+	Slug     string  `json:"slug"`
+	ParentID *uint32 `json:"parentId"`
+}
+
+// CategoryResponse carries the number of live products in the category and
+// all of its descendants, for faceted navigation
+type CategoryResponse struct {
+	ID           uint32  `json:"id"`
+	ParentID     *uint32 `json:"parentId,omitempty"`
+	Name         string  `json:"name"`
+	Slug         string  `json:"slug"`
+	ProductCount int64   `json:"productCount"`
+}
+
+type CategoryProducts struct {
+	Category CategoryResponse     `json:"category"`
+	Children []CategoryResponse   `json:"children"`
+	Products []ProductWithOutShop `json:"products"`
+}
+
+type CategoryProductCount struct {
+	CategoryID uint32
+	Count      int64
+}
@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventOrderPlaced         EventType = "order.placed"
+	EventOrderStatusChanged  EventType = "order.status_changed"
+	EventProductPriceChanged EventType = "product.price_changed"
+	EventProductUpdated      EventType = "product.updated"
+	EventShopRegistered      EventType = "shop.registered"
+)
+
+// DomainEvent is the outbox of the event bus. Usecases publish an event in
+// the transaction of the change it reports, the bus hands it to every
+// subscriber of its type and sets DispatchedAt. AggregateID is the order,
+// product or shop the event is about, Payload the event's struct below as
+// JSON.
+type DomainEvent struct {
+	ID           uint64     `gorm:"primary_key"`
+	Type         EventType  `gorm:"type:varchar(50);not null"`
+	AggregateID  uint32     `gorm:"not null"`
+	Payload      string     `gorm:"type:jsonb;not null"`
+	CreatedAt    time.Time  `gorm:"index"`
+	DispatchedAt *time.Time `gorm:"index"`
+}
+
+// Decode reads the payload into v, one of the event structs below
+func (e DomainEvent) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(e.Payload), v)
+}
+
+// EventDelivery is one event for one subscriber. Subscribers are named, so
+// deliveries survive restarts and a failing subscriber is retried on its
+// own. Delivery is at least once, subscribers must tolerate an event
+// twice. Status goes through the same states as a webhook delivery.
+type EventDelivery struct {
+	ID            uint64                `gorm:"primary_key"`
+	EventID       uint64                `gorm:"not null;uniqueIndex:idx_event_delivery_subscriber"`
+	Event         DomainEvent           `gorm:"foreignKey:EventID;constraint:OnDelete:CASCADE"`
+	Subscriber    string                `gorm:"size:100;not null;uniqueIndex:idx_event_delivery_subscriber"`
+	Status        WebhookDeliveryStatus `gorm:"type:varchar(20);not null;index:idx_event_delivery_due,priority:1"`
+	Attempts      int                   `gorm:"not null;default:0"`
+	NextAttemptAt time.Time             `gorm:"not null;index:idx_event_delivery_due,priority:2"`
+	LastError     string
+	LastAttemptAt *time.Time
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+}
+
+type OrderPlacedEvent struct {
+	OrderID uint32      `json:"orderId"`
+	UserID  uint32      `json:"userId"`
+	Total   float32     `json:"total"`
+	Courier string      `json:"courier"`
+	Lines   []OrderLine `json:"lines"`
+}
+
+type OrderLine struct {
+	ProductID uint32 `json:"productId"`
+	VariantID uint32 `json:"variantId,omitempty"`
+	Amount    uint32 `json:"amount"`
+	Price     uint32 `json:"price"`
+}
+
+type OrderStatusChangedEvent struct {
+	OrderID uint32 `json:"orderId"`
+	UserID  uint32 `json:"userId"`
+	// ShopID is the shop that changed the status
+	ShopID uint32 `json:"shopId"`
+	From   Status `json:"from"`
+	To     Status `json:"to"`
+}
+
+// ProductPriceChangedEvent is sent for the product's own price and for each of
+// its variants, VariantID is zero for the former
+type ProductPriceChangedEvent struct {
+	ProductID uint32 `json:"productId"`
+	VariantID uint32 `json:"variantId,omitempty"`
+	ShopID    uint32 `json:"shopId"`
+	OldPrice  uint32 `json:"oldPrice"`
+	NewPrice  uint32 `json:"newPrice"`
+}
+
+// ProductUpdatedEvent is sent after changes to the product or its variants
+type ProductUpdatedEvent struct {
+	ProductID uint32 `json:"productId"`
+	ShopID    uint32 `json:"shopId"`
+}
+
+type ShopRegisteredEvent struct {
+	ShopID uint32 `json:"shopId"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	// OwnerID is the account that created the shop, zero for shops
+	// registered with a password
+	OwnerID uint32 `json:"ownerId,omitempty"`
+}
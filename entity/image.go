@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// ProductImage is an uploaded product picture, the bytes live in the blob
+// store under Key
+type ProductImage struct {
+	ID          uint32                  `gorm:"primary_key"`
+	ProductID   uint32                  `gorm:"not null;index"`
+	Key         string                  `gorm:"not null"`
+	ContentType string                  `gorm:"not null"`
+	Width       int                     `gorm:"not null"`
+	Height      int                     `gorm:"not null"`
+	Thumbnails  []ProductImageThumbnail `gorm:"foreignKey:ImageID;constraint:OnDelete:CASCADE"`
+	// Purging a product removes its image rows with it
+	Product   Product `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+}
+
+// ProductImageThumbnail is a downscaled copy of a product image
+type ProductImageThumbnail struct {
+	ID      uint32 `gorm:"primary_key"`
+	ImageID uint32 `gorm:"not null;uniqueIndex:idx_thumbnail_image_size"`
+	Size    string `gorm:"not null;uniqueIndex:idx_thumbnail_image_size"`
+	Key     string `gorm:"not null"`
+	Width   int    `gorm:"not null"`
+	Height  int    `gorm:"not null"`
+}
+
+type ProductImageResponse struct {
+	ID         uint32            `json:"id"`
+	URL        string            `json:"url"`
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+type ProductImageManagementRequest struct {
+	ShopID    uint32 `json:"shop_id"`
+	ProductID uint32 `json:"product_id"`
+	ImageID   uint32 `json:"image_id"`
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// LoginAttempt tracks failed logins for one key, an account or a client IP.
+// While BlockedUntil is in the future logins for the key are refused without
+// checking the password. Locked marks a lockout after too many failures as
+// opposed to the short backoff before it.
+type LoginAttempt struct {
+	Key           string     `gorm:"primaryKey;size:320" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null;index" json:"lastFailureAt"`
+	BlockedUntil  *time.Time `json:"blockedUntil"`
+	Locked        bool       `gorm:"not null;default:false" json:"locked"`
+}
+
+const (
+	UserLoginKeyPrefix = "user:"
+	ShopLoginKeyPrefix = "shop:"
+	IPLoginKeyPrefix   = "ip:"
+)
+
+// UserLoginKey is lowercased so case variations of an address share one
+// counter
+func UserLoginKey(email string) string {
+	return UserLoginKeyPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ShopLoginKey(name string) string {
+	return ShopLoginKeyPrefix + name
+}
+
+func IPLoginKey(ip string) string {
+	return IPLoginKeyPrefix + ip
+}
+
+// LoginLockedError is returned while a login is refused because of earlier
+// failures. RetryAfter is how long until the next attempt is accepted.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return "too many failed logins"
+}
+
+type UnlockLoginRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,9 @@
+package entity
+
+// Email is a plain text message to a single recipient, the sender is
+// configured on the mailer
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+}
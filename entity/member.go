@@ -0,0 +1,93 @@
+package entity
+
+import "time"
+
+type MemberRole string
+
+const (
+	OWNER   MemberRole = "OWNER"
+	MANAGER MemberRole = "MANAGER"
+	PACKER  MemberRole = "PACKER"
+)
+
+type Permission string
+
+const (
+	ManageProducts Permission = "manage_products"
+	FulfilOrders   Permission = "fulfil_orders"
+	ViewFinances   Permission = "view_finances"
+	ManageMembers  Permission = "manage_members"
+	ManageShop     Permission = "manage_shop"
+)
+
+var rolePermissions = map[MemberRole][]Permission{
+	OWNER:   {ManageProducts, FulfilOrders, ViewFinances, ManageMembers, ManageShop},
+	MANAGER: {ManageProducts, FulfilOrders, ViewFinances},
+	PACKER:  {FulfilOrders},
+}
+
+func (r MemberRole) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r MemberRole) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r MemberRole) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// ShopMember lets a user act for a shop with the permissions of its role.
+// Logging in with the shop's own name and password acts as OWNER.
+type ShopMember struct {
+	ID        uint32     `gorm:"primary_key"`
+	ShopID    uint32     `gorm:"not null;uniqueIndex:idx_shop_member"`
+	UserID    uint32     `gorm:"not null;uniqueIndex:idx_shop_member;index"`
+	Role      MemberRole `gorm:"type:varchar(20);not null"`
+	Shop      Shop       `gorm:"foreignKey:ShopID;constraint:OnDelete:CASCADE"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type MemberRequest struct {
+	Email string     `json:"email"`
+	Role  MemberRole `json:"role"`
+}
+
+type MemberResponse struct {
+	ID          uint32       `json:"id"`
+	UserID      uint32       `json:"userId"`
+	Email       string       `json:"email"`
+	Role        MemberRole   `json:"role"`
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
+
+type MembershipResponse struct {
+	ShopID      uint32       `json:"shopId"`
+	ShopName    string       `json:"shopName"`
+	ShopSlug    string       `json:"shopSlug"`
+	Role        MemberRole   `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type MemberManagementRequest struct {
+	ShopID   uint32
+	MemberID uint32
+}
+
+// ShopFinances sums the order lines of the shop's products, Revenue over
+// completed orders and PendingRevenue over pending and shipping ones.
+type ShopFinances struct {
+	CompletedOrders int64 `json:"completedOrders"`
+	Revenue         int64 `json:"revenue"`
+	PendingRevenue  int64 `json:"pendingRevenue"`
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ShopRecoveryCode signs a shop in once when its authenticator is lost. Only
+// the SHA-256 hash of the code is stored.
+type ShopRecoveryCode struct {
+	ID        uint32 `gorm:"primary_key"`
+	ShopID    uint32 `gorm:"not null;index"`
+	Shop      Shop   `gorm:"foreignKey:ShopID;constraint:OnDelete:CASCADE"`
+	CodeHash  string `gorm:"size:64;not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// ShopMFAJWT is the short lived token of a shop login waiting for its second
+// factor. Version is the shop's token version, so a password change also
+// revokes pending logins.
+type ShopMFAJWT struct {
+	ID      uint32 `json:"id"`
+	Version uint32 `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+func (c *ShopMFAJWT) Validate() error {
+	if c.ID == 0 {
+		return errors.New("[ShopMFAJWT.Validate]: missing id claim")
+	}
+	return nil
+}
+
+// ShopLogin is the outcome of a login step. Token is the shop token and
+// goes out in the Authorization header. Without it MFAToken has to be sent
+// back with a code, after enrolling first unless MFAEnrolled. RecoveryCodes
+// are only set when the login completed an enrolment.
+type ShopLogin struct {
+	Token         string   `json:"-"`
+	MFAToken      string   `json:"mfaToken,omitempty"`
+	MFAEnrolled   bool     `json:"mfaEnrolled"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+// MFACodeRequest carries an authenticator code, or a recovery code where
+// those are accepted
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFAEnrolment is shown once when enrolling, URI is the otpauth:// URI to
+// render as QR code and Secret the same for typing it in
+type MFAEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabledAt"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int64      `json:"recoveryCodesLeft"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFAPolicy is set by admins through PUT /auth/mfa-policy
+type MFAPolicy struct {
+	RequireShops bool `json:"requireShops"`
+}
@@ -0,0 +1,97 @@
+package entity
+
+import "time"
+
+type NotificationType string
+
+const (
+	NotificationOrderStatus NotificationType = "order.status_changed"
+)
+
+// NotificationTypes are the types a user can set preferences for
+var NotificationTypes = []NotificationType{NotificationOrderStatus}
+
+func (t NotificationType) Valid() bool {
+	for _, notificationType := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification is an entry of a user's in-app feed. EventID is the domain
+// event it was made from, so an event handled twice adds one entry.
+// EmailedAt is set once the email about it went out. Hidden entries were
+// only emailed, they are kept to send the email once and left out of the
+// feed.
+type Notification struct {
+	ID        uint64           `gorm:"primary_key;index:idx_notification_feed,priority:2"`
+	UserID    uint32           `gorm:"not null;uniqueIndex:idx_notification_event,priority:1;index:idx_notification_feed,priority:1"`
+	User      User             `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	EventID   uint64           `gorm:"not null;uniqueIndex:idx_notification_event,priority:2"`
+	Type      NotificationType `gorm:"type:varchar(50);not null"`
+	OrderID   uint32
+	Title     string `gorm:"size:200;not null"`
+	Body      string `gorm:"not null"`
+	ReadAt    *time.Time
+	EmailedAt *time.Time
+	Hidden    bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+}
+
+// NotificationPreference is what a user receives of one type. Users
+// without a row get both, see DefaultNotificationPreference.
+type NotificationPreference struct {
+	UserID    uint32           `gorm:"primaryKey;autoIncrement:false"`
+	User      User             `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Type      NotificationType `gorm:"primaryKey;type:varchar(50)"`
+	Email     bool             `gorm:"not null"`
+	InApp     bool             `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func DefaultNotificationPreference(userID uint32, notificationType NotificationType) NotificationPreference {
+	return NotificationPreference{
+		UserID: userID,
+		Type:   notificationType,
+		Email:  true,
+		InApp:  true,
+	}
+}
+
+type NotificationResponse struct {
+	ID        uint64           `json:"id"`
+	Type      NotificationType `json:"type"`
+	OrderID   uint32           `json:"orderId,omitempty"`
+	Title     string           `json:"title"`
+	Body      string           `json:"body"`
+	Read      bool             `json:"read"`
+	ReadAt    *time.Time       `json:"readAt"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+type NotificationFeed struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	// Unread counts the unread notifications of the user, not only those
+	// on this page
+	Unread int64 `json:"unread"`
+}
+
+// NotificationFilter narrows the feed. Before is a notification id to page
+// back from, Limit 0 means the default.
+type NotificationFilter struct {
+	Unread bool
+	Before uint64
+	Limit  int
+}
+
+type NotificationPreferenceItem struct {
+	Type  NotificationType `json:"type"`
+	Email bool             `json:"email"`
+	InApp bool             `json:"inApp"`
+}
+
+type NotificationPreferencesRequest struct {
+	Preferences []NotificationPreferenceItem `json:"preferences"`
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type Order struct {
 	ID            uint32 `gorm:"primary_key"`
 	Status        Status `gorm:"type:varchar(20)"`
@@ -9,6 +11,8 @@ type Order struct {
 	User          User           `gorm:"foreignKey:UserID"`
 	Products      []Product      `gorm:"many2many:order_products;"`
 	OrderProducts []OrderProduct `gorm:"foreignKey:OrderID"`
+	CreatedAt     time.Time      `gorm:"index"`
+	UpdatedAt     time.Time
 }
 
 type Status string
@@ -22,11 +26,15 @@ const (
 
 // OrderProduct represents the join table between Order and Product with additional fields
 type OrderProduct struct {
-	OrderID   uint32  `gorm:"primaryKey"`
-	ProductID uint32  `gorm:"primaryKey"`
-	Amount    uint32  `gorm:"not null"` // Amount of products in the order
-	Order     Order   `gorm:"foreignKey:OrderID"`
-	Product   Product `gorm:"foreignKey:ProductID"`
+	OrderID   uint32 `gorm:"primaryKey"`
+	ProductID uint32 `gorm:"primaryKey"`
+	// Zero for products without variants, so no foreign key constraint
+	VariantID uint32         `gorm:"primaryKey;default:0"`
+	Amount    uint32         `gorm:"not null"` // Amount of products in the order
+	Price     uint32         // Unit price at the time of ordering
+	Order     Order          `gorm:"foreignKey:OrderID"`
+	Product   Product        `gorm:"foreignKey:ProductID"`
+	Variant   ProductVariant `gorm:"foreignKey:VariantID;constraint:-"`
 }
 
 type OrderRequest struct {
@@ -36,15 +44,45 @@ type OrderRequest struct {
 
 type OrderProductRequest struct {
 	ProductId uint32 `json:"productId"`
+	VariantId uint32 `json:"variantId"`
 	Amount    uint32 `json:"amount"`
 }
 
 type OrderResponse struct {
-	ID       uint32               `json:"id"`
-	Status   Status               `json:"status"`
-	Total    float32              `json:"total"`
-	Courier  string               `json:"courier"`
-	Products []ProductOrderAmount `json:"products"`
+	ID        uint32               `json:"id"`
+	Status    Status               `json:"status"`
+	Total     float32              `json:"total"`
+	Courier   string               `json:"courier"`
+	Products  []ProductOrderAmount `json:"products"`
+	CreatedAt time.Time            `json:"createdAt"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+}
+
+type OrderStatusRequest struct {
+	Status Status `json:"status"`
+}
+
+// NextStatuses are the statuses an order can move on to from each status
+var NextStatuses = map[Status][]Status{
+	PENDING:  {SHIPPING, CANCELLED},
+	SHIPPING: {COMPLETED},
+}
+
+func (s Status) Valid() bool {
+	switch s {
+	case PENDING, SHIPPING, CANCELLED, COMPLETED:
+		return true
+	}
+	return false
+}
+
+func (s Status) CanMoveTo(next Status) bool {
+	for _, status := range NextStatuses[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
 }
 
 type OrderInfo struct {
@@ -58,3 +96,39 @@ type OrderProductInfo struct {
 	OrderInfo
 	Products []ProductWithOutShop `json:"products"`
 }
+
+type OrderSort string
+
+const (
+	SortByCreatedAt OrderSort = "created_at"
+	SortByTotal     OrderSort = "total"
+)
+
+// OrderFilter narrows an order listing. Cursor is the opaque value returned
+// as OrderPage.NextCursor, After is its decoded form used by the repository.
+type OrderFilter struct {
+	Status Status
+	From   *time.Time
+	To     *time.Time
+	SortBy OrderSort
+	Desc   bool
+	Limit  int
+	Cursor string
+	After  *OrderCursor
+}
+
+// OrderCursor is the keyset position of the last order of a page: the value
+// of the sort column plus the id as tie breaker. SortBy and Desc are the
+// ordering it was made for, it is no position in any other.
+type OrderCursor struct {
+	SortBy    OrderSort `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Total     float32   `json:"t,omitempty"`
+	ID        uint32    `json:"i"`
+}
+
+type OrderPage struct {
+	Orders     []OrderResponse `json:"orders"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
@@ -1,32 +1,81 @@
 package entity
 
+import "gorm.io/gorm"
+
 type Product struct {
 	ID            uint32 `gorm:"primary_key"`
 	Name          string
 	Description   string
 	Price         uint32
-	ShopID        uint32
+	ShopID        uint32         `gorm:"uniqueIndex:idx_product_shop_sku,where:deleted_at IS NULL"`
 	Shop          Shop           `gorm:"foreignKey:ShopID"`
 	Orders        []Order        `gorm:"many2many:order_products;"`
 	OrderProducts []OrderProduct `gorm:"foreignKey:ProductID"`
+	CategoryID    *uint32        `gorm:"index"`
+	Category      *Category      `gorm:"foreignKey:CategoryID;constraint:OnDelete:SET NULL"`
+	Tags          []Tag          `gorm:"many2many:product_tags;" json:"-"`
+	// TagNames is how tags are sent and returned, a nil slice on update
+	// keeps the current tags
+	TagNames []string `gorm:"-" json:"Tags"`
+	// SKU is optional, when set it is unique within the shop and is what
+	// bulk imports match products on
+	SKU *string `gorm:"uniqueIndex:idx_product_shop_sku,where:deleted_at IS NULL"`
+	// Deleted products stay in the table so past orders can still show them
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type ProductWithOutShop struct {
-	ID          uint32 `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Price       uint32 `json:"price"`
+	ID          uint32                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Price       uint32                 `json:"price"`
+	Images      []ProductImageResponse `gorm:"-" json:"images"`
 }
 
 type ProductOrderAmount struct {
-	ID          uint32 `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Price       uint32 `json:"price"`
-	Amount      uint32 `json:"amount"`
+	ID          uint32          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Price       uint32          `json:"price"`
+	Amount      uint32          `json:"amount"`
+	VariantID   uint32          `json:"variantId,omitempty"`
+	SKU         string          `json:"sku,omitempty"`
+	Options     []VariantOption `json:"options,omitempty"`
 }
 
 type ProductManagementRequest struct {
 	ShopID    uint32 `json:"shop_id"`
 	ProductID uint32 `json:"product_id"`
 }
+
+// Bulk import and export file formats
+const (
+	ProductFileCSV   = "csv"
+	ProductFileJSONL = "jsonl"
+)
+
+// ProductImportRow is one parsed line of a bulk import file
+type ProductImportRow struct {
+	Line        int      `json:"-"`
+	SKU         string   `json:"sku"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       *uint32  `json:"price"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+}
+
+type ProductImportError struct {
+	Line  int    `json:"line"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// ProductImportResult reports what an import did, or would do on a dry run.
+// Nothing is written when Errors is not empty.
+type ProductImportResult struct {
+	DryRun  bool                 `json:"dryRun"`
+	Created int                  `json:"created"`
+	Updated int                  `json:"updated"`
+	Errors  []ProductImportError `json:"errors"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// RateLimitPolicy is a token bucket holding Limit requests that refills
+// completely over Window. A policy applies to the routes listed as
+// "METHOD /path" with the path as registered in echo, otherwise to routes
+// below Prefix. The policy named default covers everything else.
+type RateLimitPolicy struct {
+	Name   string        `mapstructure:"name"`
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+	Routes []string      `mapstructure:"routes"`
+	Prefix string        `mapstructure:"prefix"`
+}
+
+// RateLimitResult is the state of a bucket after taking a request from it.
+// Reset is how long until the bucket is full again, RetryAfter how long
+// until the next request is allowed when this one was not.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
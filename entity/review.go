@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+// ProductReview can only be left by users with a completed order containing
+// the product, once per product.
+type ProductReview struct {
+	ID        uint32 `gorm:"primary_key"`
+	ProductID uint32 `gorm:"not null;uniqueIndex:idx_review_product_user"`
+	UserID    uint32 `gorm:"not null;uniqueIndex:idx_review_product_user"`
+	Rating    uint8  `gorm:"not null"`
+	Comment   string
+	Product   Product `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"`
+	User      User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ReviewRequest struct {
+	Rating  uint8  `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+type ReviewResponse struct {
+	ID        uint32    `json:"id"`
+	UserID    uint32    `json:"userId"`
+	Rating    uint8     `json:"rating"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RatingSummary aggregates reviews, Stars counts the reviews per rating from
+// 1 to 5.
+type RatingSummary struct {
+	Average float64         `json:"average"`
+	Count   int64           `json:"count"`
+	Stars   map[uint8]int64 `json:"stars"`
+}
+
+type RatingCount struct {
+	Rating uint8
+	Count  int64
+}
+
+type ProductReviews struct {
+	Rating  RatingSummary    `json:"rating"`
+	Reviews []ReviewResponse `json:"reviews"`
+}
+
+// NewRatingSummary builds the summary from the review count per rating.
+func NewRatingSummary(counts []RatingCount) RatingSummary {
+	summary := RatingSummary{Stars: map[uint8]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	var total int64
+	for _, c := range counts {
+		summary.Stars[c.Rating] += c.Count
+		summary.Count += c.Count
+		total += int64(c.Rating) * c.Count
+	}
+	if summary.Count > 0 {
+		summary.Average = math.Round(float64(total)/float64(summary.Count)*100) / 100
+	}
+	return summary
+}
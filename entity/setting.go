@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// Setting is a value admins change at runtime, as opposed to the config file
+type Setting struct {
+	Key       string `gorm:"primaryKey;size:100"`
+	Value     string `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+const SettingShopMFARequired = "shop.mfa.required"
@@ -1,18 +1,37 @@
 package entity
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type Shop struct {
-	ID          uint32 `gorm:"primary_key"`
-	Name        string `gorm:"not null;unique"`
+	ID   uint32 `gorm:"primary_key"`
+	Name string `gorm:"not null;unique"`
+	// Slug is derived from the name at registration and kept on renames so
+	// storefront URLs stay stable
+	Slug        string `gorm:"uniqueIndex"`
 	Description string
 	Password    string    `gorm:"not null"`
 	Products    []Product `gorm:"foreignKey:ShopID"`
+	// TokenVersion is embedded in every shop JWT, bumping it revokes all
+	// tokens issued before
+	TokenVersion  uint32 `gorm:"not null;default:0" json:"-"`
+	DeactivatedAt *time.Time
+	// TOTPSecret is the authenticator secret, stored on enrolment and in use
+	// once TOTPEnabledAt is set. TOTPLastStep is the time step of the last
+	// accepted code, so every code is accepted once.
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"-"`
+	TOTPLastStep  int64      `gorm:"not null;default:0" json:"-"`
 }
 
 type ShopWithOutPassword struct {
 	ID          uint32 `json:"id"`
 	Name        string `json:"name"`
+	Slug        string `json:"slug"`
 	Description string `json:"description"`
 }
 
@@ -20,12 +39,93 @@ type ShopJWT struct {
 	ID          uint32 `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Version     uint32 `json:"ver"`
+	// UserID is set when a member logged in with their user account, Role is
+	// OWNER for the shop's own credentials
+	UserID uint32     `json:"member,omitempty"`
+	Role   MemberRole `json:"role"`
+	// APIKey is set instead of a token when the request authenticated with
+	// one of the shop's API keys, whose scopes then limit Role
+	APIKey *ShopAPIKey `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// Validate is called by jwt after the registered claims checked out, so a
+// token missing the claims the handlers rely on is rejected while parsing
+func (c *ShopJWT) Validate() error {
+	if c.ID == 0 {
+		return errors.New("[ShopJWT.Validate]: missing id claim")
+	}
+	if c.Name == "" {
+		return errors.New("[ShopJWT.Validate]: missing name claim")
+	}
+	if !c.Role.Valid() {
+		return errors.New("[ShopJWT.Validate]: invalid role claim")
+	}
+	return nil
+}
+
+type ShopUpdateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type ShopPasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type ShopDeactivateRequest struct {
+	Password string `json:"password"`
+}
+
+type ShopProfile struct {
+	ID            uint32     `json:"id"`
+	Name          string     `json:"name"`
+	Slug          string     `json:"slug"`
+	Description   string     `json:"description"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+}
+
 type ShopWithProducts struct {
 	ID          uint32               `json:"id"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
 	Products    []ProductWithOutShop `json:"products"`
 }
+
+// ReservedShopSlugs are path segments under /shops that a storefront slug
+// would otherwise shadow.
+var ReservedShopSlugs = map[string]bool{
+	"me":          true,
+	"profile":     true,
+	"orders":      true,
+	"products":    true,
+	"register":    true,
+	"login":       true,
+	"logout":      true,
+	"members":     true,
+	"memberships": true,
+	"finances":    true,
+	"webhooks":    true,
+}
+
+type ShopStats struct {
+	ProductCount    int64
+	CompletedOrders int64
+	Rating          RatingSummary
+}
+
+// ShopStorefront is the public page of a shop, Products holds one page of
+// its catalog and NextCursor fetches the next one.
+type ShopStorefront struct {
+	ID              uint32               `json:"id"`
+	Name            string               `json:"name"`
+	Slug            string               `json:"slug"`
+	Description     string               `json:"description"`
+	ProductCount    int64                `json:"productCount"`
+	CompletedOrders int64                `json:"completedOrders"`
+	Rating          RatingSummary        `json:"rating"`
+	Products        []ProductWithOutShop `json:"products"`
+	NextCursor      string               `json:"nextCursor,omitempty"`
+}
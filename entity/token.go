@@ -0,0 +1,28 @@
+package entity
+
+// Audiences of the tokens this API issues. User tokens are the account
+// tokens of POST /users/login and context switches, shop tokens those of a
+// shop's own credentials. Shop MFA tokens only let a shop whose password
+// checked out finish the login with its second factor.
+const (
+	UserTokenAudience    = "users"
+	ShopTokenAudience    = "shops"
+	ShopMFATokenAudience = "shops-mfa"
+)
+
+// JWK is a public signing key as published at /.well-known/jwks.json
+// (RFC 7517). RSA keys fill N and E, Ed25519 keys Crv and X.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
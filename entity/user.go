@@ -1,24 +1,97 @@
 package entity
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type User struct {
 	ID       uint32 `gorm:"primary_key"`
 	Email    string `gorm:"unique;not null"`
 	Address  string
 	Password string  `gorm:"not null"`
+	Role     Role    `gorm:"type:varchar(20);not null;default:CUSTOMER"`
 	Orders   []Order `gorm:"foreignKey:UserID"`
+	// EmailVerifiedAt is set once the user follows the link from the
+	// verification email
+	EmailVerifiedAt *time.Time
 }
 
+type Role string
+
+const (
+	CUSTOMER Role = "CUSTOMER"
+	ADMIN    Role = "ADMIN"
+)
+
 type UserWithOutPassword struct {
-	ID      uint32 `json:"id"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
+	ID              uint32     `json:"id"`
+	Email           string     `json:"email"`
+	Address         string     `json:"address"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
 }
 
+// UserJWT is the account token. ShopID is the active shop context, set after
+// switching into a shop the user is a member of and zero when acting as a
+// buyer.
 type UserJWT struct {
-	ID      uint32 `json:"id"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
+	ID       uint32     `json:"id"`
+	Email    string     `json:"email"`
+	Address  string     `json:"address"`
+	Role     Role       `json:"role"`
+	ShopID   uint32     `json:"shop,omitempty"`
+	ShopName string     `json:"shopName,omitempty"`
+	ShopRole MemberRole `json:"shopRole,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// Validate is called by jwt after the registered claims checked out, so a
+// token missing the claims the handlers rely on is rejected while parsing
+func (c *UserJWT) Validate() error {
+	if c.ID == 0 {
+		return errors.New("[UserJWT.Validate]: missing id claim")
+	}
+	if c.Email == "" {
+		return errors.New("[UserJWT.Validate]: missing email claim")
+	}
+	if c.Role != CUSTOMER && c.Role != ADMIN {
+		return errors.New("[UserJWT.Validate]: invalid role claim")
+	}
+	if c.ShopID == 0 {
+		if c.ShopName != "" || c.ShopRole != "" {
+			return errors.New("[UserJWT.Validate]: shop claims without shop")
+		}
+		return nil
+	}
+	if !c.ShopRole.Valid() {
+		return errors.New("[UserJWT.Validate]: invalid shopRole claim")
+	}
+	return nil
+}
+
+type SwitchContextRequest struct {
+	ShopID uint32 `json:"shopId"`
+}
+
+// LinkShopRequest needs Code, an authenticator or recovery code, when the
+// shop has two-factor authentication enabled
+type LinkShopRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
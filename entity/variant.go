@@ -0,0 +1,70 @@
+package entity
+
+import "gorm.io/gorm"
+
+// ProductOptionType is a dimension a product varies on, e.g. "Size"
+type ProductOptionType struct {
+	ID        uint32               `gorm:"primary_key"`
+	ProductID uint32               `gorm:"not null;uniqueIndex:idx_option_type_product_name"`
+	Name      string               `gorm:"not null;uniqueIndex:idx_option_type_product_name"`
+	Values    []ProductOptionValue `gorm:"foreignKey:OptionTypeID"`
+}
+
+// ProductOptionValue is one value of an option type, e.g. "M"
+type ProductOptionValue struct {
+	ID           uint32            `gorm:"primary_key"`
+	OptionTypeID uint32            `gorm:"not null;uniqueIndex:idx_option_value_type_value"`
+	Value        string            `gorm:"not null;uniqueIndex:idx_option_value_type_value"`
+	OptionType   ProductOptionType `gorm:"foreignKey:OptionTypeID"`
+}
+
+// ProductVariant is a sellable combination of option values with its own
+// SKU, price and stock
+type ProductVariant struct {
+	ID           uint32               `gorm:"primary_key"`
+	ProductID    uint32               `gorm:"not null;uniqueIndex:idx_variant_product_sku,where:deleted_at IS NULL"`
+	SKU          string               `gorm:"not null;uniqueIndex:idx_variant_product_sku,where:deleted_at IS NULL"`
+	Price        uint32               `gorm:"not null"`
+	Stock        uint32               `gorm:"not null"`
+	OptionValues []ProductOptionValue `gorm:"many2many:variant_option_values;"`
+	Product      Product              `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"`
+	DeletedAt    gorm.DeletedAt       `gorm:"index"`
+}
+
+type VariantOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type VariantRequest struct {
+	SKU     string            `json:"sku"`
+	Price   uint32            `json:"price"`
+	Stock   uint32            `json:"stock"`
+	Options map[string]string `json:"options"`
+}
+
+type VariantResponse struct {
+	ID      uint32          `json:"id"`
+	SKU     string          `json:"sku"`
+	Price   uint32          `json:"price"`
+	Stock   uint32          `json:"stock"`
+	Options []VariantOption `json:"options"`
+}
+
+type VariantManagementRequest struct {
+	ShopID    uint32 `json:"shop_id"`
+	ProductID uint32 `json:"product_id"`
+	VariantID uint32 `json:"variant_id"`
+}
+
+// Options flattens the preloaded OptionValues.OptionType into name/value pairs
+func (v ProductVariant) Options() []VariantOption {
+	options := make([]VariantOption, 0, len(v.OptionValues))
+	for _, value := range v.OptionValues {
+		options = append(options, VariantOption{
+			Name:  value.OptionType.Name,
+			Value: value.Value,
+		})
+	}
+	return options
+}
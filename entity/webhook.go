@@ -0,0 +1,190 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type WebhookEventType string
+
+const (
+	OrderCreated       WebhookEventType = "order.created"
+	OrderStatusChanged WebhookEventType = "order.status_changed"
+	ProductUpdated     WebhookEventType = "product.updated"
+)
+
+var webhookEventTypes = map[WebhookEventType]bool{
+	OrderCreated:       true,
+	OrderStatusChanged: true,
+	ProductUpdated:     true,
+}
+
+func (t WebhookEventType) Valid() bool {
+	return webhookEventTypes[t]
+}
+
+// Webhook is an endpoint of a shop that is sent the events it subscribed
+// to. Secret signs every delivery, so it is kept in plain text. Events are
+// space separated.
+type Webhook struct {
+	ID        uint32 `gorm:"primary_key"`
+	ShopID    uint32 `gorm:"not null;index"`
+	Shop      Shop   `gorm:"foreignKey:ShopID;constraint:OnDelete:CASCADE"`
+	URL       string `gorm:"size:2048;not null"`
+	Secret    string `gorm:"size:100;not null"`
+	Events    string `gorm:"not null"`
+	Active    bool   `gorm:"not null;default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (w Webhook) EventList() []WebhookEventType {
+	events := []WebhookEventType{}
+	for _, event := range strings.Fields(w.Events) {
+		events = append(events, WebhookEventType(event))
+	}
+	return events
+}
+
+func (w Webhook) Subscribes(eventType WebhookEventType) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is what one shop is sent about a domain event. The webhooks
+// subscriber of the event bus adds it together with a delivery per
+// subscribed webhook, SourceEventID is the domain event, so handing over the
+// same event twice adds nothing. Payload is the event's data as JSON.
+type WebhookEvent struct {
+	ID            uint64           `gorm:"primary_key"`
+	SourceEventID *uint64          `gorm:"uniqueIndex:idx_webhook_event_source,priority:1"`
+	ShopID        uint32           `gorm:"not null;index;uniqueIndex:idx_webhook_event_source,priority:2"`
+	Type          WebhookEventType `gorm:"type:varchar(50);not null"`
+	Payload       string           `gorm:"type:jsonb;not null"`
+	CreatedAt     time.Time        `gorm:"index"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event for one webhook. It stays pending while
+// attempts are left, NextAttemptAt is when the dispatcher tries it next. The
+// Last fields describe the latest attempt.
+type WebhookDelivery struct {
+	ID             uint64                `gorm:"primary_key"`
+	WebhookID      uint32                `gorm:"not null;index"`
+	Webhook        Webhook               `gorm:"foreignKey:WebhookID;constraint:OnDelete:CASCADE"`
+	EventID        uint64                `gorm:"not null;index"`
+	Event          WebhookEvent          `gorm:"foreignKey:EventID;constraint:OnDelete:CASCADE"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(20);not null;index:idx_webhook_delivery_due,priority:1"`
+	Attempts       int                   `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time             `gorm:"not null;index:idx_webhook_delivery_due,priority:2"`
+	LastStatusCode int
+	LastError      string
+	LastResponse   string
+	LastDurationMs int64
+	LastAttemptAt  *time.Time
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+// WebhookEnvelope is the body of every delivery
+type WebhookEnvelope struct {
+	ID        uint64           `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+// OrderWebhookPayload is the data of order events. An order can hold
+// products of several shops, each shop is only sent its own lines and their
+// subtotal.
+type OrderWebhookPayload struct {
+	ID             uint32             `json:"id"`
+	Status         Status             `json:"status"`
+	PreviousStatus Status             `json:"previousStatus,omitempty"`
+	Courier        string             `json:"courier"`
+	Subtotal       uint64             `json:"subtotal"`
+	Lines          []OrderWebhookLine `json:"lines"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+}
+
+type OrderWebhookLine struct {
+	ProductID uint32 `json:"productId"`
+	VariantID uint32 `json:"variantId,omitempty"`
+	Amount    uint32 `json:"amount"`
+	Price     uint32 `json:"price"`
+}
+
+// ProductWebhookPayload is the data of product.updated, sent after changes
+// to the product or its variants. It is the product as it is when the event
+// is handled, so a burst of changes may report the latest state twice.
+type ProductWebhookPayload struct {
+	ID          uint32            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Price       uint32            `json:"price"`
+	SKU         *string           `json:"sku"`
+	CategoryID  *uint32           `json:"categoryId"`
+	Variants    []VariantResponse `json:"variants"`
+}
+
+type WebhookRequest struct {
+	URL    string             `json:"url"`
+	Events []WebhookEventType `json:"events"`
+	// Active is only read on update, nil keeps the current state
+	Active *bool `json:"active"`
+}
+
+type WebhookResponse struct {
+	ID        uint32             `json:"id"`
+	URL       string             `json:"url"`
+	Events    []WebhookEventType `json:"events"`
+	Active    bool               `json:"active"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+// WebhookCreated carries the signing secret, which is only shown once
+type WebhookCreated struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID            uint64                `json:"id"`
+	EventID       uint64                `json:"eventId"`
+	EventType     WebhookEventType      `json:"eventType"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt *time.Time            `json:"nextAttemptAt,omitempty"`
+	StatusCode    int                   `json:"statusCode,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	Response      string                `json:"response,omitempty"`
+	DurationMs    int64                 `json:"durationMs"`
+	LastAttemptAt *time.Time            `json:"lastAttemptAt"`
+	DeliveredAt   *time.Time            `json:"deliveredAt"`
+	CreatedAt     time.Time             `json:"createdAt"`
+}
+
+type WebhookManagementRequest struct {
+	ShopID    uint32
+	WebhookID uint32
+}
+
+// WebhookDeliveryFilter narrows the delivery log, Limit 0 means the default
+type WebhookDeliveryFilter struct {
+	Status WebhookDeliveryStatus
+	Limit  int
+}
@@ -0,0 +1,129 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/domain"
+	"order-management/entity"
+	"order-management/middleware"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	guard domain.LoginGuard
+	mfa   domain.MFAPolicy
+}
+
+func NewHandler(e *echo.Group, g domain.LoginGuard, m domain.MFAPolicy) *Handler {
+	h := Handler{guard: g, mfa: m}
+
+	adminGroup := e.Group("")
+	adminGroup.Use(middleware.AdminAuth())
+	adminGroup.GET("/lockouts", h.GetLockouts)
+	adminGroup.DELETE("/lockouts", h.Unlock)
+	adminGroup.GET("/mfa-policy", h.GetMFAPolicy)
+	adminGroup.PUT("/mfa-policy", h.SetMFAPolicy)
+	return &h
+}
+
+func (h *Handler) GetLockouts(c echo.Context) error {
+	lockouts, err := h.guard.GetLockouts(c.Request().Context())
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetLockouts]: failed to get lockouts")
+
+		log.WithError(err).Error("Internal server error while getting lockouts")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Lockouts fetched successfully",
+		Data:    lockouts,
+		Status:  http.StatusOK,
+	})
+}
+
+// Unlock lifts the lockout or backoff of ?key=, e.g. user:alice@example.com,
+// shop:My Shop or ip:203.0.113.7
+func (h *Handler) Unlock(c echo.Context) error {
+	key := c.QueryParam("key")
+	if key == "" {
+		err := errors.New("[Handler.Unlock]: key is required")
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.guard.Unlock(c.Request().Context(), key); err != nil {
+		if err.Error() == "[LoginGuard.Unlock]: lockout not found" {
+			err = errors.Wrap(err, "[Handler.Unlock]: lockout not found")
+			return c.JSON(http.StatusNotFound, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+		err = errors.Wrap(err, "[Handler.Unlock]: failed to unlock")
+
+		log.WithFields(log.Fields{
+			"key": key,
+		}).WithError(err).Error("Internal server error while unlocking login")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	log.WithFields(log.Fields{
+		"key":   key,
+		"admin": c.Get("user").(*entity.UserJWT).ID,
+	}).Info("Login unlocked by admin")
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Login unlocked successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) GetMFAPolicy(c echo.Context) error {
+	policy, err := h.mfa.GetMFAPolicy(c.Request().Context())
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetMFAPolicy]: failed to get mfa policy")
+
+		log.WithError(err).Error("Internal server error while getting mfa policy")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "MFA policy fetched successfully",
+		Data:    policy,
+		Status:  http.StatusOK,
+	})
+}
+
+// SetMFAPolicy switches whether every shop has to use two-factor
+// authentication. Shops without it enrol on their next login.
+func (h *Handler) SetMFAPolicy(c echo.Context) error {
+	policy := entity.MFAPolicy{}
+	if err := c.Bind(&policy); err != nil {
+		err = errors.Wrap(err, "[Handler.SetMFAPolicy]: invalid request body")
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.mfa.SetMFAPolicy(c.Request().Context(), policy); err != nil {
+		err = errors.Wrap(err, "[Handler.SetMFAPolicy]: failed to set mfa policy")
+
+		log.WithError(err).Error("Internal server error while setting mfa policy")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	log.WithFields(log.Fields{
+		"requireShops": policy.RequireShops,
+		"admin":        c.Get("user").(*entity.UserJWT).ID,
+	}).Info("MFA policy changed by admin")
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "MFA policy updated successfully",
+		Data:    policy,
+		Status:  http.StatusOK,
+	})
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/database"
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) domain.LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) GetLoginAttempts(ctx context.Context, keys []string) ([]entity.LoginAttempt, error) {
+	attempts := []entity.LoginAttempt{}
+	if err := database.Conn(ctx, r.db).Where("key IN ?", keys).Find(&attempts).Error; err != nil {
+		err = errors.Wrap(err, "[LoginAttemptRepository.GetLoginAttempts]: failed to get login attempts")
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// RecordLoginFailure counts a failure in a single statement, so concurrent
+// guesses cannot overwrite each other's count. A failure more than window
+// after the previous one starts counting from one again.
+func (r *loginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (entity.LoginAttempt, error) {
+	attempt := entity.LoginAttempt{}
+	if err := database.Conn(ctx, r.db).Raw(`INSERT INTO login_attempts (key, failures, last_failure_at, locked)
+		VALUES (?, 1, ?, false)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING *`, key, now, now.Add(-window)).
+		Scan(&attempt).Error; err != nil {
+		err = errors.Wrap(err, "[LoginAttemptRepository.RecordLoginFailure]: failed to record login failure")
+		return entity.LoginAttempt{}, err
+	}
+	return attempt, nil
+}
+
+func (r *loginAttemptRepository) SetLoginBlocked(ctx context.Context, key string, until time.Time, locked bool) error {
+	if err := database.Conn(ctx, r.db).Model(&entity.LoginAttempt{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"blocked_until": until,
+			"locked":        locked,
+		}).Error; err != nil {
+		err = errors.Wrap(err, "[LoginAttemptRepository.SetLoginBlocked]: failed to block login")
+		return err
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) DeleteLoginAttempt(ctx context.Context, key string) error {
+	result := database.Conn(ctx, r.db).Where("key = ?", key).Delete(&entity.LoginAttempt{})
+	if result.Error != nil {
+		err := errors.Wrap(result.Error, "[LoginAttemptRepository.DeleteLoginAttempt]: failed to delete login attempt")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[LoginAttemptRepository.DeleteLoginAttempt]: login attempt not found")
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) GetLockedLoginAttempts(ctx context.Context, now time.Time) ([]entity.LoginAttempt, error) {
+	attempts := []entity.LoginAttempt{}
+	if err := database.Conn(ctx, r.db).
+		Where("locked AND blocked_until > ?", now).
+		Order("blocked_until DESC").
+		Find(&attempts).Error; err != nil {
+		err = errors.Wrap(err, "[LoginAttemptRepository.GetLockedLoginAttempts]: failed to get locked login attempts")
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// DeleteExpiredLoginAttempts drops keys whose last failure is older than
+// before and that are not blocked anymore
+func (r *loginAttemptRepository) DeleteExpiredLoginAttempts(ctx context.Context, before time.Time, now time.Time) (int64, error) {
+	result := database.Conn(ctx, r.db).
+		Where("last_failure_at < ? AND (blocked_until IS NULL OR blocked_until < ?)", before, now).
+		Delete(&entity.LoginAttempt{})
+	if result.Error != nil {
+		err := errors.Wrap(result.Error, "[LoginAttemptRepository.DeleteExpiredLoginAttempts]: failed to delete login attempts")
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
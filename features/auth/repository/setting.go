@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type settingRepository struct {
+	db *gorm.DB
+}
+
+func NewSettingRepository(db *gorm.DB) domain.SettingRepository {
+	return &settingRepository{db: db}
+}
+
+func (r *settingRepository) GetSetting(ctx context.Context, key string) (entity.Setting, error) {
+	setting := entity.Setting{}
+	if err := r.db.WithContext(ctx).Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Setting{}, errors.New("[SettingRepository.GetSetting]: setting not found")
+		}
+		err = errors.Wrap(err, "[SettingRepository.GetSetting]: failed to get setting")
+		return entity.Setting{}, err
+	}
+	return setting, nil
+}
+
+func (r *settingRepository) PutSetting(ctx context.Context, key string, value string) error {
+	setting := entity.Setting{Key: key, Value: value, UpdatedAt: time.Now()}
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).
+		Create(&setting).Error; err != nil {
+		err = errors.Wrap(err, "[SettingRepository.PutSetting]: failed to store setting")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type mfaPolicy struct {
+	settings domain.SettingRepository
+}
+
+func NewMFAPolicy(settings domain.SettingRepository) domain.MFAPolicy {
+	return &mfaPolicy{settings: settings}
+}
+
+// GetMFAPolicy falls back to mfa.requireshops until an admin set the policy
+func (p *mfaPolicy) GetMFAPolicy(ctx context.Context) (entity.MFAPolicy, error) {
+	policy := entity.MFAPolicy{RequireShops: viper.GetBool("mfa.requireshops")}
+
+	setting, err := p.settings.GetSetting(ctx, entity.SettingShopMFARequired)
+	if err != nil {
+		if err.Error() == "[SettingRepository.GetSetting]: setting not found" {
+			return policy, nil
+		}
+		err = errors.Wrap(err, "[MFAPolicy.GetMFAPolicy]: failed to get setting")
+		return entity.MFAPolicy{}, err
+	}
+
+	required, err := strconv.ParseBool(setting.Value)
+	if err != nil {
+		err = errors.Wrap(err, "[MFAPolicy.GetMFAPolicy]: invalid setting")
+		return entity.MFAPolicy{}, err
+	}
+	policy.RequireShops = required
+	return policy, nil
+}
+
+func (p *mfaPolicy) SetMFAPolicy(ctx context.Context, policy entity.MFAPolicy) error {
+	log.WithFields(log.Fields{
+		"requireShops": policy.RequireShops,
+	}).Info("Changing MFA policy")
+
+	if err := p.settings.PutSetting(ctx, entity.SettingShopMFARequired, strconv.FormatBool(policy.RequireShops)); err != nil {
+		err = errors.Wrap(err, "[MFAPolicy.SetMFAPolicy]: failed to store setting")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type loginGuard struct {
+	repo domain.LoginAttemptRepository
+}
+
+func NewLoginGuard(repo domain.LoginAttemptRepository) domain.LoginGuard {
+	return &loginGuard{repo: repo}
+}
+
+// loginPolicy is read from the login config section, missing keys fall back
+// to the defaults below
+type loginPolicy struct {
+	// FreeAttempts failures in a row cost nothing, every further one doubles
+	// the wait starting at BaseDelay up to MaxDelay
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// LockThreshold failures lock an account for LockDuration, an IP is
+	// locked after IPThreshold failures and never backed off before, since
+	// many users can share one address
+	LockThreshold int
+	IPThreshold   int
+	LockDuration  time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+func loadLoginPolicy() loginPolicy {
+	p := loginPolicy{
+		FreeAttempts:  viper.GetInt("login.freeattempts"),
+		BaseDelay:     viper.GetDuration("login.basedelay"),
+		MaxDelay:      viper.GetDuration("login.maxdelay"),
+		LockThreshold: viper.GetInt("login.lockthreshold"),
+		IPThreshold:   viper.GetInt("login.ipthreshold"),
+		LockDuration:  viper.GetDuration("login.lockduration"),
+		Window:        viper.GetDuration("login.window"),
+	}
+	if p.FreeAttempts <= 0 {
+		p.FreeAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = time.Second
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = time.Minute
+	}
+	if p.LockThreshold <= 0 {
+		p.LockThreshold = 10
+	}
+	if p.IPThreshold <= 0 {
+		p.IPThreshold = 50
+	}
+	if p.LockDuration <= 0 {
+		p.LockDuration = 15 * time.Minute
+	}
+	if p.Window <= 0 {
+		p.Window = time.Hour
+	}
+	return p
+}
+
+// penalty returns how long key is blocked after its failures-th failure and
+// whether that is a lockout
+func (p loginPolicy) penalty(key string, failures int) (time.Duration, bool) {
+	if strings.HasPrefix(key, entity.IPLoginKeyPrefix) {
+		if failures >= p.IPThreshold {
+			return p.LockDuration, true
+		}
+		return 0, false
+	}
+
+	if failures >= p.LockThreshold {
+		return p.LockDuration, true
+	}
+	if failures <= p.FreeAttempts {
+		return 0, false
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay, false
+}
+
+func (g *loginGuard) Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	attempts, err := g.repo.GetLoginAttempts(ctx, keys)
+	if err != nil {
+		err = errors.Wrap(err, "[LoginGuard.Check]: failed to get login attempts")
+		return 0, err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, attempt := range attempts {
+		if attempt.BlockedUntil != nil && attempt.BlockedUntil.Sub(now) > wait {
+			wait = attempt.BlockedUntil.Sub(now)
+		}
+	}
+	return wait, nil
+}
+
+func (g *loginGuard) Failed(ctx context.Context, keys ...string) ([]entity.LoginAttempt, error) {
+	policy := loadLoginPolicy()
+	now := time.Now()
+
+	locked := []entity.LoginAttempt{}
+	for _, key := range keys {
+		attempt, err := g.repo.RecordLoginFailure(ctx, key, now, policy.Window)
+		if err != nil {
+			err = errors.Wrap(err, "[LoginGuard.Failed]: failed to record login failure")
+			return nil, err
+		}
+
+		delay, lock := policy.penalty(key, attempt.Failures)
+		if delay == 0 {
+			continue
+		}
+		until := now.Add(delay)
+		if err := g.repo.SetLoginBlocked(ctx, key, until, lock); err != nil {
+			err = errors.Wrap(err, "[LoginGuard.Failed]: failed to block login")
+			return nil, err
+		}
+
+		if lock {
+			log.WithFields(log.Fields{
+				"key":      key,
+				"failures": attempt.Failures,
+				"until":    until,
+			}).Warn("Login locked after repeated failures")
+
+			attempt.BlockedUntil = &until
+			attempt.Locked = true
+			locked = append(locked, attempt)
+		}
+	}
+	return locked, nil
+}
+
+func (g *loginGuard) Succeeded(ctx context.Context, key string) error {
+	if err := g.repo.DeleteLoginAttempt(ctx, key); err != nil {
+		if err.Error() == "[LoginAttemptRepository.DeleteLoginAttempt]: login attempt not found" {
+			return nil
+		}
+		err = errors.Wrap(err, "[LoginGuard.Succeeded]: failed to delete login attempt")
+		return err
+	}
+	return nil
+}
+
+// GetLoginAttempt returns the zero attempt for keys without failures
+func (g *loginGuard) GetLoginAttempt(ctx context.Context, key string) (entity.LoginAttempt, error) {
+	attempts, err := g.repo.GetLoginAttempts(ctx, []string{key})
+	if err != nil {
+		err = errors.Wrap(err, "[LoginGuard.GetLoginAttempt]: failed to get login attempts")
+		return entity.LoginAttempt{}, err
+	}
+	if len(attempts) == 0 {
+		return entity.LoginAttempt{Key: key}, nil
+	}
+	return attempts[0], nil
+}
+
+func (g *loginGuard) GetLockouts(ctx context.Context) ([]entity.LoginAttempt, error) {
+	attempts, err := g.repo.GetLockedLoginAttempts(ctx, time.Now())
+	if err != nil {
+		err = errors.Wrap(err, "[LoginGuard.GetLockouts]: failed to get locked login attempts")
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (g *loginGuard) Unlock(ctx context.Context, key string) error {
+	log.WithFields(log.Fields{
+		"key": key,
+	}).Info("Unlocking login")
+
+	if err := g.repo.DeleteLoginAttempt(ctx, key); err != nil {
+		if err.Error() == "[LoginAttemptRepository.DeleteLoginAttempt]: login attempt not found" {
+			err = errors.New("[LoginGuard.Unlock]: lockout not found")
+			return err
+		}
+		err = errors.Wrap(err, "[LoginGuard.Unlock]: failed to delete login attempt")
+		return err
+	}
+	return nil
+}
+
+func (g *loginGuard) PurgeLoginAttempts(ctx context.Context) (int64, error) {
+	now := time.Now()
+	purged, err := g.repo.DeleteExpiredLoginAttempts(ctx, now.Add(-loadLoginPolicy().Window), now)
+	if err != nil {
+		err = errors.Wrap(err, "[LoginGuard.PurgeLoginAttempts]: failed to delete login attempts")
+		return 0, err
+	}
+	return purged, nil
+}
@@ -0,0 +1,187 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/domain"
+	"order-management/entity"
+	"order-management/middleware"
+	"order-management/utils"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	usecase domain.CategoryUsecase
+}
+
+func NewHandler(e *echo.Group, u domain.CategoryUsecase) *Handler {
+	h := Handler{usecase: u}
+
+	publicGroup := e.Group("")
+	publicGroup.GET("", h.GetCategories)
+	publicGroup.GET("/:slug/products", h.GetCategoryProducts)
+
+	adminGroup := e.Group("")
+	adminGroup.Use(middleware.AdminAuth())
+	adminGroup.POST("", h.CreateCategory)
+	adminGroup.PUT("/:id", h.UpdateCategory)
+	adminGroup.DELETE("/:id", h.DeleteCategory)
+	return &h
+}
+
+func (h *Handler) GetCategories(c echo.Context) error {
+	categories, err := h.usecase.GetCategories(c.Request().Context())
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetCategories]: failed to get categories")
+
+		log.WithError(err).Error("Internal server error while getting categories")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Categories fetched successfully",
+		Data:    categories,
+		Status:  http.StatusOK,
+	})
+}
+
+// GetCategoryProducts lists the products of the category and its
+// subcategories, ?tag= narrows them to one tag
+func (h *Handler) GetCategoryProducts(c echo.Context) error {
+	products, err := h.usecase.GetCategoryProducts(c.Request().Context(), c.Param("slug"), c.QueryParam("tag"))
+	if err != nil {
+		if err.Error() == "[CategoryUsecase.GetCategoryProducts]: category not found" {
+			err = errors.Wrap(err, "[Handler.GetCategoryProducts]: category not found")
+
+			log.WithError(err).Warn("Category not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+		err = errors.Wrap(err, "[Handler.GetCategoryProducts]: failed to get category products")
+
+		log.WithError(err).Error("Internal server error while getting category products")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Category products fetched successfully",
+		Data:    products,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) CreateCategory(c echo.Context) error {
+	req := entity.CategoryRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.CreateCategory]: invalid category")
+
+		log.WithError(err).Warn("Invalid category data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		err := errors.New("[Handler.CreateCategory]: name is required")
+
+		log.Warn("Category name is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	category, err := h.usecase.CreateCategory(c.Request().Context(), req)
+	if err != nil {
+		return categoryError(c, errors.Wrap(err, "[Handler.CreateCategory]: failed to create category"))
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Category created successfully",
+		Data:    category,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) UpdateCategory(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateCategory]: invalid category id")
+
+		log.WithError(err).Warn("Invalid category ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	req := entity.CategoryRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateCategory]: invalid category")
+
+		log.WithError(err).Warn("Invalid category data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		err := errors.New("[Handler.UpdateCategory]: name is required")
+
+		log.Warn("Category name is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	category, err := h.usecase.UpdateCategory(c.Request().Context(), uint32(id), req)
+	if err != nil {
+		return categoryError(c, errors.Wrap(err, "[Handler.UpdateCategory]: failed to update category"))
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Category updated successfully",
+		Data:    category,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) DeleteCategory(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.DeleteCategory]: invalid category id")
+
+		log.WithError(err).Warn("Invalid category ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.usecase.DeleteCategory(c.Request().Context(), uint32(id)); err != nil {
+		return categoryError(c, errors.Wrap(err, "[Handler.DeleteCategory]: failed to delete category"))
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Category deleted successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func categoryError(c echo.Context, err error) error {
+	switch utils.StandardError(err) {
+	case "category not found":
+		log.WithError(err).Warn("Category not found")
+
+		return c.JSON(http.StatusNotFound, entity.ResponseError{Error: utils.StandardError(err)})
+	case "parent not found", "invalid parent", "invalid slug":
+		log.WithError(err).Warn("Invalid category")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	case "category already exists", "category has subcategories":
+		log.WithError(err).Warn("Category conflict")
+
+		return c.JSON(http.StatusConflict, entity.ResponseError{Error: utils.StandardError(err)})
+	default:
+		log.WithError(err).Error("Internal server error while managing category")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+}
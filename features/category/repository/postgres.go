@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) domain.CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) GetAllCategories(ctx context.Context) (categories []entity.Category, err error) {
+	if err := r.db.WithContext(ctx).Order("name").Find(&categories).Error; err != nil {
+		err = errors.Wrap(err, "[CategoryRepository.GetAllCategories]: failed to get categories")
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) GetCategoryByID(ctx context.Context, id uint32) (category entity.Category, err error) {
+	if err := r.db.WithContext(ctx).First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Category{}, errors.New("[CategoryRepository.GetCategoryByID]: category not found")
+		}
+		err = errors.Wrap(err, "[CategoryRepository.GetCategoryByID]: failed to get category")
+		return entity.Category{}, err
+	}
+	return category, nil
+}
+
+func (r *categoryRepository) GetCategoryBySlug(ctx context.Context, slug string) (category entity.Category, err error) {
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Category{}, errors.New("[CategoryRepository.GetCategoryBySlug]: category not found")
+		}
+		err = errors.Wrap(err, "[CategoryRepository.GetCategoryBySlug]: failed to get category")
+		return entity.Category{}, err
+	}
+	return category, nil
+}
+
+func (r *categoryRepository) CreateCategory(ctx context.Context, category *entity.Category) error {
+	if err := r.db.WithContext(ctx).Omit("Parent").Create(category).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.New("[CategoryRepository.CreateCategory]: category already exists")
+		}
+		err = errors.Wrap(err, "[CategoryRepository.CreateCategory]: failed to create category")
+		return err
+	}
+	return nil
+}
+
+// UpdateCategory writes name, slug and parent as given, a nil parent moves
+// the category to the top level.
+func (r *categoryRepository) UpdateCategory(ctx context.Context, category *entity.Category) error {
+	if err := r.db.WithContext(ctx).Model(category).Select("Name", "Slug", "ParentID").Updates(category).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.New("[CategoryRepository.UpdateCategory]: category already exists")
+		}
+		err = errors.Wrap(err, "[CategoryRepository.UpdateCategory]: failed to update category")
+		return err
+	}
+	return nil
+}
+
+// DeleteCategory refuses to delete a category with subcategories, its
+// products become uncategorised.
+func (r *categoryRepository) DeleteCategory(ctx context.Context, id uint32) error {
+	result := r.db.WithContext(ctx).Delete(&entity.Category{}, id)
+	if err := result.Error; err != nil {
+		if errors.Is(err, gorm.ErrForeignKeyViolated) {
+			return errors.New("[CategoryRepository.DeleteCategory]: category has subcategories")
+		}
+		err = errors.Wrap(err, "[CategoryRepository.DeleteCategory]: failed to delete category")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[CategoryRepository.DeleteCategory]: category not found")
+	}
+	return nil
+}
+
+// CountProductsByCategory counts the live products directly in each category
+func (r *categoryRepository) CountProductsByCategory(ctx context.Context) (counts []entity.CategoryProductCount, err error) {
+	if err := r.db.WithContext(ctx).Model(&entity.Product{}).
+		Select("category_id, count(*) AS count").
+		Where("category_id IS NOT NULL").
+		Where("shop_id IN (SELECT id FROM shops WHERE deactivated_at IS NULL)").
+		Group("category_id").
+		Scan(&counts).Error; err != nil {
+		err = errors.Wrap(err, "[CategoryRepository.CountProductsByCategory]: failed to count products")
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetProductsInCategoryTree lists the products of the category and all of its
+// descendants, optionally only those carrying the tag with the given slug.
+func (r *categoryRepository) GetProductsInCategoryTree(ctx context.Context, categoryID uint32, tag string) (products []entity.ProductWithOutShop, err error) {
+	tree := r.db.Raw(`WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = ?
+			UNION ALL
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		) SELECT id FROM tree`, categoryID)
+
+	query := r.db.WithContext(ctx).Model(&entity.Product{}).
+		Where("category_id IN (?)", tree).
+		Where("shop_id IN (SELECT id FROM shops WHERE deactivated_at IS NULL)")
+	if tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.product_id = products.id AND t.slug = ?)", tag)
+	}
+
+	if err := query.Order("id").Find(&products).Error; err != nil {
+		err = errors.Wrap(err, "[CategoryRepository.GetProductsInCategoryTree]: failed to get products")
+		return nil, err
+	}
+	return products, nil
+}
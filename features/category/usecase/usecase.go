@@ -0,0 +1,259 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"order-management/domain"
+	"order-management/entity"
+	productUsecase "order-management/features/product/usecase"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type categoryUsecase struct {
+	categoryRepo domain.CategoryRepository
+	productRepo  domain.ProductRepository
+	store        domain.BlobStore
+}
+
+func NewCategoryUsecase(categoryRepo domain.CategoryRepository, productRepo domain.ProductRepository, store domain.BlobStore) domain.CategoryUsecase {
+	return &categoryUsecase{
+		categoryRepo: categoryRepo,
+		productRepo:  productRepo,
+		store:        store,
+	}
+}
+
+func (u *categoryUsecase) GetCategories(ctx context.Context) ([]entity.CategoryResponse, error) {
+	log.Trace("Entering function GetCategories()")
+	defer log.Trace("Exiting function GetCategories()")
+
+	categories, counts, err := u.categoryTree(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "[CategoryUsecase.GetCategories]: failed to load categories")
+		return nil, err
+	}
+
+	categoriesResponse := make([]entity.CategoryResponse, 0, len(categories))
+	for _, category := range categories {
+		categoriesResponse = append(categoriesResponse, toCategoryResponse(category, counts))
+	}
+	return categoriesResponse, nil
+}
+
+func (u *categoryUsecase) GetCategoryProducts(ctx context.Context, slug string, tag string) (entity.CategoryProducts, error) {
+	log.Trace("Entering function GetCategoryProducts()")
+	defer log.Trace("Exiting function GetCategoryProducts()")
+
+	log.WithFields(log.Fields{
+		"slug": slug,
+		"tag":  tag,
+	}).Debug("Getting category products")
+
+	category, err := u.categoryRepo.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		if err.Error() == "[CategoryRepository.GetCategoryBySlug]: category not found" {
+			return entity.CategoryProducts{}, errors.New("[CategoryUsecase.GetCategoryProducts]: category not found")
+		}
+		err = errors.Wrap(err, "[CategoryUsecase.GetCategoryProducts]: failed to get category")
+		return entity.CategoryProducts{}, err
+	}
+
+	categories, counts, err := u.categoryTree(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "[CategoryUsecase.GetCategoryProducts]: failed to load categories")
+		return entity.CategoryProducts{}, err
+	}
+
+	children := []entity.CategoryResponse{}
+	for _, child := range categories {
+		if child.ParentID != nil && *child.ParentID == category.ID {
+			children = append(children, toCategoryResponse(child, counts))
+		}
+	}
+
+	if tag != "" {
+		tag = utils.Slugify(tag)
+	}
+	products, err := u.categoryRepo.GetProductsInCategoryTree(ctx, category.ID, tag)
+	if err != nil {
+		err = errors.Wrap(err, "[CategoryUsecase.GetCategoryProducts]: failed to get products")
+		return entity.CategoryProducts{}, err
+	}
+	if products == nil {
+		products = []entity.ProductWithOutShop{}
+	}
+	if err := productUsecase.AttachImages(ctx, u.productRepo, u.store, products); err != nil {
+		err = errors.Wrap(err, "[CategoryUsecase.GetCategoryProducts]: failed to attach images")
+		return entity.CategoryProducts{}, err
+	}
+
+	return entity.CategoryProducts{
+		Category: toCategoryResponse(category, counts),
+		Children: children,
+		Products: products,
+	}, nil
+}
+
+func (u *categoryUsecase) CreateCategory(ctx context.Context, req entity.CategoryRequest) (entity.Category, error) {
+	log.Trace("Entering function CreateCategory()")
+	defer log.Trace("Exiting function CreateCategory()")
+
+	log.WithFields(log.Fields{
+		"req": req,
+	}).Debug("Creating category")
+
+	category := entity.Category{
+		Name:     strings.TrimSpace(req.Name),
+		Slug:     categorySlug(req),
+		ParentID: req.ParentID,
+	}
+	if category.Slug == "" {
+		return entity.Category{}, errors.New("[CategoryUsecase.CreateCategory]: invalid slug")
+	}
+
+	if category.ParentID != nil {
+		if _, err := u.categoryRepo.GetCategoryByID(ctx, *category.ParentID); err != nil {
+			if err.Error() == "[CategoryRepository.GetCategoryByID]: category not found" {
+				return entity.Category{}, errors.New("[CategoryUsecase.CreateCategory]: parent not found")
+			}
+			err = errors.Wrap(err, "[CategoryUsecase.CreateCategory]: failed to get parent category")
+			return entity.Category{}, err
+		}
+	}
+
+	if err := u.categoryRepo.CreateCategory(ctx, &category); err != nil {
+		if err.Error() == "[CategoryRepository.CreateCategory]: category already exists" {
+			return entity.Category{}, errors.New("[CategoryUsecase.CreateCategory]: category already exists")
+		}
+		err = errors.Wrap(err, "[CategoryUsecase.CreateCategory]: failed to create category")
+		return entity.Category{}, err
+	}
+	return category, nil
+}
+
+func (u *categoryUsecase) UpdateCategory(ctx context.Context, id uint32, req entity.CategoryRequest) (entity.Category, error) {
+	log.Trace("Entering function UpdateCategory()")
+	defer log.Trace("Exiting function UpdateCategory()")
+
+	log.WithFields(log.Fields{
+		"id":  id,
+		"req": req,
+	}).Debug("Updating category")
+
+	categories, err := u.categoryRepo.GetAllCategories(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "[CategoryUsecase.UpdateCategory]: failed to get categories")
+		return entity.Category{}, err
+	}
+	byID := make(map[uint32]entity.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	category, ok := byID[id]
+	if !ok {
+		return entity.Category{}, errors.New("[CategoryUsecase.UpdateCategory]: category not found")
+	}
+
+	// Walk up from the new parent, reaching the category itself would turn
+	// the tree into a cycle
+	for parentID := req.ParentID; parentID != nil; {
+		if *parentID == id {
+			return entity.Category{}, errors.New("[CategoryUsecase.UpdateCategory]: invalid parent")
+		}
+		parent, ok := byID[*parentID]
+		if !ok {
+			return entity.Category{}, errors.New("[CategoryUsecase.UpdateCategory]: parent not found")
+		}
+		parentID = parent.ParentID
+	}
+
+	category.Name = strings.TrimSpace(req.Name)
+	category.Slug = categorySlug(req)
+	category.ParentID = req.ParentID
+	if category.Slug == "" {
+		return entity.Category{}, errors.New("[CategoryUsecase.UpdateCategory]: invalid slug")
+	}
+
+	if err := u.categoryRepo.UpdateCategory(ctx, &category); err != nil {
+		if err.Error() == "[CategoryRepository.UpdateCategory]: category already exists" {
+			return entity.Category{}, errors.New("[CategoryUsecase.UpdateCategory]: category already exists")
+		}
+		err = errors.Wrap(err, "[CategoryUsecase.UpdateCategory]: failed to update category")
+		return entity.Category{}, err
+	}
+	return category, nil
+}
+
+func (u *categoryUsecase) DeleteCategory(ctx context.Context, id uint32) error {
+	log.Trace("Entering function DeleteCategory()")
+	defer log.Trace("Exiting function DeleteCategory()")
+
+	log.WithFields(log.Fields{
+		"id": id,
+	}).Debug("Deleting category")
+
+	if err := u.categoryRepo.DeleteCategory(ctx, id); err != nil {
+		switch err.Error() {
+		case "[CategoryRepository.DeleteCategory]: category not found":
+			return errors.New("[CategoryUsecase.DeleteCategory]: category not found")
+		case "[CategoryRepository.DeleteCategory]: category has subcategories":
+			return errors.New("[CategoryUsecase.DeleteCategory]: category has subcategories")
+		}
+		err = errors.Wrap(err, "[CategoryUsecase.DeleteCategory]: failed to delete category")
+		return err
+	}
+	return nil
+}
+
+// categoryTree loads every category with its product count rolled up over
+// its descendants. The tree is small enough to keep the roll up in memory.
+func (u *categoryUsecase) categoryTree(ctx context.Context) ([]entity.Category, map[uint32]int64, error) {
+	categories, err := u.categoryRepo.GetAllCategories(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	direct, err := u.categoryRepo.CountProductsByCategory(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parents := make(map[uint32]*uint32, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	counts := make(map[uint32]int64, len(categories))
+	for _, count := range direct {
+		// Add the direct count to the category and every ancestor, the depth
+		// bound only guards against a corrupted tree
+		id := &count.CategoryID
+		for depth := 0; id != nil && depth <= len(categories); depth++ {
+			counts[*id] += count.Count
+			id = parents[*id]
+		}
+	}
+	return categories, counts, nil
+}
+
+func categorySlug(req entity.CategoryRequest) string {
+	if req.Slug != "" {
+		return utils.Slugify(req.Slug)
+	}
+	return utils.Slugify(req.Name)
+}
+
+func toCategoryResponse(category entity.Category, counts map[uint32]int64) entity.CategoryResponse {
+	return entity.CategoryResponse{
+		ID:           category.ID,
+		ParentID:     category.ParentID,
+		Name:         category.Name,
+		Slug:         category.Slug,
+		ProductCount: counts[category.ID],
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/database"
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type eventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) domain.EventRepository {
+	return &eventRepository{db: db}
+}
+
+// AddEvent joins the transaction of ctx, see database.Conn
+func (r *eventRepository) AddEvent(ctx context.Context, event *entity.DomainEvent) error {
+	if err := database.Conn(ctx, r.db).Create(event).Error; err != nil {
+		return errors.Wrap(err, "[EventRepository.AddEvent]: failed to create event")
+	}
+	return nil
+}
+
+// FanOutEvents marks the events dispatched, those no one subscribes to
+// included. Locked events are skipped, so several instances can run it at
+// once.
+func (r *eventRepository) FanOutEvents(ctx context.Context, subscribers map[entity.EventType][]string, limit int) (int, error) {
+	dispatched := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		events := []entity.DomainEvent{}
+		if err := tx.Select("id", "type").
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return errors.Wrap(err, "[EventRepository.FanOutEvents]: failed to get events")
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		eventIDs := make([]uint64, 0, len(events))
+		deliveries := []entity.EventDelivery{}
+		for _, event := range events {
+			eventIDs = append(eventIDs, event.ID)
+			for _, subscriber := range subscribers[event.Type] {
+				deliveries = append(deliveries, entity.EventDelivery{
+					EventID:       event.ID,
+					Subscriber:    subscriber,
+					Status:        entity.DeliveryPending,
+					NextAttemptAt: now,
+				})
+			}
+		}
+		if len(deliveries) > 0 {
+			if err := tx.Omit("Event").
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&deliveries).Error; err != nil {
+				return errors.Wrap(err, "[EventRepository.FanOutEvents]: failed to create deliveries")
+			}
+		}
+
+		if err := tx.Model(&entity.DomainEvent{}).
+			Where("id IN ?", eventIDs).
+			Update("dispatched_at", now).Error; err != nil {
+			return errors.Wrap(err, "[EventRepository.FanOutEvents]: failed to mark events dispatched")
+		}
+		dispatched = len(events)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return dispatched, nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries that are due,
+// with their event, and moves their next attempt lease ahead, so no other
+// instance runs them meanwhile.
+func (r *eventRepository) ClaimDueDeliveries(ctx context.Context, lease time.Duration, limit int) ([]entity.EventDelivery, error) {
+	ids := []uint64{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&entity.EventDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", entity.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return errors.Wrap(err, "[EventRepository.ClaimDueDeliveries]: failed to get deliveries")
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&entity.EventDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return errors.Wrap(err, "[EventRepository.ClaimDueDeliveries]: failed to claim deliveries")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// Oldest events first, so a subscriber sees an order placed before its
+	// status changes as long as nothing fails
+	deliveries := []entity.EventDelivery{}
+	if err := r.db.WithContext(ctx).
+		Preload("Event").
+		Where("id IN ?", ids).
+		Order("event_id").
+		Find(&deliveries).Error; err != nil {
+		return nil, errors.Wrap(err, "[EventRepository.ClaimDueDeliveries]: failed to load deliveries")
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of the latest attempt of delivery
+func (r *eventRepository) RecordAttempt(ctx context.Context, delivery *entity.EventDelivery) error {
+	if err := r.db.WithContext(ctx).Model(&entity.EventDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_error":      delivery.LastError,
+			"last_attempt_at": delivery.LastAttemptAt,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error; err != nil {
+		return errors.Wrap(err, "[EventRepository.RecordAttempt]: failed to update delivery")
+	}
+	return nil
+}
+
+// PurgeEvents deletes dispatched events created before before whose
+// deliveries all finished, together with the deliveries
+func (r *eventRepository) PurgeEvents(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("dispatched_at IS NOT NULL AND created_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM event_deliveries WHERE event_id = domain_events.id AND status = ?)", entity.DeliveryPending).
+		Delete(&entity.DomainEvent{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "[EventRepository.PurgeEvents]: failed to delete events")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// batchSize is how many events or deliveries one round handles
+	batchSize = 100
+	// maxRetryDelay caps the backoff between attempts
+	maxRetryDelay = time.Hour
+)
+
+type eventBus struct {
+	repo domain.EventRepository
+
+	mu          sync.RWMutex
+	handlers    map[string]domain.EventHandler
+	subscribers map[entity.EventType][]string
+}
+
+func NewEventBus(repo domain.EventRepository) domain.EventBus {
+	return &eventBus{
+		repo:        repo,
+		handlers:    map[string]domain.EventHandler{},
+		subscribers: map[entity.EventType][]string{},
+	}
+}
+
+// retryDelay doubles events.retrydelay with every failed attempt up to
+// maxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	return utils.Backoff(utils.DurationOr("events.retrydelay", 10*time.Second), maxRetryDelay, attempts)
+}
+
+func (b *eventBus) Publish(ctx context.Context, eventType entity.EventType, aggregateID uint32, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "[EventBus.Publish]: failed to encode payload")
+	}
+
+	event := entity.DomainEvent{
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Payload:     string(data),
+	}
+	if err := b.repo.AddEvent(ctx, &event); err != nil {
+		return errors.Wrap(err, "[EventBus.Publish]: failed to add event")
+	}
+
+	log.WithFields(log.Fields{
+		"eventID":     event.ID,
+		"type":        eventType,
+		"aggregateID": aggregateID,
+	}).Debug("Published event")
+	return nil
+}
+
+// Subscribe panics on a name that is taken, that is a wiring mistake
+func (b *eventBus) Subscribe(name string, handler domain.EventHandler, types ...entity.EventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.handlers[name]; ok {
+		panic("[EventBus.Subscribe]: subscriber " + name + " already exists")
+	}
+	b.handlers[name] = handler
+	for _, eventType := range types {
+		b.subscribers[eventType] = append(b.subscribers[eventType], name)
+	}
+}
+
+func (b *eventBus) Dispatch(ctx context.Context) (int, error) {
+	b.mu.RLock()
+	subscribers := make(map[entity.EventType][]string, len(b.subscribers))
+	for eventType, names := range b.subscribers {
+		subscribers[eventType] = names
+	}
+	b.mu.RUnlock()
+
+	if _, err := utils.InRounds(ctx, batchSize, func() (int, error) {
+		return b.repo.FanOutEvents(ctx, subscribers, batchSize)
+	}); err != nil {
+		return 0, errors.Wrap(err, "[EventBus.Dispatch]: failed to dispatch events")
+	}
+
+	// Handlers run one after another, in the order the events happened
+	timeout := utils.DurationOr("events.handlertimeout", 30*time.Second)
+	total, err := utils.InRounds(ctx, batchSize, func() (int, error) {
+		deliveries, err := b.repo.ClaimDueDeliveries(ctx, timeout+30*time.Second, batchSize)
+		if err != nil {
+			return 0, err
+		}
+		for _, delivery := range deliveries {
+			b.deliver(ctx, delivery, timeout)
+		}
+		return len(deliveries), nil
+	})
+	if err != nil {
+		return total, errors.Wrap(err, "[EventBus.Dispatch]: failed to claim deliveries")
+	}
+	return total, nil
+}
+
+// deliver runs the subscriber's handler once and records the attempt.
+// Deliveries of a subscriber that no longer exists fail right away.
+func (b *eventBus) deliver(ctx context.Context, delivery entity.EventDelivery, timeout time.Duration) {
+	fields := log.Fields{
+		"subscriber": delivery.Subscriber,
+		"eventID":    delivery.EventID,
+		"type":       delivery.Event.Type,
+	}
+
+	b.mu.RLock()
+	handler, ok := b.handlers[delivery.Subscriber]
+	b.mu.RUnlock()
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastError = ""
+
+	if !ok {
+		delivery.Status = entity.DeliveryFailed
+		delivery.LastError = "no subscriber named " + delivery.Subscriber
+	} else if err := run(ctx, handler, delivery.Event, timeout); err != nil {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= utils.IntOr("events.maxattempts", 10) {
+			delivery.Status = entity.DeliveryFailed
+			log.WithFields(fields).WithError(err).Error("Event subscriber failed for good")
+		} else {
+			delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+			log.WithFields(fields).WithError(err).Warn("Event subscriber failed, retrying later")
+		}
+	} else {
+		delivery.Status = entity.DeliverySucceeded
+		delivery.DeliveredAt = &now
+	}
+
+	// The attempt happened even if the job is being stopped
+	if err := b.repo.RecordAttempt(context.WithoutCancel(ctx), &delivery); err != nil {
+		log.WithFields(fields).WithError(err).Error("Failed to record event delivery")
+	}
+}
+
+// run turns a panicking handler into an error, so one bad event cannot
+// stop the bus
+func run(ctx context.Context, handler domain.EventHandler, event entity.DomainEvent, timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, event)
+}
+
+// PurgeEvents deletes events older than events.retention whose deliveries
+// all finished
+func (b *eventBus) PurgeEvents(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-utils.DurationOr("events.retention", 7*24*time.Hour))
+	deleted, err := b.repo.PurgeEvents(ctx, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "[EventBus.PurgeEvents]: failed to purge events")
+	}
+	return deleted, nil
+}
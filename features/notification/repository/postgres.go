@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) domain.NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) AddNotification(ctx context.Context, notification *entity.Notification) error {
+	if err := r.db.WithContext(ctx).
+		Omit("User").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(notification).Error; err != nil {
+		return errors.Wrap(err, "[NotificationRepository.AddNotification]: failed to create notification")
+	}
+
+	// The insert was skipped, the event was handled before
+	if notification.ID == 0 {
+		if err := r.db.WithContext(ctx).
+			Where("user_id = ? AND event_id = ?", notification.UserID, notification.EventID).
+			First(notification).Error; err != nil {
+			return errors.Wrap(err, "[NotificationRepository.AddNotification]: failed to get notification")
+		}
+	}
+	return nil
+}
+
+func (r *notificationRepository) SetEmailed(ctx context.Context, notificationID uint64) error {
+	if err := r.db.WithContext(ctx).Model(&entity.Notification{}).
+		Where("id = ?", notificationID).
+		Update("emailed_at", time.Now()).Error; err != nil {
+		return errors.Wrap(err, "[NotificationRepository.SetEmailed]: failed to update notification")
+	}
+	return nil
+}
+
+// GetNotifications returns the user's latest notifications first, hidden
+// ones left out
+func (r *notificationRepository) GetNotifications(ctx context.Context, userID uint32, filter entity.NotificationFilter) (notifications []entity.Notification, err error) {
+	query := r.db.WithContext(ctx).Where("user_id = ? AND NOT hidden", userID)
+	if filter.Unread {
+		query = query.Where("read_at IS NULL")
+	}
+	if filter.Before > 0 {
+		query = query.Where("id < ?", filter.Before)
+	}
+	if err := query.Order("id DESC").Limit(filter.Limit).Find(&notifications).Error; err != nil {
+		err = errors.Wrap(err, "[NotificationRepository.GetNotifications]: failed to get notifications")
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uint32) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&entity.Notification{}).
+		Where("user_id = ? AND NOT hidden AND read_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "[NotificationRepository.CountUnread]: failed to count notifications")
+	}
+	return count, nil
+}
+
+// MarkRead keeps the first read time of a notification read before
+func (r *notificationRepository) MarkRead(ctx context.Context, userID uint32, notificationID uint64) error {
+	result := r.db.WithContext(ctx).Model(&entity.Notification{}).
+		Where("id = ? AND user_id = ? AND NOT hidden", notificationID, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[NotificationRepository.MarkRead]: failed to update notification")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[NotificationRepository.MarkRead]: notification not found")
+	}
+	return nil
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID uint32) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&entity.Notification{}).
+		Where("user_id = ? AND NOT hidden AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "[NotificationRepository.MarkAllRead]: failed to update notifications")
+	}
+	return result.RowsAffected, nil
+}
+
+// GetPreferences returns the stored rows only, types without one use the
+// defaults
+func (r *notificationRepository) GetPreferences(ctx context.Context, userID uint32) (preferences []entity.NotificationPreference, err error) {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&preferences).Error; err != nil {
+		err = errors.Wrap(err, "[NotificationRepository.GetPreferences]: failed to get preferences")
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (r *notificationRepository) SavePreferences(ctx context.Context, preferences []entity.NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).
+		Omit("User").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"email", "in_app", "updated_at"}),
+		}).
+		Create(&preferences).Error; err != nil {
+		return errors.Wrap(err, "[NotificationRepository.SavePreferences]: failed to save preferences")
+	}
+	return nil
+}
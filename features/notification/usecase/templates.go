@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"order-management/entity"
+)
+
+// orderStatusData is what the order status templates can refer to
+type orderStatusData struct {
+	OrderID uint32
+	From    entity.Status
+	To      entity.Status
+	Link    string
+}
+
+type notificationTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+func mustTemplate(title string, body string) notificationTemplate {
+	return notificationTemplate{
+		title: template.Must(template.New("title").Parse(title)),
+		body:  template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// orderStatusTemplates are keyed by the new status. The title is the email
+// subject, the body the in-app text and, with the link and a footer, the
+// email text.
+var orderStatusTemplates = map[entity.Status]notificationTemplate{
+	entity.SHIPPING: mustTemplate(
+		"Your order #{{.OrderID}} has shipped",
+		"Good news, order #{{.OrderID}} is on its way.",
+	),
+	entity.COMPLETED: mustTemplate(
+		"Your order #{{.OrderID}} is complete",
+		"Order #{{.OrderID}} has been delivered. Thank you for shopping with us.",
+	),
+	entity.CANCELLED: mustTemplate(
+		"Your order #{{.OrderID}} was cancelled",
+		"Order #{{.OrderID}} was cancelled by the shop. Anything you paid for it will be refunded.",
+	),
+}
+
+// fallbackTemplate covers statuses added without a template of their own
+var fallbackTemplate = mustTemplate(
+	"Your order #{{.OrderID}} was updated",
+	"Order #{{.OrderID}} moved from {{.From}} to {{.To}}.",
+)
+
+var emailTemplate = template.Must(template.New("email").Parse(
+	"{{.Body}}\n\nSee the order at:\n\n{{.Link}}\n\n" +
+		"You get these emails for updates to your orders. Turn them off in your notification preferences.\n",
+))
+
+func render(t *template.Template, data interface{}) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// renderOrderStatus returns the title and body of an order status change
+func renderOrderStatus(data orderStatusData) (string, string, error) {
+	tmpl, ok := orderStatusTemplates[data.To]
+	if !ok {
+		tmpl = fallbackTemplate
+	}
+	title, err := render(tmpl.title, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := render(tmpl.body, data)
+	if err != nil {
+		return "", "", err
+	}
+	return title, body, nil
+}
+
+func renderEmail(body string, link string) (string, error) {
+	text, err := render(emailTemplate, struct{ Body, Link string }{body, link})
+	if err != nil {
+		return "", err
+	}
+	return text + "\n", nil
+}
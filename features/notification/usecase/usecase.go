@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxFeedPage is the default and largest page of the feed
+const maxFeedPage = 50
+
+type notificationUsecase struct {
+	repo     domain.NotificationRepository
+	userRepo domain.UserRepository
+	mailer   domain.Mailer
+}
+
+func NewNotificationUsecase(repo domain.NotificationRepository, userRepo domain.UserRepository, mailer domain.Mailer) domain.NotificationUsecase {
+	return &notificationUsecase{repo: repo, userRepo: userRepo, mailer: mailer}
+}
+
+// orderLink is the frontend page of the order below notification.orderurl
+func orderLink(orderID uint32) string {
+	link := viper.GetString("notification.orderurl")
+	if link == "" {
+		link = "http://localhost:3000/orders"
+	}
+	id := strconv.FormatUint(uint64(orderID), 10)
+	u, err := url.Parse(link)
+	if err != nil {
+		return link + "/" + id
+	}
+	return u.JoinPath(id).String()
+}
+
+func toNotificationResponse(notification entity.Notification) entity.NotificationResponse {
+	return entity.NotificationResponse{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		OrderID:   notification.OrderID,
+		Title:     notification.Title,
+		Body:      notification.Body,
+		Read:      notification.ReadAt != nil,
+		ReadAt:    notification.ReadAt,
+		CreatedAt: notification.CreatedAt,
+	}
+}
+
+// preference returns the user's preference of the type, the default when
+// none is stored
+func (u *notificationUsecase) preference(ctx context.Context, userID uint32, notificationType entity.NotificationType) (entity.NotificationPreference, error) {
+	preferences, err := u.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		return entity.NotificationPreference{}, err
+	}
+	for _, preference := range preferences {
+		if preference.Type == notificationType {
+			return preference, nil
+		}
+	}
+	return entity.DefaultNotificationPreference(userID, notificationType), nil
+}
+
+// HandleOrderStatusChanged adds the notification to the buyer's feed and
+// emails it, as their preferences say. The bus may hand over an event
+// twice: the entry is stored once per event, hidden from the feed when
+// in-app notifications are off, and its email is not sent again.
+func (u *notificationUsecase) HandleOrderStatusChanged(ctx context.Context, event entity.DomainEvent) error {
+	payload := entity.OrderStatusChangedEvent{}
+	if err := event.Decode(&payload); err != nil {
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to decode event")
+	}
+
+	fields := log.Fields{
+		"eventID": event.ID,
+		"orderID": payload.OrderID,
+		"userID":  payload.UserID,
+	}
+
+	preference, err := u.preference(ctx, payload.UserID, entity.NotificationOrderStatus)
+	if err != nil {
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to get preference")
+	}
+	if !preference.Email && !preference.InApp {
+		log.WithFields(fields).Debug("Order status notifications are off")
+		return nil
+	}
+
+	data := orderStatusData{
+		OrderID: payload.OrderID,
+		From:    payload.From,
+		To:      payload.To,
+		Link:    orderLink(payload.OrderID),
+	}
+	title, body, err := renderOrderStatus(data)
+	if err != nil {
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to render notification")
+	}
+
+	notification := entity.Notification{
+		UserID:  payload.UserID,
+		EventID: event.ID,
+		Type:    entity.NotificationOrderStatus,
+		OrderID: payload.OrderID,
+		Title:   title,
+		Body:    body,
+		Hidden:  !preference.InApp,
+	}
+	if err := u.repo.AddNotification(ctx, &notification); err != nil {
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to add notification")
+	}
+
+	if !preference.Email || notification.EmailedAt != nil {
+		return nil
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, payload.UserID)
+	if err != nil {
+		// The account is gone, there is no one to tell
+		if err.Error() == "[UserRepository.GetUserByID]: user not found" {
+			log.WithFields(fields).Warn("Buyer of the order not found, skipping email")
+			return nil
+		}
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to get user by id")
+	}
+
+	text, err := renderEmail(body, data.Link)
+	if err != nil {
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to render email")
+	}
+	if err := u.mailer.Send(ctx, entity.Email{
+		To:      user.Email,
+		Subject: title,
+		Text:    text,
+	}); err != nil {
+		return errors.Wrap(err, "[NotificationUsecase.HandleOrderStatusChanged]: failed to send email")
+	}
+
+	if err := u.repo.SetEmailed(context.WithoutCancel(ctx), notification.ID); err != nil {
+		// The email went out, a retry would only send it again
+		log.WithFields(fields).WithError(err).Error("Failed to record notification email")
+	}
+
+	log.WithFields(fields).Debug("Sent order status notification")
+	return nil
+}
+
+// GetNotifications is the user's feed, latest first
+func (u *notificationUsecase) GetNotifications(ctx context.Context, userID uint32, filter entity.NotificationFilter) (entity.NotificationFeed, error) {
+	if filter.Limit <= 0 || filter.Limit > maxFeedPage {
+		filter.Limit = maxFeedPage
+	}
+	notifications, err := u.repo.GetNotifications(ctx, userID, filter)
+	if err != nil {
+		err = errors.Wrap(err, "[NotificationUsecase.GetNotifications]: failed to get notifications")
+		return entity.NotificationFeed{}, err
+	}
+	unread, err := u.repo.CountUnread(ctx, userID)
+	if err != nil {
+		err = errors.Wrap(err, "[NotificationUsecase.GetNotifications]: failed to count unread notifications")
+		return entity.NotificationFeed{}, err
+	}
+
+	feed := entity.NotificationFeed{
+		Notifications: make([]entity.NotificationResponse, 0, len(notifications)),
+		Unread:        unread,
+	}
+	for _, notification := range notifications {
+		feed.Notifications = append(feed.Notifications, toNotificationResponse(notification))
+	}
+	return feed, nil
+}
+
+func (u *notificationUsecase) MarkRead(ctx context.Context, userID uint32, notificationID uint64) error {
+	if err := u.repo.MarkRead(ctx, userID, notificationID); err != nil {
+		if err.Error() == "[NotificationRepository.MarkRead]: notification not found" {
+			err = errors.New("[NotificationUsecase.MarkRead]: notification not found")
+			return err
+		}
+		err = errors.Wrap(err, "[NotificationUsecase.MarkRead]: failed to mark notification read")
+		return err
+	}
+	return nil
+}
+
+func (u *notificationUsecase) MarkAllRead(ctx context.Context, userID uint32) (int64, error) {
+	marked, err := u.repo.MarkAllRead(ctx, userID)
+	if err != nil {
+		err = errors.Wrap(err, "[NotificationUsecase.MarkAllRead]: failed to mark notifications read")
+		return 0, err
+	}
+	return marked, nil
+}
+
+// GetPreferences lists every type, with the defaults for those the user
+// never set
+func (u *notificationUsecase) GetPreferences(ctx context.Context, userID uint32) ([]entity.NotificationPreferenceItem, error) {
+	stored, err := u.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		err = errors.Wrap(err, "[NotificationUsecase.GetPreferences]: failed to get preferences")
+		return nil, err
+	}
+
+	byType := map[entity.NotificationType]entity.NotificationPreference{}
+	for _, preference := range stored {
+		byType[preference.Type] = preference
+	}
+
+	items := make([]entity.NotificationPreferenceItem, 0, len(entity.NotificationTypes))
+	for _, notificationType := range entity.NotificationTypes {
+		preference, ok := byType[notificationType]
+		if !ok {
+			preference = entity.DefaultNotificationPreference(userID, notificationType)
+		}
+		items = append(items, entity.NotificationPreferenceItem{
+			Type:  notificationType,
+			Email: preference.Email,
+			InApp: preference.InApp,
+		})
+	}
+	return items, nil
+}
+
+// UpdatePreferences sets the types in the request, the others keep their
+// current preference
+func (u *notificationUsecase) UpdatePreferences(ctx context.Context, userID uint32, req entity.NotificationPreferencesRequest) ([]entity.NotificationPreferenceItem, error) {
+	log.WithFields(log.Fields{
+		"userID": userID,
+		"req":    req,
+	}).Debug("Updating notification preferences")
+
+	if len(req.Preferences) == 0 {
+		err := errors.New("[NotificationUsecase.UpdatePreferences]: preferences are required")
+		return nil, err
+	}
+
+	preferences := []entity.NotificationPreference{}
+	seen := map[entity.NotificationType]bool{}
+	for _, item := range req.Preferences {
+		if !item.Type.Valid() {
+			err := errors.New("[NotificationUsecase.UpdatePreferences]: invalid notification type")
+			return nil, err
+		}
+		// A type twice in one statement fails the upsert
+		if seen[item.Type] {
+			err := errors.New("[NotificationUsecase.UpdatePreferences]: duplicate notification type")
+			return nil, err
+		}
+		seen[item.Type] = true
+		preferences = append(preferences, entity.NotificationPreference{
+			UserID: userID,
+			Type:   item.Type,
+			Email:  item.Email,
+			InApp:  item.InApp,
+		})
+	}
+
+	if err := u.repo.SavePreferences(ctx, preferences); err != nil {
+		err = errors.Wrap(err, "[NotificationUsecase.UpdatePreferences]: failed to save preferences")
+		return nil, err
+	}
+
+	items, err := u.GetPreferences(ctx, userID)
+	if err != nil {
+		err = errors.Wrap(err, "[NotificationUsecase.UpdatePreferences]: failed to get preferences")
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"order-management/entity"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultOrderLimit = 20
+	maxOrderLimit     = 100
+)
+
+// ParseOrderFilter reads the query parameters shared by the order listing
+// endpoints: status, from, to (RFC 3339 or YYYY-MM-DD, to is exclusive and a
+// bare date covers the whole day), sort (created_at|total), order (asc|desc,
+// newest first by default), limit and cursor.
+func ParseOrderFilter(c echo.Context) (entity.OrderFilter, error) {
+	filter := entity.OrderFilter{
+		SortBy: entity.SortByCreatedAt,
+		Desc:   true,
+		Limit:  defaultOrderLimit,
+		Cursor: c.QueryParam("cursor"),
+	}
+
+	if status := c.QueryParam("status"); status != "" {
+		switch entity.Status(status) {
+		case entity.PENDING, entity.SHIPPING, entity.CANCELLED, entity.COMPLETED:
+			filter.Status = entity.Status(status)
+		default:
+			return entity.OrderFilter{}, errors.New("[Delivery.ParseOrderFilter]: invalid status")
+		}
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		t, _, err := parseDate(from)
+		if err != nil {
+			return entity.OrderFilter{}, errors.New("[Delivery.ParseOrderFilter]: invalid from date")
+		}
+		filter.From = &t
+	}
+
+	if to := c.QueryParam("to"); to != "" {
+		t, dateOnly, err := parseDate(to)
+		if err != nil {
+			return entity.OrderFilter{}, errors.New("[Delivery.ParseOrderFilter]: invalid to date")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+
+	switch sort := c.QueryParam("sort"); sort {
+	case "", string(entity.SortByCreatedAt):
+	case string(entity.SortByTotal):
+		filter.SortBy = entity.SortByTotal
+	default:
+		return entity.OrderFilter{}, errors.New("[Delivery.ParseOrderFilter]: invalid sort")
+	}
+
+	switch order := c.QueryParam("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		return entity.OrderFilter{}, errors.New("[Delivery.ParseOrderFilter]: invalid order")
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxOrderLimit {
+			return entity.OrderFilter{}, errors.New("[Delivery.ParseOrderFilter]: limit must be between 1 and 100")
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+func parseDate(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
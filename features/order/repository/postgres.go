@@ -1,11 +1,16 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+
+	"order-management/database"
 	"order-management/domain"
 	"order-management/entity"
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type orderRepository struct {
@@ -16,10 +21,28 @@ func NewOrderRepository(db *gorm.DB) domain.OrderRepository {
 	return &orderRepository{db: db}
 }
 
-func (r *orderRepository) CreateOrder(order entity.Order) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+// CreateOrder sets the order's id
+func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Reserve variant stock, the conditional update fails the whole order
+		// if another order took the last items in the meantime
+		for _, orderProduct := range order.OrderProducts {
+			if orderProduct.VariantID == 0 {
+				continue
+			}
+			result := tx.Model(&entity.ProductVariant{}).
+				Where("id = ? AND stock >= ?", orderProduct.VariantID, orderProduct.Amount).
+				UpdateColumn("stock", gorm.Expr("stock - ?", orderProduct.Amount))
+			if result.Error != nil {
+				return errors.Wrap(result.Error, "[OrderRepository.CreateOrder]: failed to reserve stock")
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("[OrderRepository.CreateOrder]: insufficient stock")
+			}
+		}
+
 		// Create the order first
-		if err := tx.Create(&order).Error; err != nil {
+		if err := tx.Create(order).Error; err != nil {
 			return errors.Wrap(err, "[OrderRepository.CreateOrder]: failed to create order")
 		}
 
@@ -28,38 +51,128 @@ func (r *orderRepository) CreateOrder(order entity.Order) error {
 	})
 }
 
-func (r *orderRepository) GetOrder(orderID uint32) (entity.Order, error) {
+func (r *orderRepository) GetOrder(ctx context.Context, orderID uint32) (entity.Order, error) {
 	var order entity.Order
-	if err := r.db.Preload("Products").Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Preload("OrderProducts.Product", withDeleted).Preload("OrderProducts.Variant", withDeleted).Preload("OrderProducts.Variant.OptionValues.OptionType").Where("id = ?", orderID).First(&order).Error; err != nil {
 		err = errors.Wrap(err, "[OrderRepository.GetOrder]: failed to get order")
 		return entity.Order{}, err
 	}
 	return order, nil
 }
 
-func (r *orderRepository) GetOrdersByUserID(userID uint32) ([]uint32, error) {
-	var orderIDs []uint32
-	if err := r.db.Table("orders").Select("orders.id").Where("orders.user_id = ?", userID).Pluck("orders.id", &orderIDs).Error; err != nil {
+// GetOrderForUpdate locks the order until the transaction of ctx ends, with
+// its lines and their products, deleted ones included
+func (r *orderRepository) GetOrderForUpdate(ctx context.Context, orderID uint32) (entity.Order, error) {
+	var order entity.Order
+	if err := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("OrderProducts.Product", withDeleted).
+		Where("id = ?", orderID).
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Order{}, errors.New("[OrderRepository.GetOrderForUpdate]: order not found")
+		}
+		return entity.Order{}, errors.Wrap(err, "[OrderRepository.GetOrderForUpdate]: failed to get order")
+	}
+	return order, nil
+}
+
+// ReleaseStock adds the amounts of the order's variant lines back to their
+// variants, deleted ones included so a restored variant has them
+func (r *orderRepository) ReleaseStock(ctx context.Context, order entity.Order) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		for _, orderProduct := range order.OrderProducts {
+			if orderProduct.VariantID == 0 {
+				continue
+			}
+			if err := tx.Unscoped().Model(&entity.ProductVariant{}).
+				Where("id = ?", orderProduct.VariantID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", orderProduct.Amount)).Error; err != nil {
+				return errors.Wrap(err, "[OrderRepository.ReleaseStock]: failed to release stock")
+			}
+		}
+		return nil
+	})
+}
+
+// GetOrdersByUserID loads one page of the user's orders together with their
+// lines and products in three queries (orders, order_products, products)
+// however many orders the page holds.
+func (r *orderRepository) GetOrdersByUserID(ctx context.Context, userID uint32, filter entity.OrderFilter) ([]entity.Order, error) {
+	var orders []entity.Order
+	query := database.Conn(ctx, r.db).Preload("OrderProducts.Product", withDeleted).Preload("OrderProducts.Variant", withDeleted).Preload("OrderProducts.Variant.OptionValues.OptionType").
+		Where("orders.user_id = ?", userID)
+	if err := applyOrderFilter(query, filter).Find(&orders).Error; err != nil {
 		err = errors.Wrap(err, "[OrderRepository.GetOrdersByUserID]: failed to get orders by user id")
 		return nil, err
 	}
-	return orderIDs, nil
+	return orders, nil
 }
 
-func (r *orderRepository) GetOrdersByShopID(shopID uint32) ([]uint32, error) {
-	var orderIDs []uint32
-	if err := r.db.Table("orders").Select("orders.id").
-		Joins("JOIN order_products op ON orders.id = op.order_id").
-		Where("op.shop_id = ?", shopID).
-		Pluck("orders.id", &orderIDs).Error; err != nil {
+// GetOrdersByShopID returns one page of the orders containing at least one
+// product of the shop, preloaded the same way as GetOrdersByUserID.
+func (r *orderRepository) GetOrdersByShopID(ctx context.Context, shopID uint32, filter entity.OrderFilter) ([]entity.Order, error) {
+	var orders []entity.Order
+	shopOrders := r.db.Table("order_products op").
+		Select("op.order_id").
+		Joins("JOIN products p ON p.id = op.product_id").
+		Where("p.shop_id = ?", shopID)
+	query := database.Conn(ctx, r.db).Preload("OrderProducts.Product", withDeleted).Preload("OrderProducts.Variant", withDeleted).Preload("OrderProducts.Variant.OptionValues.OptionType").
+		Where("orders.id IN (?)", shopOrders)
+	if err := applyOrderFilter(query, filter).Find(&orders).Error; err != nil {
 		err = errors.Wrap(err, "[OrderRepository.GetOrdersByShopID]: failed to get orders by shop id")
 		return nil, err
 	}
-	return orderIDs, nil
+	return orders, nil
+}
+
+// withDeleted lets order lines resolve products that were soft deleted after
+// the order was placed.
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
 }
 
-func (r *orderRepository) UpdateOrder(order entity.Order) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+// applyOrderFilter adds the filter, keyset and ordering clauses. It fetches
+// one row more than the limit so the caller can tell whether a next page
+// exists.
+func applyOrderFilter(query *gorm.DB, filter entity.OrderFilter) *gorm.DB {
+	if filter.Status != "" {
+		query = query.Where("orders.status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("orders.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("orders.created_at < ?", *filter.To)
+	}
+
+	column := "orders.created_at"
+	if filter.SortBy == entity.SortByTotal {
+		column = "orders.total"
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		var value interface{} = filter.After.CreatedAt
+		if filter.SortBy == entity.SortByTotal {
+			value = filter.After.Total
+		}
+		query = query.Where(fmt.Sprintf("(%s, orders.id) %s (?, ?)", column, comparison), value, filter.After.ID)
+	}
+
+	query = query.Order(fmt.Sprintf("%s %s, orders.id %s", column, direction, direction))
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit + 1)
+	}
+	return query
+}
+
+func (r *orderRepository) UpdateOrder(ctx context.Context, order entity.Order) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
 		// Update order details
 		if err := tx.Model(&entity.Order{}).Where("id = ?", order.ID).Updates(order).Error; err != nil {
 			return errors.Wrap(err, "[OrderRepository.UpdateOrder]: failed to update order")
@@ -85,8 +198,8 @@ func (r *orderRepository) UpdateOrder(order entity.Order) error {
 	})
 }
 
-func (r *orderRepository) DeleteOrder(orderID uint32) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+func (r *orderRepository) DeleteOrder(ctx context.Context, orderID uint32) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
 		// Delete order products first (due to foreign key constraint)
 		if err := tx.Where("order_id = ?", orderID).Delete(&entity.OrderProduct{}).Error; err != nil {
 			return errors.Wrap(err, "[OrderRepository.DeleteOrder]: failed to delete order products")
@@ -101,18 +214,10 @@ func (r *orderRepository) DeleteOrder(orderID uint32) error {
 	})
 }
 
-func (r *orderRepository) GetAllOrders() ([]entity.Order, error) {
+func (r *orderRepository) GetAllOrders(ctx context.Context) ([]entity.Order, error) {
 	var orders []entity.Order
-	if err := r.db.Preload("OrderProducts.Product").Find(&orders).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Preload("OrderProducts.Product", withDeleted).Preload("OrderProducts.Variant", withDeleted).Preload("OrderProducts.Variant.OptionValues.OptionType").Find(&orders).Error; err != nil {
 		return nil, errors.Wrap(err, "[OrderRepository.GetAllOrders]: failed to get all orders")
 	}
 	return orders, nil
 }
-
-func (r *orderRepository) GetProductOrderAmount(orderID uint32, productID uint32) (uint32, error) {
-	var amount uint32
-	if err := r.db.Table("order_products").Where("order_id = ? AND product_id = ?", orderID, productID).Pluck("amount", &amount).Error; err != nil {
-		return 0, errors.Wrap(err, "[OrderRepository.GetProductOrderAmount]: failed to get product order amount")
-	}
-	return amount, nil
-}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"order-management/entity"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// listingQueries is what one order listing costs: the orders, then one
+// query per preloaded relation (lines, products, variants, the variant to
+// option value join table, option values and option types)
+const listingQueries = 7
+
+// mockOrders answers the queries of an order listing with n orders of one
+// line each, every line with a variant and an option value, so that every
+// preload has rows to load
+func mockOrders(mock sqlmock.Sqlmock, n int) {
+	now := time.Now()
+	orders := sqlmock.NewRows([]string{"id", "status", "total", "courier", "user_id", "created_at", "updated_at"})
+	lines := sqlmock.NewRows([]string{"order_id", "product_id", "variant_id", "amount", "price"})
+	products := sqlmock.NewRows([]string{"id", "name", "description", "price", "shop_id", "category_id", "sku", "deleted_at"})
+	variants := sqlmock.NewRows([]string{"id", "product_id", "sku", "price", "stock", "deleted_at"})
+	joins := sqlmock.NewRows([]string{"product_variant_id", "product_option_value_id"})
+	values := sqlmock.NewRows([]string{"id", "option_type_id", "value"})
+	types := sqlmock.NewRows([]string{"id", "product_id", "name"})
+	for i := 1; i <= n; i++ {
+		id := driver.Value(int64(i))
+		orders.AddRow(id, entity.PENDING, 10, "post", 1, now, now)
+		lines.AddRow(id, id, id, 1, 10)
+		products.AddRow(id, "product", "", 10, 1, nil, nil, nil)
+		variants.AddRow(id, id, "sku", 10, 5, nil)
+		joins.AddRow(id, id)
+		values.AddRow(id, id, "M")
+		types.AddRow(id, id, "Size")
+	}
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery(`FROM "orders"`).WillReturnRows(orders)
+	mock.ExpectQuery(`FROM "order_products"`).WillReturnRows(lines)
+	mock.ExpectQuery(`FROM "products"`).WillReturnRows(products)
+	mock.ExpectQuery(`FROM "product_variants"`).WillReturnRows(variants)
+	mock.ExpectQuery(`FROM "variant_option_values"`).WillReturnRows(joins)
+	mock.ExpectQuery(`FROM "product_option_values"`).WillReturnRows(values)
+	mock.ExpectQuery(`FROM "product_option_types"`).WillReturnRows(types)
+}
+
+// countListingQueries runs list against n mocked orders and returns how many
+// statements it sent
+func countListingQueries(t *testing.T, n int, list func(repo *orderRepository) ([]entity.Order, error)) int {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Subqueries such as the shop filter run the callbacks in dry run mode
+	// to build their SQL, only statements sent to the database count
+	statements := 0
+	if err := db.Callback().Query().After("gorm:query").Register("test:count", func(tx *gorm.DB) {
+		if !tx.DryRun {
+			statements++
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mockOrders(mock, n)
+	orders, err := list(&orderRepository{db: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != n {
+		t.Fatalf("got %d orders, want %d", len(orders), n)
+	}
+	for _, order := range orders {
+		if len(order.OrderProducts) != 1 {
+			t.Fatalf("order %d has %d lines, want 1", order.ID, len(order.OrderProducts))
+		}
+		line := order.OrderProducts[0]
+		if line.Product.ID != line.ProductID || line.Variant.ID != line.VariantID {
+			t.Fatalf("order %d line was not preloaded", order.ID)
+		}
+		if len(line.Variant.OptionValues) != 1 || line.Variant.OptionValues[0].OptionType.Name != "Size" {
+			t.Fatalf("order %d variant options were not preloaded", order.ID)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	return statements
+}
+
+func TestOrderListingQueryCount(t *testing.T) {
+	listings := map[string]func(repo *orderRepository) ([]entity.Order, error){
+		"GetOrdersByUserID": func(repo *orderRepository) ([]entity.Order, error) {
+			return repo.GetOrdersByUserID(context.Background(), 1, entity.OrderFilter{})
+		},
+		"GetOrdersByShopID": func(repo *orderRepository) ([]entity.Order, error) {
+			return repo.GetOrdersByShopID(context.Background(), 1, entity.OrderFilter{})
+		},
+	}
+
+	for name, list := range listings {
+		t.Run(name, func(t *testing.T) {
+			for _, n := range []int{1, 25} {
+				if got := countListingQueries(t, n, list); got != listingQueries {
+					t.Errorf("%d orders took %d queries, want %d", n, got, listingQueries)
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseStock(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lines without a variant reserved nothing
+	order := entity.Order{ID: 1, OrderProducts: []entity.OrderProduct{
+		{ProductID: 1, VariantID: 4, Amount: 2},
+		{ProductID: 2, Amount: 5},
+		{ProductID: 3, VariantID: 9, Amount: 1},
+	}}
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "product_variants" SET "stock"=stock \+ \$1 WHERE id = \$2`).
+		WithArgs(2, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "product_variants" SET "stock"=stock \+ \$1 WHERE id = \$2`).
+		WithArgs(1, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	repo := &orderRepository{db: db}
+	if err := repo.ReleaseStock(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,23 +1,30 @@
 package usecase
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
 	"order-management/domain"
 	"order-management/entity"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type OrderUsecase struct {
 	orderRepo   domain.OrderRepository
 	productRepo domain.ProductRepository
+	tx          domain.Transactor
+	events      domain.EventPublisher
 }
 
-func NewOrderUsecase(orderRepo domain.OrderRepository, productRepo domain.ProductRepository) domain.OrderUsecase {
-	return &OrderUsecase{orderRepo: orderRepo, productRepo: productRepo}
+func NewOrderUsecase(orderRepo domain.OrderRepository, productRepo domain.ProductRepository, tx domain.Transactor, events domain.EventPublisher) domain.OrderUsecase {
+	return &OrderUsecase{orderRepo: orderRepo, productRepo: productRepo, tx: tx, events: events}
 }
 
-func (u *OrderUsecase) CreateOrder(orderRequest entity.OrderRequest, userID uint32) error {
+func (u *OrderUsecase) CreateOrder(ctx context.Context, orderRequest entity.OrderRequest, userID uint32) error {
 	log.Trace("Entering function CreateOrder()")
 	defer log.Trace("Exiting function CreateOrder()")
 
@@ -25,36 +32,66 @@ func (u *OrderUsecase) CreateOrder(orderRequest entity.OrderRequest, userID uint
 		"orderRequest": orderRequest,
 	}).Debug("Creating order")
 
-	totalPrice := 0.0
-	for _, reqProduct := range orderRequest.OrderProducts {
-		price, err := u.productRepo.GetProductPrice(reqProduct.ProductId)
-		if err != nil {
-			err = errors.Wrap(err, "[OrderUsecase.CreateOrder]: failed to get product price")
-			return err
-		}
-		totalPrice += price * float64(reqProduct.Amount)
-	}
 	// 1. Create the main order
 	order := entity.Order{
 		Status:        entity.PENDING, // Initial status should be PENDING
 		Courier:       orderRequest.Courier,
 		UserID:        userID,
 		OrderProducts: make([]entity.OrderProduct, len(orderRequest.OrderProducts)),
-		Total:         float32(totalPrice),
 	}
 
-	// 2. Transform OrderProductRequest into OrderProduct entries
+	// 2. Transform OrderProductRequest into OrderProduct entries, pricing each
+	// line from its variant when the product has variants. A product or
+	// variant may only appear once per order
+	totalPrice := 0.0
+	seen := map[[2]uint32]bool{}
 	for i, reqProduct := range orderRequest.OrderProducts {
-		orderProduct := entity.OrderProduct{
-			ProductID: reqProduct.ProductId,
-			Amount:    reqProduct.Amount,
-			// OrderID will be automatically set by the repository after order creation
+		if reqProduct.Amount == 0 {
+			return errors.New("[OrderUsecase.CreateOrder]: invalid amount")
+		}
+		orderProduct, err := u.orderLine(ctx, reqProduct)
+		if err != nil {
+			return err
+		}
+		key := [2]uint32{orderProduct.ProductID, orderProduct.VariantID}
+		if seen[key] {
+			return errors.New("[OrderUsecase.CreateOrder]: duplicate order line")
 		}
+		seen[key] = true
+		// OrderID will be automatically set by the repository after order creation
 		order.OrderProducts[i] = orderProduct
+		totalPrice += float64(orderProduct.Price) * float64(orderProduct.Amount)
 	}
+	order.Total = float32(totalPrice)
 
-	// 3. Call the repository to create the order
-	if err := u.orderRepo.CreateOrder(order); err != nil {
+	// 3. Call the repository to create the order, together with the event
+	err := u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := u.orderRepo.CreateOrder(ctx, &order); err != nil {
+			return err
+		}
+
+		placed := entity.OrderPlacedEvent{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			Total:   order.Total,
+			Courier: order.Courier,
+			Lines:   make([]entity.OrderLine, 0, len(order.OrderProducts)),
+		}
+		for _, orderProduct := range order.OrderProducts {
+			placed.Lines = append(placed.Lines, entity.OrderLine{
+				ProductID: orderProduct.ProductID,
+				VariantID: orderProduct.VariantID,
+				Amount:    orderProduct.Amount,
+				Price:     orderProduct.Price,
+			})
+		}
+		return u.events.Publish(ctx, entity.EventOrderPlaced, order.ID, placed)
+	})
+	if err != nil {
+		if err.Error() == "[OrderRepository.CreateOrder]: insufficient stock" {
+			err = errors.New("[OrderUsecase.CreateOrder]: insufficient stock")
+			return err
+		}
 		err = errors.Wrap(err, "[OrderUsecase.CreateOrder]: failed to create order")
 		return err
 	}
@@ -62,13 +99,140 @@ func (u *OrderUsecase) CreateOrder(orderRequest entity.OrderRequest, userID uint
 	return nil
 }
 
-func (u *OrderUsecase) GetAllOrders() ([]entity.Order, error) {
+func (u *OrderUsecase) UpdateOrderStatus(ctx context.Context, shopID uint32, orderID uint32, status entity.Status) (entity.OrderInfo, error) {
+	log.WithFields(log.Fields{
+		"shopID":  shopID,
+		"orderID": orderID,
+		"status":  status,
+	}).Debug("Updating order status")
+
+	if !status.Valid() {
+		err := errors.New("[OrderUsecase.UpdateOrderStatus]: invalid status")
+		return entity.OrderInfo{}, err
+	}
+
+	var order entity.Order
+	err := u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = u.orderRepo.GetOrderForUpdate(ctx, orderID)
+		if err != nil {
+			return err
+		}
+
+		// Shops only see orders with their products, the others do not exist
+		// for them
+		ours := false
+		for _, orderProduct := range order.OrderProducts {
+			if orderProduct.Product.ShopID == shopID {
+				ours = true
+				break
+			}
+		}
+		if !ours {
+			return errors.New("[OrderRepository.GetOrderForUpdate]: order not found")
+		}
+		if !order.Status.CanMoveTo(status) {
+			return errors.New("[OrderUsecase.UpdateOrderStatus]: invalid status transition")
+		}
+
+		previous := order.Status
+		order.Status = status
+		if err := u.orderRepo.UpdateOrder(ctx, entity.Order{ID: order.ID, Status: status}); err != nil {
+			return err
+		}
+		// Only pending orders can be cancelled, their stock is still reserved
+		if status == entity.CANCELLED {
+			if err := u.orderRepo.ReleaseStock(ctx, order); err != nil {
+				return err
+			}
+		}
+		return u.events.Publish(ctx, entity.EventOrderStatusChanged, order.ID, entity.OrderStatusChangedEvent{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			ShopID:  shopID,
+			From:    previous,
+			To:      status,
+		})
+	})
+	if err != nil {
+		switch err.Error() {
+		case "[OrderRepository.GetOrderForUpdate]: order not found":
+			return entity.OrderInfo{}, errors.New("[OrderUsecase.UpdateOrderStatus]: order not found")
+		case "[OrderUsecase.UpdateOrderStatus]: invalid status transition":
+			return entity.OrderInfo{}, err
+		}
+		err = errors.Wrap(err, "[OrderUsecase.UpdateOrderStatus]: failed to update order")
+		return entity.OrderInfo{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"shopID":  shopID,
+		"orderID": orderID,
+		"status":  status,
+	}).Info("Shop changed an order's status")
+
+	return entity.OrderInfo{
+		ID:      order.ID,
+		Status:  order.Status,
+		Total:   order.Total,
+		Courier: order.Courier,
+	}, nil
+}
+
+func (u *OrderUsecase) orderLine(ctx context.Context, reqProduct entity.OrderProductRequest) (entity.OrderProduct, error) {
+	if reqProduct.VariantId == 0 {
+		hasVariants, err := u.productRepo.HasVariants(ctx, reqProduct.ProductId)
+		if err != nil {
+			err = errors.Wrap(err, "[OrderUsecase.CreateOrder]: failed to check product variants")
+			return entity.OrderProduct{}, err
+		}
+		if hasVariants {
+			return entity.OrderProduct{}, errors.New("[OrderUsecase.CreateOrder]: variant is required")
+		}
+
+		price, err := u.productRepo.GetProductPrice(ctx, reqProduct.ProductId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return entity.OrderProduct{}, errors.New("[OrderUsecase.CreateOrder]: product not found")
+			}
+			err = errors.Wrap(err, "[OrderUsecase.CreateOrder]: failed to get product price")
+			return entity.OrderProduct{}, err
+		}
+
+		return entity.OrderProduct{
+			ProductID: reqProduct.ProductId,
+			Amount:    reqProduct.Amount,
+			Price:     uint32(price),
+		}, nil
+	}
+
+	variant, err := u.productRepo.GetVariantByID(ctx, reqProduct.VariantId)
+	if err != nil {
+		if err.Error() == "[ProductRepository.GetVariantByID]: variant not found" {
+			return entity.OrderProduct{}, errors.New("[OrderUsecase.CreateOrder]: variant not found")
+		}
+		err = errors.Wrap(err, "[OrderUsecase.CreateOrder]: failed to get variant")
+		return entity.OrderProduct{}, err
+	}
+	if reqProduct.ProductId != 0 && reqProduct.ProductId != variant.ProductID {
+		return entity.OrderProduct{}, errors.New("[OrderUsecase.CreateOrder]: variant does not belong to product")
+	}
+
+	return entity.OrderProduct{
+		ProductID: variant.ProductID,
+		VariantID: variant.ID,
+		Amount:    reqProduct.Amount,
+		Price:     variant.Price,
+	}, nil
+}
+
+func (u *OrderUsecase) GetAllOrders(ctx context.Context) ([]entity.Order, error) {
 	log.Trace("Entering function GetAllOrders()")
 	defer log.Trace("Exiting function GetAllOrders()")
 
 	log.Debug("Getting all orders")
 
-	orders, err := u.orderRepo.GetAllOrders()
+	orders, err := u.orderRepo.GetAllOrders(ctx)
 	if err != nil {
 		err = errors.Wrap(err, "[OrderUsecase.GetAllOrders]: failed to get all orders")
 		return nil, err
@@ -77,7 +241,7 @@ func (u *OrderUsecase) GetAllOrders() ([]entity.Order, error) {
 	return orders, nil
 }
 
-func (u *OrderUsecase) GetOrder(orderID uint32) (entity.OrderResponse, error) {
+func (u *OrderUsecase) GetOrder(ctx context.Context, orderID uint32) (entity.OrderResponse, error) {
 	log.Trace("Entering function GetOrder()")
 	defer log.Trace("Exiting function GetOrder()")
 
@@ -85,87 +249,146 @@ func (u *OrderUsecase) GetOrder(orderID uint32) (entity.OrderResponse, error) {
 		"orderID": orderID,
 	}).Debug("Getting order by ID")
 
-	order, err := u.orderRepo.GetOrder(orderID)
+	order, err := u.orderRepo.GetOrder(ctx, orderID)
 	if err != nil {
 		err = errors.Wrap(err, "[OrderUsecase.GetOrder]: failed to get order by ID")
 		return entity.OrderResponse{}, err
 	}
 
-	orderProducts := []entity.ProductOrderAmount{}
-	for _, product := range order.Products {
-		amount, err := u.orderRepo.GetProductOrderAmount(orderID, product.ID)
-		if err != nil {
-			err = errors.Wrap(err, "[OrderUsecase.GetOrder]: failed to get product order amount")
-			return entity.OrderResponse{}, err
-		}
-		orderProducts = append(orderProducts, entity.ProductOrderAmount{
-			ID:          product.ID,
-			Name:        product.Name,
-			Price:       product.Price,
-			Description: product.Description,
-			Amount:      amount,
-		})
-	}
-	orderResponse := entity.OrderResponse{
-		ID:       order.ID,
-		Status:   order.Status,
-		Total:    order.Total,
-		Courier:  order.Courier,
-		Products: orderProducts,
-	}
-
-	return orderResponse, nil
+	return toOrderResponse(order), nil
 }
 
-func (u *OrderUsecase) GetOrdersByUserID(userID uint32) ([]entity.OrderResponse, error) {
+func (u *OrderUsecase) GetOrdersByUserID(ctx context.Context, userID uint32, filter entity.OrderFilter) (entity.OrderPage, error) {
 	log.Trace("Entering function GetOrdersByUserID()")
 	defer log.Trace("Exiting function GetOrdersByUserID()")
 
 	log.WithFields(log.Fields{
 		"userID": userID,
+		"filter": filter,
 	}).Debug("Getting orders by user ID")
 
-	orderIds, err := u.orderRepo.GetOrdersByUserID(userID)
+	after, err := decodeOrderCursor(filter)
 	if err != nil {
-		err = errors.Wrap(err, "[OrderUsecase.GetOrdersByUserID]: failed to get orders by user ID")
-		return nil, err
+		err = errors.New("[OrderUsecase.GetOrdersByUserID]: invalid cursor")
+		return entity.OrderPage{}, err
 	}
+	filter.After = after
 
-	ordersResponse := []entity.OrderResponse{}
-	for _, orderID := range orderIds {
-		order, err := u.GetOrder(orderID)
-		if err != nil {
-			err = errors.Wrap(err, "[OrderUsecase.GetOrdersByUserID]: failed to get order by ID")
-			return nil, err
-		}
-		ordersResponse = append(ordersResponse, order)
+	orders, err := u.orderRepo.GetOrdersByUserID(ctx, userID, filter)
+	if err != nil {
+		err = errors.Wrap(err, "[OrderUsecase.GetOrdersByUserID]: failed to get orders by user ID")
+		return entity.OrderPage{}, err
 	}
 
-	return ordersResponse, nil
+	return toOrderPage(orders, filter), nil
 }
 
-func (u *OrderUsecase) GetOrdersByShopID(shopID uint32) ([]entity.OrderResponse, error) {
+func (u *OrderUsecase) GetOrdersByShopID(ctx context.Context, shopID uint32, filter entity.OrderFilter) (entity.OrderPage, error) {
 	log.Trace("Entering function GetOrdersByShopID()")
 	defer log.Trace("Exiting function GetOrdersByShopID()")
 
 	log.WithFields(log.Fields{
 		"shopID": shopID,
+		"filter": filter,
 	}).Debug("Getting orders by shop ID")
 
-	orderIds, err := u.orderRepo.GetOrdersByShopID(shopID)
+	after, err := decodeOrderCursor(filter)
+	if err != nil {
+		err = errors.New("[OrderUsecase.GetOrdersByShopID]: invalid cursor")
+		return entity.OrderPage{}, err
+	}
+	filter.After = after
+
+	orders, err := u.orderRepo.GetOrdersByShopID(ctx, shopID, filter)
 	if err != nil {
 		err = errors.Wrap(err, "[OrderUsecase.GetOrdersByShopID]: failed to get orders by shop ID")
+		return entity.OrderPage{}, err
+	}
+
+	return toOrderPage(orders, filter), nil
+}
+
+// toOrderPage trims the extra row the repository fetched past the limit and
+// turns the last order of the page into the next cursor.
+func toOrderPage(orders []entity.Order, filter entity.OrderFilter) entity.OrderPage {
+	page := entity.OrderPage{}
+	if filter.Limit > 0 && len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+		last := orders[len(orders)-1]
+		page.NextCursor = encodeOrderCursor(entity.OrderCursor{
+			SortBy:    filter.SortBy,
+			Desc:      filter.Desc,
+			CreatedAt: last.CreatedAt,
+			Total:     last.Total,
+			ID:        last.ID,
+		})
+	}
+
+	page.Orders = make([]entity.OrderResponse, 0, len(orders))
+	for _, order := range orders {
+		page.Orders = append(page.Orders, toOrderResponse(order))
+	}
+	return page
+}
+
+func encodeOrderCursor(cursor entity.OrderCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeOrderCursor reads the cursor of the filter, which must have been
+// made for the same sort and order
+func decodeOrderCursor(filter entity.OrderFilter) (*entity.OrderCursor, error) {
+	if filter.Cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+	if err != nil {
 		return nil, err
 	}
+	cursor := entity.OrderCursor{}
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.SortBy != filter.SortBy || cursor.Desc != filter.Desc {
+		return nil, errors.New("cursor of another ordering")
+	}
+	return &cursor, nil
+}
 
-	ordersResponse := []entity.OrderResponse{}
-	for _, orderID := range orderIds {
-		order, err := u.GetOrder(orderID)
-		if err != nil {
-			err = errors.Wrap(err, "[OrderUsecase.GetOrdersByShopID]: failed to get order by ID")
-			return nil, err
+// toOrderResponse expects the order to be loaded with OrderProducts.Product
+// and OrderProducts.Variant.OptionValues.OptionType
+func toOrderResponse(order entity.Order) entity.OrderResponse {
+	orderProducts := make([]entity.ProductOrderAmount, 0, len(order.OrderProducts))
+	for _, op := range order.OrderProducts {
+		// Lines created before prices were recorded fall back to the current price
+		price := op.Price
+		if price == 0 {
+			price = op.Product.Price
+		}
+
+		line := entity.ProductOrderAmount{
+			ID:          op.Product.ID,
+			Name:        op.Product.Name,
+			Price:       price,
+			Description: op.Product.Description,
+			Amount:      op.Amount,
 		}
-		ordersResponse = append(ordersResponse, order)
+		if op.VariantID != 0 {
+			line.VariantID = op.VariantID
+			line.SKU = op.Variant.SKU
+			line.Options = op.Variant.Options()
+		}
+		orderProducts = append(orderProducts, line)
+	}
+
+	return entity.OrderResponse{
+		ID:        order.ID,
+		Status:    order.Status,
+		Total:     order.Total,
+		Courier:   order.Courier,
+		Products:  orderProducts,
+		CreatedAt: order.CreatedAt,
+		UpdatedAt: order.UpdatedAt,
 	}
-	return ordersResponse, nil
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"order-management/domain"
 	"order-management/entity"
+	"order-management/middleware"
 	"order-management/utils"
 	"strconv"
 
@@ -20,6 +21,12 @@ func NewHandler(e *echo.Group, u domain.ProductUsecase) *Handler {
 	publicGroup := e.Group("")
 	publicGroup.GET("", h.GetAllProducts)
 	publicGroup.GET("/:productID", h.GetProductByID)
+	publicGroup.GET("/:productID/variants", h.GetVariants)
+	publicGroup.GET("/:productID/reviews", h.GetReviews)
+
+	userGroup := e.Group("")
+	userGroup.Use(middleware.UserAuth())
+	userGroup.POST("/:productID/reviews", h.CreateReview) // Only buyers with a completed order
 	return &h
 }
 
@@ -31,8 +38,13 @@ func (h *Handler) GetProductByID(c echo.Context) error {
 			Error: utils.StandardError(err),
 		})
 	}
-	product, err := h.usecase.GetProductByID(uint32(productIDUint))
+	product, err := h.usecase.GetProductByID(c.Request().Context(), uint32(productIDUint))
 	if err != nil {
+		if err.Error() == "[ProductUsecase.GetProductByID]: product not found" {
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
 		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
 			Error: utils.StandardError(err),
 		})
@@ -44,8 +56,35 @@ func (h *Handler) GetProductByID(c echo.Context) error {
 		Status:  http.StatusOK,
 	})
 }
+
+func (h *Handler) GetVariants(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("productID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	variants, err := h.usecase.GetVariants(c.Request().Context(), uint32(productID))
+	if err != nil {
+		if err.Error() == "[ProductUsecase.GetVariants]: product not found" {
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Variants fetched successfully",
+		Data:    variants,
+		Status:  http.StatusOK,
+	})
+}
+
 func (h *Handler) GetAllProducts(c echo.Context) error {
-	products, err := h.usecase.GetAllProducts()
+	products, err := h.usecase.GetAllProducts(c.Request().Context())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
 			Error: utils.StandardError(err),
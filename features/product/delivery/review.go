@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/entity"
+	"order-management/utils"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) CreateReview(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("productID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	user, ok := c.Get("user").(*entity.UserJWT)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: "no user claims found",
+		})
+	}
+	req := entity.ReviewRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	review, err := h.usecase.CreateReview(c.Request().Context(), user.ID, uint32(productID), req)
+	if err != nil {
+		switch utils.StandardError(err) {
+		case "rating must be between 1 and 5":
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "product not found":
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "product not purchased":
+			return c.JSON(http.StatusForbidden, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "review already exists":
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Review created successfully",
+		Data:    review,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) GetReviews(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("productID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	reviews, err := h.usecase.GetReviews(c.Request().Context(), uint32(productID))
+	if err != nil {
+		if err.Error() == "[ProductUsecase.GetReviews]: product not found" {
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Reviews fetched successfully",
+		Data:    reviews,
+		Status:  http.StatusOK,
+	})
+}
@@ -1,8 +1,14 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"time"
+
+	"order-management/database"
 	"order-management/domain"
 	"order-management/entity"
+	"order-management/utils"
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
@@ -16,60 +22,507 @@ func NewProductRepository(db *gorm.DB) domain.ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) GetProductPrice(productID uint32) (float64, error) {
+// activeShop limits products to those of shops that are not deactivated, for
+// everything the public sees or can order.
+const activeShop = "shop_id IN (SELECT id FROM shops WHERE deactivated_at IS NULL)"
+
+func (r *productRepository) GetProductPrice(ctx context.Context, productID uint32) (float64, error) {
 	var product entity.Product
-	if err := r.db.Where("id = ?", productID).First(&product).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Where("id = ?", productID).Where(activeShop).First(&product).Error; err != nil {
 		return 0, errors.Wrap(err, "[ProductRepository.GetProductPrice]: failed to get product price")
 	}
 	return float64(product.Price), nil
 }
 
-func (r *productRepository) CreateProduct(product entity.Product, shopID uint32) error {
+func (r *productRepository) CreateProduct(ctx context.Context, product entity.Product, shopID uint32) error {
 	product.ShopID = shopID
-	if err := r.db.Create(&product).Error; err != nil {
-		err = errors.Wrap(err, "[ProductRepository.CreateProduct]: failed to create product")
-		return err
-	}
-	return nil
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		tags, err := findOrCreateTags(tx, product.TagNames)
+		if err != nil {
+			return err
+		}
+		product.Tags = tags
+
+		if err := tx.Omit("Category", "Tags.*").Create(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrForeignKeyViolated) {
+				return errors.New("[ProductRepository.CreateProduct]: category not found")
+			}
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errors.New("[ProductRepository.CreateProduct]: sku already exists")
+			}
+			return errors.Wrap(err, "[ProductRepository.CreateProduct]: failed to create product")
+		}
+		return nil
+	})
 }
 
-func (r *productRepository) GetProductsByShopID(shopID uint32) (products []entity.ProductWithOutShop, err error) {
-	if err := r.db.Model(&entity.Product{}).Where("shop_id = ?", shopID).Find(&products).Error; err != nil {
+func (r *productRepository) GetProductsByShopID(ctx context.Context, shopID uint32) (products []entity.ProductWithOutShop, err error) {
+	if err := database.Conn(ctx, r.db).Model(&entity.Product{}).Where("shop_id = ?", shopID).Find(&products).Error; err != nil {
 		err = errors.Wrap(err, "[ProductRepository.GetProductsByShopID]: failed to get products by shop id")
 		return nil, err
 	}
 	return products, nil
 }
 
-func (r *productRepository) UpdateProduct(req *entity.ProductManagementRequest, product *entity.Product) error {
-	if err := r.db.Model(&entity.Product{}).Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Updates(product).Error; err != nil {
-		err = errors.Wrap(err, "[ProductRepository.UpdateProduct]: failed to update product")
-		return err
-	}
-	return nil
+// UpdateProduct updates the non-zero fields and replaces the tags when the
+// product carries a non-nil TagNames.
+func (r *productRepository) UpdateProduct(ctx context.Context, req *entity.ProductManagementRequest, product *entity.Product) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.Product{}).Omit("Category", "Tags").Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Updates(product).Error; err != nil {
+			if errors.Is(err, gorm.ErrForeignKeyViolated) {
+				return errors.New("[ProductRepository.UpdateProduct]: category not found")
+			}
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errors.New("[ProductRepository.UpdateProduct]: sku already exists")
+			}
+			return errors.Wrap(err, "[ProductRepository.UpdateProduct]: failed to update product")
+		}
+
+		if product.TagNames == nil {
+			return nil
+		}
+
+		tags, err := findOrCreateTags(tx, product.TagNames)
+		if err != nil {
+			return err
+		}
+		existing := entity.Product{ID: req.ProductID}
+		if err := tx.Model(&existing).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+			return errors.Wrap(err, "[ProductRepository.UpdateProduct]: failed to replace tags")
+		}
+		return nil
+	})
 }
 
-func (r *productRepository) GetProductByID(productID uint32) (product entity.Product, err error) {
-	if err := r.db.Preload("Shop").First(&product, productID).Error; err != nil {
+func (r *productRepository) GetProductByID(ctx context.Context, productID uint32) (product entity.Product, err error) {
+	product, err = r.getProduct(database.Conn(ctx, r.db), productID)
+	if err != nil {
 		err = errors.Wrap(err, "[ProductRepository.GetProductByID]: failed to get product by id")
 		return entity.Product{}, err
 	}
+	return product, nil
+}
+
+// GetActiveProductByID is GetProductByID for the public, products of
+// deactivated shops are not found
+func (r *productRepository) GetActiveProductByID(ctx context.Context, productID uint32) (product entity.Product, err error) {
+	product, err = r.getProduct(database.Conn(ctx, r.db).Where(activeShop), productID)
+	if err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetActiveProductByID]: failed to get product by id")
+		return entity.Product{}, err
+	}
+	return product, nil
+}
+
+func (r *productRepository) getProduct(db *gorm.DB, productID uint32) (product entity.Product, err error) {
+	if err := db.Preload("Shop").Preload("Category").Preload("Tags").First(&product, productID).Error; err != nil {
+		return entity.Product{}, err
+	}
 	product.Shop.Password = ""
+	product.TagNames = make([]string, 0, len(product.Tags))
+	for _, tag := range product.Tags {
+		product.TagNames = append(product.TagNames, tag.Name)
+	}
 	return product, nil
 }
 
-func (r *productRepository) DeleteProduct(req *entity.ProductManagementRequest) error {
-	if err := r.db.Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Delete(&entity.Product{}).Error; err != nil {
+// DeleteProduct soft deletes the product, it disappears from listings but
+// orders that contain it keep resolving it.
+func (r *productRepository) DeleteProduct(ctx context.Context, req *entity.ProductManagementRequest) error {
+	result := database.Conn(ctx, r.db).Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Delete(&entity.Product{})
+	if err := result.Error; err != nil {
 		err = errors.Wrap(err, "[ProductRepository.DeleteProduct]: failed to delete product")
 		return err
 	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ProductRepository.DeleteProduct]: product not found")
+	}
+	return nil
+}
+
+func (r *productRepository) RestoreProduct(ctx context.Context, req *entity.ProductManagementRequest) error {
+	result := database.Conn(ctx, r.db).Unscoped().Model(&entity.Product{}).
+		Where("id = ? AND shop_id = ? AND deleted_at IS NOT NULL", req.ProductID, req.ShopID).
+		Update("deleted_at", nil)
+	if err := result.Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.New("[ProductRepository.RestoreProduct]: sku already exists")
+		}
+		err = errors.Wrap(err, "[ProductRepository.RestoreProduct]: failed to restore product")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ProductRepository.RestoreProduct]: product not found")
+	}
 	return nil
 }
 
-func (r *productRepository) GetAllProducts() (products []entity.ProductWithOutShop, err error) {
-	if err := r.db.Model(&entity.Product{}).Find(&products).Error; err != nil {
+func (r *productRepository) GetDeletedProductsByShopID(ctx context.Context, shopID uint32) (products []entity.ProductWithOutShop, err error) {
+	if err := database.Conn(ctx, r.db).Unscoped().Model(&entity.Product{}).
+		Where("shop_id = ? AND deleted_at IS NOT NULL", shopID).
+		Find(&products).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetDeletedProductsByShopID]: failed to get deleted products by shop id")
+		return nil, err
+	}
+	return products, nil
+}
+
+// PurgeDeletedProducts permanently removes products soft deleted before the
+// given time that no order line references. Products that were ever sold are
+// kept forever for the order history. The images of the purged products are
+// returned so the caller can remove their blobs, the rows go with the product.
+func (r *productRepository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) (int64, []entity.ProductImage, error) {
+	var purged int64
+	var images []entity.ProductImage
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		purgeable := tx.Unscoped().Model(&entity.Product{}).Select("id").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Where("NOT EXISTS (SELECT 1 FROM order_products op WHERE op.product_id = products.id)")
+
+		if err := tx.Preload("Thumbnails").Where("product_id IN (?)", purgeable).Find(&images).Error; err != nil {
+			return errors.Wrap(err, "[ProductRepository.PurgeDeletedProducts]: failed to get product images")
+		}
+
+		result := tx.Unscoped().Where("id IN (?)", purgeable).Delete(&entity.Product{})
+		if err := result.Error; err != nil {
+			return errors.Wrap(err, "[ProductRepository.PurgeDeletedProducts]: failed to purge deleted products")
+		}
+		purged = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return purged, images, nil
+}
+
+func (r *productRepository) GetAllProducts(ctx context.Context) (products []entity.ProductWithOutShop, err error) {
+	if err := database.Conn(ctx, r.db).Model(&entity.Product{}).Where(activeShop).Find(&products).Error; err != nil {
 		err = errors.Wrap(err, "[ProductRepository.GetAllProducts]: failed to get all products")
 		return nil, err
 	}
 	return products, nil
 }
+
+func (r *productRepository) GetVariantsByProductID(ctx context.Context, productID uint32) (variants []entity.ProductVariant, err error) {
+	if err := database.Conn(ctx, r.db).Preload("OptionValues.OptionType").
+		Where("product_id = ?", productID).
+		Order("id").
+		Find(&variants).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetVariantsByProductID]: failed to get variants by product id")
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (r *productRepository) GetVariantByID(ctx context.Context, variantID uint32) (variant entity.ProductVariant, err error) {
+	if err := database.Conn(ctx, r.db).Preload("OptionValues.OptionType").
+		Where("product_id IN (?)", r.db.Model(&entity.Product{}).Select("id").Where(activeShop)).
+		First(&variant, variantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ProductRepository.GetVariantByID]: variant not found")
+			return entity.ProductVariant{}, err
+		}
+		err = errors.Wrap(err, "[ProductRepository.GetVariantByID]: failed to get variant by id")
+		return entity.ProductVariant{}, err
+	}
+	return variant, nil
+}
+
+func (r *productRepository) HasVariants(ctx context.Context, productID uint32) (bool, error) {
+	var exists bool
+	if err := database.Conn(ctx, r.db).Model(&entity.ProductVariant{}).
+		Select("count(*) > 0").
+		Where("product_id = ?", productID).
+		Find(&exists).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.HasVariants]: failed to check product variants")
+		return false, err
+	}
+	return exists, nil
+}
+
+// CreateVariant creates the variant together with any option type or value
+// it names that the product does not have yet.
+func (r *productRepository) CreateVariant(ctx context.Context, productID uint32, req entity.VariantRequest) (entity.ProductVariant, error) {
+	variant := entity.ProductVariant{
+		ProductID: productID,
+		SKU:       req.SKU,
+		Price:     req.Price,
+		Stock:     req.Stock,
+	}
+
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		values, err := findOrCreateOptionValues(tx, productID, req.Options)
+		if err != nil {
+			return err
+		}
+		variant.OptionValues = values
+
+		if err := tx.Omit("OptionValues.*").Create(&variant).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errors.New("[ProductRepository.CreateVariant]: variant already exists")
+			}
+			return errors.Wrap(err, "[ProductRepository.CreateVariant]: failed to create variant")
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.ProductVariant{}, err
+	}
+	return variant, nil
+}
+
+// UpdateVariant overwrites SKU, price and stock, and the option values when
+// the request names any.
+func (r *productRepository) UpdateVariant(ctx context.Context, req *entity.VariantManagementRequest, variant entity.VariantRequest) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		existing := entity.ProductVariant{}
+		if err := tx.Where("id = ? AND product_id = ?", req.VariantID, req.ProductID).First(&existing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("[ProductRepository.UpdateVariant]: variant not found")
+			}
+			return errors.Wrap(err, "[ProductRepository.UpdateVariant]: failed to get variant")
+		}
+
+		if err := tx.Model(&existing).Select("SKU", "Price", "Stock").Updates(entity.ProductVariant{
+			SKU:   variant.SKU,
+			Price: variant.Price,
+			Stock: variant.Stock,
+		}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errors.New("[ProductRepository.UpdateVariant]: variant already exists")
+			}
+			return errors.Wrap(err, "[ProductRepository.UpdateVariant]: failed to update variant")
+		}
+
+		if len(variant.Options) == 0 {
+			return nil
+		}
+
+		values, err := findOrCreateOptionValues(tx, req.ProductID, variant.Options)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&existing).Omit("OptionValues.*").Association("OptionValues").Replace(values); err != nil {
+			return errors.Wrap(err, "[ProductRepository.UpdateVariant]: failed to replace option values")
+		}
+		return nil
+	})
+}
+
+func (r *productRepository) DeleteVariant(ctx context.Context, req *entity.VariantManagementRequest) error {
+	result := database.Conn(ctx, r.db).Where("id = ? AND product_id = ?", req.VariantID, req.ProductID).Delete(&entity.ProductVariant{})
+	if err := result.Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.DeleteVariant]: failed to delete variant")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ProductRepository.DeleteVariant]: variant not found")
+	}
+	return nil
+}
+
+func findOrCreateOptionValues(tx *gorm.DB, productID uint32, options map[string]string) ([]entity.ProductOptionValue, error) {
+	values := make([]entity.ProductOptionValue, 0, len(options))
+	for name, value := range options {
+		optionType := entity.ProductOptionType{ProductID: productID, Name: name}
+		if err := tx.Where(optionType).FirstOrCreate(&optionType).Error; err != nil {
+			return nil, errors.Wrap(err, "[ProductRepository.findOrCreateOptionValues]: failed to get option type")
+		}
+
+		optionValue := entity.ProductOptionValue{OptionTypeID: optionType.ID, Value: value}
+		if err := tx.Where(optionValue).FirstOrCreate(&optionValue).Error; err != nil {
+			return nil, errors.Wrap(err, "[ProductRepository.findOrCreateOptionValues]: failed to get option value")
+		}
+		values = append(values, optionValue)
+	}
+	return values, nil
+}
+
+func (r *productRepository) CreateProductImage(ctx context.Context, image *entity.ProductImage) error {
+	if err := database.Conn(ctx, r.db).Omit("Product").Create(image).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.CreateProductImage]: failed to create product image")
+		return err
+	}
+	return nil
+}
+
+func (r *productRepository) CountProductImages(ctx context.Context, productID uint32) (int64, error) {
+	var count int64
+	if err := database.Conn(ctx, r.db).Model(&entity.ProductImage{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.CountProductImages]: failed to count product images")
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetImagesByProductIDs loads the images of several products in one query,
+// oldest first
+func (r *productRepository) GetImagesByProductIDs(ctx context.Context, productIDs []uint32) (images []entity.ProductImage, err error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+	if err := database.Conn(ctx, r.db).Preload("Thumbnails").
+		Where("product_id IN ?", productIDs).
+		Order("id").
+		Find(&images).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetImagesByProductIDs]: failed to get product images")
+		return nil, err
+	}
+	return images, nil
+}
+
+// DeleteProductImage removes the image row and its thumbnails, returning
+// them so the caller can remove the blobs.
+func (r *productRepository) DeleteProductImage(ctx context.Context, req *entity.ProductImageManagementRequest) (entity.ProductImage, error) {
+	image := entity.ProductImage{}
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Thumbnails").
+			Where("id = ? AND product_id = ?", req.ImageID, req.ProductID).
+			First(&image).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("[ProductRepository.DeleteProductImage]: image not found")
+			}
+			return errors.Wrap(err, "[ProductRepository.DeleteProductImage]: failed to get image")
+		}
+
+		if err := tx.Select("Thumbnails").Delete(&image).Error; err != nil {
+			return errors.Wrap(err, "[ProductRepository.DeleteProductImage]: failed to delete image")
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.ProductImage{}, err
+	}
+	return image, nil
+}
+
+// findOrCreateTags resolves tag names to tags by slug, names that slugify to
+// nothing are dropped and duplicates collapse into one tag.
+func findOrCreateTags(tx *gorm.DB, names []string) ([]entity.Tag, error) {
+	tags := make([]entity.Tag, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		tag := entity.Tag{}
+		if err := tx.Where(entity.Tag{Slug: slug}).Attrs(entity.Tag{Name: strings.TrimSpace(name)}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, errors.Wrap(err, "[ProductRepository.findOrCreateTags]: failed to get tag")
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (r *productRepository) GetProductPrices(ctx context.Context, shopID uint32, productIDs []uint32) (map[uint32]uint32, error) {
+	products := []entity.Product{}
+	if len(productIDs) > 0 {
+		if err := database.Conn(ctx, r.db).Select("id", "price").
+			Where("shop_id = ? AND id IN ?", shopID, productIDs).
+			Find(&products).Error; err != nil {
+			err = errors.Wrap(err, "[ProductRepository.GetProductPrices]: failed to get product prices")
+			return nil, err
+		}
+	}
+
+	prices := make(map[uint32]uint32, len(products))
+	for _, product := range products {
+		prices[product.ID] = product.Price
+	}
+	return prices, nil
+}
+
+// GetProductIDsBySKUs maps the SKUs of the shop's live products to their ids,
+// unknown SKUs are left out
+func (r *productRepository) GetProductIDsBySKUs(ctx context.Context, shopID uint32, skus []string) (map[string]uint32, error) {
+	products := []entity.Product{}
+	if len(skus) > 0 {
+		if err := database.Conn(ctx, r.db).Select("id", "sku").
+			Where("shop_id = ? AND sku IN ?", shopID, skus).
+			Find(&products).Error; err != nil {
+			err = errors.Wrap(err, "[ProductRepository.GetProductIDsBySKUs]: failed to get products by sku")
+			return nil, err
+		}
+	}
+
+	ids := make(map[string]uint32, len(products))
+	for _, product := range products {
+		ids[*product.SKU] = product.ID
+	}
+	return ids, nil
+}
+
+func (r *productRepository) GetCategoryIDsBySlugs(ctx context.Context, slugs []string) (map[string]uint32, error) {
+	categories := []entity.Category{}
+	if len(slugs) > 0 {
+		if err := database.Conn(ctx, r.db).Select("id", "slug").
+			Where("slug IN ?", slugs).
+			Find(&categories).Error; err != nil {
+			err = errors.Wrap(err, "[ProductRepository.GetCategoryIDsBySlugs]: failed to get categories by slug")
+			return nil, err
+		}
+	}
+
+	ids := make(map[string]uint32, len(categories))
+	for _, category := range categories {
+		ids[category.Slug] = category.ID
+	}
+	return ids, nil
+}
+
+// ImportProducts creates the products without an id and overwrites name,
+// description, price, category and tags of the others, all or nothing.
+func (r *productRepository) ImportProducts(ctx context.Context, shopID uint32, products []entity.Product) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		for i := range products {
+			product := &products[i]
+			product.ShopID = shopID
+
+			tags, err := findOrCreateTags(tx, product.TagNames)
+			if err != nil {
+				return err
+			}
+
+			if product.ID == 0 {
+				product.Tags = tags
+				if err := tx.Omit("Category", "Tags.*").Create(product).Error; err != nil {
+					if errors.Is(err, gorm.ErrDuplicatedKey) {
+						return errors.New("[ProductRepository.ImportProducts]: sku already exists")
+					}
+					return errors.Wrap(err, "[ProductRepository.ImportProducts]: failed to create product")
+				}
+				continue
+			}
+
+			if err := tx.Model(product).Where("shop_id = ?", shopID).
+				Select("Name", "Description", "Price", "CategoryID").
+				Updates(product).Error; err != nil {
+				return errors.Wrap(err, "[ProductRepository.ImportProducts]: failed to update product")
+			}
+			if err := tx.Model(product).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+				return errors.Wrap(err, "[ProductRepository.ImportProducts]: failed to replace tags")
+			}
+		}
+		return nil
+	})
+}
+
+// ExportProducts hands the shop's live products to fn in batches, loaded with
+// their category and tags, so large catalogs never sit in memory at once.
+func (r *productRepository) ExportProducts(ctx context.Context, shopID uint32, fn func([]entity.Product) error) error {
+	products := []entity.Product{}
+	result := database.Conn(ctx, r.db).Preload("Category").Preload("Tags").
+		Where("shop_id = ?", shopID).
+		FindInBatches(&products, 500, func(tx *gorm.DB, batch int) error {
+			return fn(products)
+		})
+	if err := result.Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.ExportProducts]: failed to export products")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// GetProductPageByShopID fetches one row past the limit so the caller can
+// tell whether there is a next page.
+func (r *productRepository) GetProductPageByShopID(ctx context.Context, shopID uint32, limit int, afterID uint32) (products []entity.ProductWithOutShop, err error) {
+	query := r.db.WithContext(ctx).Model(&entity.Product{}).Where("shop_id = ?", shopID)
+	if afterID > 0 {
+		query = query.Where("id > ?", afterID)
+	}
+	if err := query.Order("id").Limit(limit + 1).Find(&products).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetProductPageByShopID]: failed to get products by shop id")
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *productRepository) HasCompletedOrder(ctx context.Context, userID uint32, productID uint32) (bool, error) {
+	var exists bool
+	if err := r.db.WithContext(ctx).Table("order_products op").
+		Select("count(*) > 0").
+		Joins("JOIN orders o ON o.id = op.order_id").
+		Where("o.user_id = ? AND o.status = ? AND op.product_id = ?", userID, entity.COMPLETED, productID).
+		Find(&exists).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.HasCompletedOrder]: failed to check completed orders")
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r *productRepository) CreateReview(ctx context.Context, review *entity.ProductReview) error {
+	if err := r.db.WithContext(ctx).Omit("Product", "User").Create(review).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.New("[ProductRepository.CreateReview]: review already exists")
+		}
+		return errors.Wrap(err, "[ProductRepository.CreateReview]: failed to create review")
+	}
+	return nil
+}
+
+func (r *productRepository) GetReviewsByProductID(ctx context.Context, productID uint32) (reviews []entity.ProductReview, err error) {
+	if err := r.db.WithContext(ctx).
+		Where("product_id = ?", productID).
+		Order("created_at DESC, id DESC").
+		Find(&reviews).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetReviewsByProductID]: failed to get reviews")
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *productRepository) GetRatingCounts(ctx context.Context, productID uint32) (counts []entity.RatingCount, err error) {
+	if err := r.db.WithContext(ctx).Model(&entity.ProductReview{}).
+		Select("rating, count(*) AS count").
+		Where("product_id = ?", productID).
+		Group("rating").
+		Scan(&counts).Error; err != nil {
+		err = errors.Wrap(err, "[ProductRepository.GetRatingCounts]: failed to summarise ratings")
+		return nil, err
+	}
+	return counts, nil
+}
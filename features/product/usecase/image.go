@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+)
+
+// ImageResponse resolves the blob keys of an image loaded with its
+// thumbnails into URLs
+func ImageResponse(store domain.BlobStore, image entity.ProductImage) entity.ProductImageResponse {
+	thumbnails := make(map[string]string, len(image.Thumbnails))
+	for _, thumbnail := range image.Thumbnails {
+		thumbnails[thumbnail.Size] = store.URL(thumbnail.Key)
+	}
+	return entity.ProductImageResponse{
+		ID:         image.ID,
+		URL:        store.URL(image.Key),
+		Width:      image.Width,
+		Height:     image.Height,
+		Thumbnails: thumbnails,
+	}
+}
+
+// AttachImages fills in the Images of every product with a single query.
+// It is shared by the listings of the product and shop features.
+func AttachImages(ctx context.Context, productRepo domain.ProductRepository, store domain.BlobStore, products []entity.ProductWithOutShop) error {
+	productIDs := make([]uint32, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+
+	images, err := productRepo.GetImagesByProductIDs(ctx, productIDs)
+	if err != nil {
+		return errors.Wrap(err, "[ProductUsecase.AttachImages]: failed to get product images")
+	}
+
+	byProduct := make(map[uint32][]entity.ProductImageResponse, len(products))
+	for _, image := range images {
+		byProduct[image.ProductID] = append(byProduct[image.ProductID], ImageResponse(store, image))
+	}
+	for i := range products {
+		products[i].Images = byProduct[products[i].ID]
+		if products[i].Images == nil {
+			products[i].Images = []entity.ProductImageResponse{}
+		}
+	}
+	return nil
+}
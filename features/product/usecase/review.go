@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func (u *productUsecase) CreateReview(ctx context.Context, userID uint32, productID uint32, req entity.ReviewRequest) (entity.ReviewResponse, error) {
+	log.WithFields(log.Fields{
+		"userID":    userID,
+		"productID": productID,
+	}).Debug("Creating review")
+
+	if req.Rating < 1 || req.Rating > 5 {
+		return entity.ReviewResponse{}, errors.New("[ProductUsecase.CreateReview]: rating must be between 1 and 5")
+	}
+
+	if _, err := u.productRepo.GetActiveProductByID(ctx, productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ReviewResponse{}, errors.New("[ProductUsecase.CreateReview]: product not found")
+		}
+		err = errors.Wrap(err, "[ProductUsecase.CreateReview]: failed to get product by id")
+		return entity.ReviewResponse{}, err
+	}
+
+	ordered, err := u.productRepo.HasCompletedOrder(ctx, userID, productID)
+	if err != nil {
+		err = errors.Wrap(err, "[ProductUsecase.CreateReview]: failed to check completed orders")
+		return entity.ReviewResponse{}, err
+	}
+	if !ordered {
+		return entity.ReviewResponse{}, errors.New("[ProductUsecase.CreateReview]: product not purchased")
+	}
+
+	review := entity.ProductReview{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    req.Rating,
+		Comment:   req.Comment,
+	}
+	if err := u.productRepo.CreateReview(ctx, &review); err != nil {
+		if err.Error() == "[ProductRepository.CreateReview]: review already exists" {
+			return entity.ReviewResponse{}, errors.New("[ProductUsecase.CreateReview]: review already exists")
+		}
+		err = errors.Wrap(err, "[ProductUsecase.CreateReview]: failed to create review")
+		return entity.ReviewResponse{}, err
+	}
+
+	return toReviewResponse(review), nil
+}
+
+func (u *productUsecase) GetReviews(ctx context.Context, productID uint32) (entity.ProductReviews, error) {
+	if _, err := u.productRepo.GetActiveProductByID(ctx, productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ProductReviews{}, errors.New("[ProductUsecase.GetReviews]: product not found")
+		}
+		err = errors.Wrap(err, "[ProductUsecase.GetReviews]: failed to get product by id")
+		return entity.ProductReviews{}, err
+	}
+
+	counts, err := u.productRepo.GetRatingCounts(ctx, productID)
+	if err != nil {
+		err = errors.Wrap(err, "[ProductUsecase.GetReviews]: failed to summarise ratings")
+		return entity.ProductReviews{}, err
+	}
+
+	reviews, err := u.productRepo.GetReviewsByProductID(ctx, productID)
+	if err != nil {
+		err = errors.Wrap(err, "[ProductUsecase.GetReviews]: failed to get reviews")
+		return entity.ProductReviews{}, err
+	}
+
+	response := entity.ProductReviews{
+		Rating:  entity.NewRatingSummary(counts),
+		Reviews: make([]entity.ReviewResponse, 0, len(reviews)),
+	}
+	for _, review := range reviews {
+		response.Reviews = append(response.Reviews, toReviewResponse(review))
+	}
+	return response, nil
+}
+
+func toReviewResponse(review entity.ProductReview) entity.ReviewResponse {
+	return entity.ReviewResponse{
+		ID:        review.ID,
+		UserID:    review.UserID,
+		Rating:    review.Rating,
+		Comment:   review.Comment,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
@@ -1,45 +1,119 @@
 package usecase
 
 import (
+	"context"
+	"time"
+
 	"order-management/domain"
 	"order-management/entity"
 
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
 )
 
 type productUsecase struct {
 	productRepo domain.ProductRepository
+	store       domain.BlobStore
 }
 
-func NewProductUsecase(productRepo domain.ProductRepository) domain.ProductUsecase {
+func NewProductUsecase(productRepo domain.ProductRepository, store domain.BlobStore) domain.ProductUsecase {
 	return &productUsecase{
 		productRepo: productRepo,
+		store:       store,
 	}
 }
 
-func (u *productUsecase) GetProductByID(productID uint32) (entity.Product, error) {
-	product, err := u.productRepo.GetProductByID(productID)
+func (u *productUsecase) GetProductByID(ctx context.Context, productID uint32) (entity.Product, error) {
+	product, err := u.productRepo.GetActiveProductByID(ctx, productID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ProductUsecase.GetProductByID]: product not found")
+			return entity.Product{}, err
+		}
 		err = errors.Wrap(err, "[ProductUsecase.GetProductByID]: failed to get product by id")
 		return entity.Product{}, err
 	}
 	return product, nil
 }
 
-func (u *productUsecase) GetAllProducts() ([]entity.ProductWithOutShop, error) {
-	products, err := u.productRepo.GetAllProducts()
+func (u *productUsecase) GetAllProducts(ctx context.Context) ([]entity.ProductWithOutShop, error) {
+	products, err := u.productRepo.GetAllProducts(ctx)
 	if err != nil {
 		err = errors.Wrap(err, "[ProductUsecase.GetAllProducts]: failed to get all products")
 		return nil, err
 	}
+	if err := AttachImages(ctx, u.productRepo, u.store, products); err != nil {
+		err = errors.Wrap(err, "[ProductUsecase.GetAllProducts]: failed to attach images")
+		return nil, err
+	}
 	return products, nil
 }
 
-func (u *productUsecase) GetProductPrice(productID uint32) (float64, error) {
-	price, err := u.productRepo.GetProductPrice(productID)
+func (u *productUsecase) GetProductPrice(ctx context.Context, productID uint32) (float64, error) {
+	price, err := u.productRepo.GetProductPrice(ctx, productID)
 	if err != nil {
 		err = errors.Wrap(err, "[ProductUsecase.GetProductPrice]: failed to get product price")
 		return 0, err
 	}
 	return price, nil
 }
+
+// PurgeDeletedProducts removes soft deleted products that were never ordered
+// once they have been deleted for longer than product.purgeafter (30 days by
+// default), leaving shops that window to restore them.
+func (u *productUsecase) PurgeDeletedProducts(ctx context.Context) (int64, error) {
+	purgeAfter := viper.GetDuration("product.purgeafter")
+	if purgeAfter <= 0 {
+		purgeAfter = 30 * 24 * time.Hour
+	}
+
+	purged, images, err := u.productRepo.PurgeDeletedProducts(ctx, time.Now().Add(-purgeAfter))
+	if err != nil {
+		err = errors.Wrap(err, "[ProductUsecase.PurgeDeletedProducts]: failed to purge deleted products")
+		return 0, err
+	}
+
+	for _, image := range images {
+		keys := []string{image.Key}
+		for _, thumbnail := range image.Thumbnails {
+			keys = append(keys, thumbnail.Key)
+		}
+		for _, key := range keys {
+			if err := u.store.Delete(ctx, key); err != nil {
+				log.WithError(err).WithField("key", key).Warn("Failed to remove image blob of purged product")
+			}
+		}
+	}
+	return purged, nil
+}
+
+func (u *productUsecase) GetVariants(ctx context.Context, productID uint32) ([]entity.VariantResponse, error) {
+	if _, err := u.productRepo.GetActiveProductByID(ctx, productID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ProductUsecase.GetVariants]: product not found")
+			return nil, err
+		}
+		err = errors.Wrap(err, "[ProductUsecase.GetVariants]: failed to get product by id")
+		return nil, err
+	}
+
+	variants, err := u.productRepo.GetVariantsByProductID(ctx, productID)
+	if err != nil {
+		err = errors.Wrap(err, "[ProductUsecase.GetVariants]: failed to get variants")
+		return nil, err
+	}
+
+	variantsResponse := make([]entity.VariantResponse, 0, len(variants))
+	for _, variant := range variants {
+		variantsResponse = append(variantsResponse, entity.VariantResponse{
+			ID:      variant.ID,
+			SKU:     variant.SKU,
+			Price:   variant.Price,
+			Stock:   variant.Stock,
+			Options: variant.Options(),
+		})
+	}
+	return variantsResponse, nil
+}
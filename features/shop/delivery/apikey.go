@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+func (h *Handler) GetAPIKeys(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetAPIKeys]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	keys, err := h.usecase.GetAPIKeys(c.Request().Context(), shop.ID)
+	if err != nil {
+		return apiKeyError(c, errors.Wrap(err, "[Handler.GetAPIKeys]: failed to get api keys"), shop.ID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.CreateAPIKey]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.APIKeyRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.CreateAPIKey]: invalid request body")
+
+		log.WithError(err).Warn("Invalid api key request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	key, err := h.usecase.CreateAPIKey(c.Request().Context(), shop, req)
+	if err != nil {
+		return apiKeyError(c, errors.Wrap(err, "[Handler.CreateAPIKey]: failed to create api key"), shop.ID)
+	}
+
+	return c.JSON(http.StatusCreated, entity.Response{
+		Success: true,
+		Message: "API key created, store it safely as it is not shown again",
+		Data:    key,
+		Status:  http.StatusCreated,
+	})
+}
+
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	keyID, err := strconv.ParseUint(c.Param("key_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.RevokeAPIKey]: invalid key id")
+
+		log.WithError(err).Warn("Invalid api key ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.RevokeAPIKey]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := &entity.APIKeyManagementRequest{ShopID: shop.ID, KeyID: uint32(keyID)}
+	if err := h.usecase.RevokeAPIKey(c.Request().Context(), req); err != nil {
+		return apiKeyError(c, errors.Wrap(err, "[Handler.RevokeAPIKey]: failed to revoke api key"), shop.ID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "API key revoked successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func apiKeyError(c echo.Context, err error, shopID uint32) error {
+	fields := log.Fields{
+		"shopID": shopID,
+	}
+
+	switch utils.StandardError(err) {
+	case "name is required", "invalid scope", "invalid rate limit":
+		log.WithFields(fields).WithError(err).Warn("Invalid api key request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "api key not found":
+		log.WithFields(fields).WithError(err).Warn("API key not found")
+
+		return c.JSON(http.StatusNotFound, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	default:
+		log.WithFields(fields).WithError(err).Error("Internal server error while managing api keys")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+}
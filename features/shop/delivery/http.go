@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"order-management/domain"
 	"order-management/entity"
+	orderDelivery "order-management/features/order/delivery"
 	"order-management/utils"
 	"strconv"
 
@@ -17,28 +18,86 @@ import (
 )
 
 type Handler struct {
-	usecase      domain.ShopUsecase
-	orderUsecase domain.OrderUsecase
+	usecase        domain.ShopUsecase
+	orderUsecase   domain.OrderUsecase
+	webhookUsecase domain.WebhookUsecase
 }
 
-func NewHandler(e *echo.Group, u domain.ShopUsecase, o domain.OrderUsecase) *Handler {
-	h := Handler{usecase: u}
+// NewHandler registers the shop routes. keyLimit limits the requests of
+// API keys, see middleware.APIKeyRateLimit.
+func NewHandler(e *echo.Group, u domain.ShopUsecase, o domain.OrderUsecase, w domain.WebhookUsecase, keyLimit echo.MiddlewareFunc) *Handler {
+	h := Handler{usecase: u, orderUsecase: o, webhookUsecase: w}
 	// Public group - no authentication required
 	publicGroup := e.Group("")
 	publicGroup.GET("", h.GetAllShops)                           // Anyone can view shops
 	publicGroup.POST("/register", h.CreateShop)                  // Public registration
 	publicGroup.POST("/login", h.Login)                          // Public login
+	publicGroup.POST("/login/mfa", h.LoginMFA)                   // Second step of a login with two-factor authentication
+	publicGroup.POST("/login/mfa/enrol", h.EnrolMFALogin)        // Enrolment during login while admins require it
 	publicGroup.GET("/:shop_id/products", h.GetProductsByShopID) // Anyone can view products
+	publicGroup.GET("/:slug", h.GetStorefront)                   // Public shop page
 
-	// Authenticated group - requires JWT
+	// Authenticated group - requires JWT or an API key, each route checks the
+	// permission of the member's role and the key's scopes
 	authGroup := e.Group("")
-	authGroup.Use(middleware.ShopAuth())
-	authGroup.POST("/products", h.CreateProduct)               // Only shop owner can create products
-	authGroup.PUT("/products/:product_id", h.UpdateProduct)    // Only shop owner can update their products
-	authGroup.DELETE("/products/:product_id", h.DeleteProduct) // Only shop owner can delete their products
-	authGroup.GET("/me", h.ReadToken)                          // Get current shop profile from JWT
-	authGroup.POST("/logout", h.Logout)                        // Logout requires JWT
-	authGroup.GET("/profile", h.GetShopProfile)                // Get detailed profile requires JWT
+	authGroup.Use(middleware.ShopAuth(u), keyLimit, h.VerifyToken)
+	authGroup.GET("/me", h.ReadToken, h.TokenOnly)                                 // Get current shop profile from JWT
+	authGroup.POST("/logout", h.Logout, h.TokenOnly)                               // Logout requires JWT
+	authGroup.GET("/profile", h.GetShopProfile, h.TokenOnly)                       // Get detailed profile requires JWT
+	authGroup.GET("/orders", h.GetOrders, h.Require(entity.FulfilOrders))          // Orders containing the shop's products
+	authGroup.GET("/finances", h.GetFinances, h.Require(entity.ViewFinances))      // Revenue from the shop's products
+	authGroup.PUT("/me", h.UpdateProfile, h.Require(entity.ManageShop))            // Name and description
+	authGroup.POST("/me/password", h.ChangePassword, h.Require(entity.ManageShop)) // Revokes every other token of the shop
+	authGroup.POST("/me/deactivate", h.Deactivate, h.Require(entity.ManageShop))   // Hides the shop and its products from the public
+	authGroup.POST("/me/reactivate", h.Reactivate, h.Require(entity.ManageShop))
+	authGroup.GET("/me/mfa", h.GetMFAStatus, h.Require(entity.ManageShop))
+	authGroup.POST("/me/mfa", h.EnrolMFA, h.Require(entity.ManageShop))                               // Returns the secret to confirm
+	authGroup.POST("/me/mfa/confirm", h.ConfirmMFA, h.Require(entity.ManageShop))                     // Returns the recovery codes
+	authGroup.POST("/me/mfa/disable", h.DisableMFA, h.Require(entity.ManageShop))                     // Refused while admins require it
+	authGroup.POST("/me/mfa/recovery-codes", h.RegenerateRecoveryCodes, h.Require(entity.ManageShop)) // Replaces all recovery codes
+	authGroup.GET("/me/api-keys", h.GetAPIKeys, h.Require(entity.ManageShop))
+	authGroup.POST("/me/api-keys", h.CreateAPIKey, h.Require(entity.ManageShop))           // Returns the key once
+	authGroup.DELETE("/me/api-keys/:key_id", h.RevokeAPIKey, h.Require(entity.ManageShop)) // Takes effect on the next request
+
+	// PENDING to SHIPPING or CANCELLED, SHIPPING to COMPLETED
+	authGroup.PUT("/orders/:order_id/status", h.UpdateOrderStatus, h.Require(entity.FulfilOrders))
+
+	productGroup := authGroup.Group("/products", h.Require(entity.ManageProducts))
+	productGroup.POST("", h.CreateProduct)
+	productGroup.PUT("/:product_id", h.UpdateProduct)    // Only the shop's own products
+	productGroup.DELETE("/:product_id", h.DeleteProduct) // Only the shop's own products
+	productGroup.GET("/deleted", h.GetDeletedProducts)   // Soft deleted products that can still be restored
+	productGroup.POST("/import", h.ImportProducts)       // Bulk create or update by SKU
+	productGroup.GET("/export", h.ExportProducts)        // Catalog as CSV
+	productGroup.POST("/:product_id/restore", h.RestoreProduct)
+	productGroup.POST("/:product_id/variants", h.CreateVariant)
+	productGroup.PUT("/:product_id/variants/:variant_id", h.UpdateVariant)
+	productGroup.DELETE("/:product_id/variants/:variant_id", h.DeleteVariant)
+	productGroup.POST("/:product_id/images", h.UploadProductImage)
+	productGroup.DELETE("/:product_id/images/:image_id", h.DeleteProductImage)
+
+	webhookGroup := authGroup.Group("/webhooks", h.Require(entity.ManageShop))
+	webhookGroup.GET("", h.GetWebhooks)
+	webhookGroup.POST("", h.CreateWebhook) // Returns the signing secret once
+	webhookGroup.PUT("/:webhook_id", h.UpdateWebhook)
+	webhookGroup.DELETE("/:webhook_id", h.DeleteWebhook)
+	webhookGroup.GET("/:webhook_id/deliveries", h.GetWebhookDeliveries)                     // Delivery log, latest first
+	webhookGroup.POST("/:webhook_id/deliveries/:delivery_id/redeliver", h.RedeliverWebhook) // Queues the delivery again
+
+	memberGroup := authGroup.Group("/members", h.Require(entity.ManageMembers))
+	memberGroup.GET("", h.GetMembers)
+	memberGroup.POST("", h.AddMember) // Existing users by email
+	memberGroup.PUT("/:member_id", h.UpdateMember)
+	memberGroup.DELETE("/:member_id", h.RemoveMember)
+
+	// Accounts act for the shops they are members of by switching the
+	// active shop context of their user token
+	membershipGroup := e.Group("/memberships")
+	membershipGroup.Use(middleware.UserAuth())
+	membershipGroup.GET("", h.GetMemberships)
+	membershipGroup.POST("", h.CreateOwnedShop)      // New shop owned by the account
+	membershipGroup.POST("/link", h.LinkShop)        // Become owner of a shop by its name and password
+	membershipGroup.POST("/switch", h.SwitchContext) // shopId 0 switches back to buying
 
 	return &h
 }
@@ -63,12 +122,12 @@ func (h *Handler) GetShopProfile(c echo.Context) error {
 		})
 	}
 
-	log.WithField("shopName", shopClaims.Name).Debug("Attempting to retrieve shop profile")
+	log.WithField("shopID", shopClaims.ID).Debug("Attempting to retrieve shop profile")
 
-	shop, err := h.usecase.GetShopByName(shopClaims.Name)
+	shop, err := h.usecase.GetShopProfile(c.Request().Context(), shopClaims.ID)
 	if err != nil {
-		if err.Error() == "[ShopUsecase.GetShopByName]: shop not found" {
-			// If this happens, it means the shop name is not in the database
+		if err.Error() == "[ShopUsecase.GetShopProfile]: shop not found" {
+			// If this happens, it means the shop id is not in the database
 			// the JWT secret is compromised or the shop is deleted
 			err = errors.Wrap(err, "[Handler.GetShopProfile]: shop not found")
 
@@ -99,6 +158,138 @@ func (h *Handler) GetShopProfile(c echo.Context) error {
 	})
 }
 
+func (h *Handler) GetOrders(c echo.Context) error {
+	shopClaims, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetOrders]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	filter, err := orderDelivery.ParseOrderFilter(c)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetOrders]: invalid filter")
+
+		log.WithError(err).Warn("Invalid order filter")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	orders, err := h.orderUsecase.GetOrdersByShopID(c.Request().Context(), shopClaims.ID, filter)
+	if err != nil {
+		if err.Error() == "[OrderUsecase.GetOrdersByShopID]: invalid cursor" {
+			err = errors.Wrap(err, "[Handler.GetOrders]: invalid cursor")
+
+			log.WithError(err).Warn("Invalid order cursor")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.GetOrders]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shopClaims.ID,
+		}).WithError(err).Error("Internal server error while getting shop orders")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Orders retrieved successfully",
+		Data:    orders,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) UpdateOrderStatus(c echo.Context) error {
+	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateOrderStatus]: invalid order id")
+
+		log.WithError(err).Warn("Invalid order ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shopClaims, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.UpdateOrderStatus]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.OrderStatusRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateOrderStatus]: invalid request body")
+
+		log.WithError(err).Warn("Invalid order status request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	order, err := h.orderUsecase.UpdateOrderStatus(c.Request().Context(), shopClaims.ID, uint32(orderID), req.Status)
+	if err != nil {
+		fields := log.Fields{
+			"shopID":  shopClaims.ID,
+			"orderID": orderID,
+		}
+
+		switch utils.StandardError(err) {
+		case "invalid status":
+			log.WithFields(fields).WithError(err).Warn("Invalid order status")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "order not found":
+			log.WithFields(fields).WithError(err).Warn("Order not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "invalid status transition":
+			log.WithFields(fields).WithError(err).Warn("Invalid order status transition")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		err = errors.Wrap(err, "[Handler.UpdateOrderStatus]: internal server error")
+
+		log.WithFields(fields).WithError(err).Error("Internal server error while updating order status")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Order status updated successfully",
+		Data:    order,
+		Status:  http.StatusOK,
+	})
+}
+
 func (h *Handler) GetProductsByShopID(c echo.Context) error {
 	shopID, err := strconv.ParseUint(c.Param("shop_id"), 10, 32)
 	if err != nil {
@@ -111,7 +302,7 @@ func (h *Handler) GetProductsByShopID(c echo.Context) error {
 		})
 	}
 
-	products, err := h.usecase.GetProductsByShopID(uint32(shopID))
+	products, err := h.usecase.GetProductsByShopID(c.Request().Context(), uint32(shopID))
 	if err != nil {
 		if err.Error() == "[ShopUsecase.GetProductsByShopID]: shop not found" {
 			err = errors.Wrap(err, "[Handler.GetProductsByShopID]: shop not found")
@@ -171,7 +362,7 @@ func (h *Handler) DeleteProduct(c echo.Context) error {
 		ProductID: uint32(productID),
 	}
 
-	if err := h.usecase.DeleteProduct(&req); err != nil {
+	if err := h.usecase.DeleteProduct(c.Request().Context(), &req); err != nil {
 		switch err.Error() {
 		case "[ShopUsecase.DeleteProduct]: shop not found":
 			err = errors.Wrap(err, "[Handler.DeleteProduct]: shop not found")
@@ -225,6 +416,111 @@ func (h *Handler) DeleteProduct(c echo.Context) error {
 	})
 }
 
+func (h *Handler) RestoreProduct(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.RestoreProduct]: invalid product id")
+
+		log.WithError(err).Warn("Invalid product ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.RestoreProduct]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ProductManagementRequest{
+		ShopID:    shop.ID,
+		ProductID: uint32(productID),
+	}
+
+	if err := h.usecase.RestoreProduct(c.Request().Context(), &req); err != nil {
+		if err.Error() == "[ShopUsecase.RestoreProduct]: sku already exists" {
+			err = errors.Wrap(err, "[Handler.RestoreProduct]: sku already exists")
+
+			log.WithFields(log.Fields{
+				"shopID":    shop.ID,
+				"productID": productID,
+			}).WithError(err).Warn("SKU taken by another product")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		if err.Error() == "[ShopUsecase.RestoreProduct]: product not found" {
+			err = errors.Wrap(err, "[Handler.RestoreProduct]: product not found")
+
+			log.WithFields(log.Fields{
+				"shopID":    shop.ID,
+				"productID": productID,
+			}).WithError(err).Warn("Deleted product not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.RestoreProduct]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID":    shop.ID,
+			"productID": productID,
+		}).WithError(err).Error("Internal server error while restoring product")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Product restored successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) GetDeletedProducts(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetDeletedProducts]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	products, err := h.usecase.GetDeletedProducts(c.Request().Context(), shop.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetDeletedProducts]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while getting deleted products")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Deleted products retrieved successfully",
+		Data:    products,
+		Status:  http.StatusOK,
+	})
+}
+
 func (h *Handler) UpdateProduct(c echo.Context) error {
 	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
 	if err != nil {
@@ -264,7 +560,7 @@ func (h *Handler) UpdateProduct(c echo.Context) error {
 		ProductID: uint32(productID),
 	}
 
-	if err := h.usecase.UpdateProduct(&req, &product); err != nil {
+	if err := h.usecase.UpdateProduct(c.Request().Context(), &req, &product); err != nil {
 		switch err.Error() {
 		case "[ShopUsecase.UpdateProduct]: shop not found":
 			err = errors.Wrap(err, "[Handler.UpdateProduct]: shop not found")
@@ -286,6 +582,28 @@ func (h *Handler) UpdateProduct(c echo.Context) error {
 			return c.JSON(http.StatusNotFound, entity.ResponseError{
 				Error: utils.StandardError(err),
 			})
+		case "[ShopUsecase.UpdateProduct]: category not found":
+			err = errors.Wrap(err, "[Handler.UpdateProduct]: category not found")
+
+			log.WithFields(log.Fields{
+				"productID":  productID,
+				"categoryID": product.CategoryID,
+			}).WithError(err).Warn("Category not found")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.UpdateProduct]: sku already exists":
+			err = errors.Wrap(err, "[Handler.UpdateProduct]: sku already exists")
+
+			log.WithFields(log.Fields{
+				"shopID":    shop.ID,
+				"productID": productID,
+			}).WithError(err).Warn("SKU taken by another product")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
 		case "[ShopUsecase.UpdateProduct]: product does not belong to shop":
 			err = errors.Wrap(err, "[Handler.UpdateProduct]: product does not belong to shop")
 
@@ -318,8 +636,17 @@ func (h *Handler) UpdateProduct(c echo.Context) error {
 	})
 }
 
+// Logout ends the cookie session of the shop's own credentials. Members
+// acting through their account log out on /users/logout. Bearer tokens are
+// not stored, clients just drop them.
 func (h *Handler) Logout(c echo.Context) error {
-	return c.NoContent(http.StatusOK)
+	middleware.ClearSession(c, middleware.ShopSessionCookie)
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Logout successful",
+		Status:  http.StatusOK,
+	})
 }
 
 func (h *Handler) CreateProduct(c echo.Context) error {
@@ -345,7 +672,32 @@ func (h *Handler) CreateProduct(c echo.Context) error {
 		})
 	}
 
-	if err := h.usecase.CreateProduct(req, shopClaims.ID); err != nil {
+	if err := h.usecase.CreateProduct(c.Request().Context(), req, shopClaims.ID); err != nil {
+		if err.Error() == "[ShopUsecase.CreateProduct]: category not found" {
+			err = errors.Wrap(err, "[Handler.CreateProduct]: category not found")
+
+			log.WithFields(log.Fields{
+				"shopID":     shopClaims.ID,
+				"categoryID": req.CategoryID,
+			}).WithError(err).Warn("Category not found")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		if err.Error() == "[ShopUsecase.CreateProduct]: sku already exists" {
+			err = errors.Wrap(err, "[Handler.CreateProduct]: sku already exists")
+
+			log.WithFields(log.Fields{
+				"shopID": shopClaims.ID,
+			}).WithError(err).Warn("SKU taken by another product")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
 		err = errors.Wrap(err, "[Handler.CreateProduct]: internal server error")
 
 		log.WithFields(log.Fields{
@@ -365,7 +717,7 @@ func (h *Handler) CreateProduct(c echo.Context) error {
 }
 
 func (h *Handler) GetAllShops(c echo.Context) error {
-	shops, err := h.usecase.GetAllShopsWithProducts()
+	shops, err := h.usecase.GetAllShopsWithProducts(c.Request().Context())
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = errors.Wrap(err, "[Handler.GetAllShops]: no shops found")
@@ -414,7 +766,7 @@ func (h *Handler) CreateShop(c echo.Context) error {
 		})
 	}
 
-	if err := h.usecase.CreateShop(req); err != nil {
+	if err := h.usecase.CreateShop(c.Request().Context(), req); err != nil {
 		// Check if the error message indicates a duplicate key
 		if err.Error() == "[ShopUsecase.CreateShop]: shop already exists" {
 			err = errors.Wrap(err, "[Handler.CreateShop]: shop already exists")
@@ -468,8 +820,21 @@ func (h *Handler) Login(c echo.Context) error {
 		})
 	}
 
-	token, err := h.usecase.Login(req.Name, req.Password)
+	login, err := h.usecase.Login(c.Request().Context(), req.Name, req.Password, c.RealIP())
 	if err != nil {
+		if locked := (*entity.LoginLockedError)(nil); errors.As(err, &locked) {
+			err = errors.Wrap(err, "[Handler.Login]: too many failed logins")
+
+			log.WithFields(log.Fields{
+				"shopName": req.Name,
+				"ip":       c.RealIP(),
+			}).WithError(err).Warn("Login refused after repeated failures")
+
+			c.Response().Header().Set("Retry-After", utils.RetryAfter(locked.RetryAfter))
+			return c.JSON(http.StatusTooManyRequests, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
 		if err.Error() == "[ShopUsecase.Login]: shop not found" {
 			err = errors.Wrap(err, "[Handler.Login]: shop not found")
 
@@ -503,7 +868,24 @@ func (h *Handler) Login(c echo.Context) error {
 		})
 	}
 
-	c.Response().Header().Set("Authorization", "Bearer "+token)
+	if login.Token == "" {
+		return c.JSON(http.StatusOK, entity.Response{
+			Success: true,
+			Message: "Two-factor authentication required",
+			Data:    login,
+			Status:  http.StatusOK,
+		})
+	}
+
+	if err := middleware.IssueToken(c, middleware.ShopSessionCookie, login.Token); err != nil {
+		err = errors.Wrap(err, "[Handler.Login]: failed to issue token")
+
+		log.WithError(err).Error("Failed to issue token")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
 
 	return c.JSON(http.StatusOK, entity.Response{
 		Success: true,
@@ -0,0 +1,175 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/entity"
+	"order-management/utils"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// UploadProductImage expects a multipart form with the file in the "image"
+// field. The content type is sniffed from the bytes, not taken from the form.
+func (h *Handler) UploadProductImage(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.UploadProductImage]: invalid product id")
+
+		log.WithError(err).Warn("Invalid product ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.UploadProductImage]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.UploadProductImage]: image file is required")
+
+		log.WithError(err).Warn("Missing image file")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.UploadProductImage]: failed to open image file")
+
+		log.WithError(err).Error("Failed to open uploaded image")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	defer file.Close()
+
+	req := entity.ProductManagementRequest{
+		ShopID:    shop.ID,
+		ProductID: uint32(productID),
+	}
+
+	image, err := h.usecase.UploadProductImage(c.Request().Context(), &req, file)
+	if err != nil {
+		return imageError(c, errors.Wrap(err, "[Handler.UploadProductImage]: failed to upload image"), req.ShopID, req.ProductID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Image uploaded successfully",
+		Data:    image,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) DeleteProductImage(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.DeleteProductImage]: invalid product id")
+
+		log.WithError(err).Warn("Invalid product ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	imageID, err := strconv.ParseUint(c.Param("image_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.DeleteProductImage]: invalid image id")
+
+		log.WithError(err).Warn("Invalid image ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.DeleteProductImage]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ProductImageManagementRequest{
+		ShopID:    shop.ID,
+		ProductID: uint32(productID),
+		ImageID:   uint32(imageID),
+	}
+
+	if err := h.usecase.DeleteProductImage(c.Request().Context(), &req); err != nil {
+		return imageError(c, errors.Wrap(err, "[Handler.DeleteProductImage]: failed to delete image"), req.ShopID, req.ProductID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Image deleted successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func imageError(c echo.Context, err error, shopID uint32, productID uint32) error {
+	fields := log.Fields{
+		"shopID":    shopID,
+		"productID": productID,
+	}
+
+	switch utils.StandardError(err) {
+	case "product not found", "image not found":
+		log.WithFields(fields).WithError(err).Warn("Image or product not found")
+
+		return c.JSON(http.StatusNotFound, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "product does not belong to shop":
+		log.WithFields(fields).WithError(err).Warn("Product does not belong to shop")
+
+		return c.JSON(http.StatusForbidden, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "unsupported image type", "invalid image":
+		log.WithFields(fields).WithError(err).Warn("Rejected image upload")
+
+		return c.JSON(http.StatusUnsupportedMediaType, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "image too large":
+		log.WithFields(fields).WithError(err).Warn("Image too large")
+
+		return c.JSON(http.StatusRequestEntityTooLarge, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "too many images":
+		log.WithFields(fields).WithError(err).Warn("Product image limit reached")
+
+		return c.JSON(http.StatusConflict, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	default:
+		log.WithFields(fields).WithError(err).Error("Internal server error while managing product image")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+}
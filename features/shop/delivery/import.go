@@ -0,0 +1,207 @@
+package delivery
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"order-management/entity"
+	"order-management/utils"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ImportProducts takes the file either as the raw body, typed by its
+// Content-Type, or as the "file" field of a multipart form, typed by its
+// extension. ?format=csv|jsonl overrides both, ?dryRun=true only validates.
+func (h *Handler) ImportProducts(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.ImportProducts]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	dryRun := false
+	if value := c.QueryParam("dryRun"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			err = errors.Wrap(err, "[Handler.ImportProducts]: invalid dryRun")
+
+			log.WithError(err).Warn("Invalid dryRun parameter")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		dryRun = parsed
+	}
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxImportSize())
+
+	var body io.Reader = c.Request().Body
+	format := c.QueryParam("format")
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType == echo.MIMEMultipartForm {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			err = errors.Wrap(err, "[Handler.ImportProducts]: file is required")
+
+			log.WithError(err).Warn("Missing import file")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			err = errors.Wrap(err, "[Handler.ImportProducts]: failed to open file")
+
+			log.WithError(err).Error("Failed to open uploaded import file")
+
+			return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		defer file.Close()
+
+		body = file
+		if format == "" {
+			format = importFormat(filepath.Ext(fileHeader.Filename))
+		}
+	} else if format == "" {
+		format = importFormat(mediaType)
+	}
+
+	result, err := h.usecase.ImportProducts(c.Request().Context(), shop.ID, format, body, dryRun)
+	if err != nil {
+		fields := log.Fields{
+			"shopID": shop.ID,
+			"format": format,
+		}
+
+		switch {
+		case err.Error() == "[ShopUsecase.ImportProducts]: unsupported format":
+			err = errors.Wrap(err, "[Handler.ImportProducts]: unsupported format")
+
+			log.WithFields(fields).WithError(err).Warn("Unsupported import format")
+
+			return c.JSON(http.StatusUnsupportedMediaType, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case err.Error() == "[ShopUsecase.ImportProducts]: too many rows":
+			err = errors.Wrap(err, "[Handler.ImportProducts]: too many rows")
+
+			log.WithFields(fields).WithError(err).Warn("Import file has too many rows")
+
+			return c.JSON(http.StatusRequestEntityTooLarge, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case err.Error() == "[ShopUsecase.ImportProducts]: sku already exists":
+			err = errors.Wrap(err, "[Handler.ImportProducts]: sku already exists")
+
+			log.WithFields(fields).WithError(err).Warn("Concurrent import created a SKU")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case strings.HasPrefix(err.Error(), "[ShopUsecase.ImportProducts]: invalid file"):
+			err = errors.Wrap(err, "[Handler.ImportProducts]: invalid file")
+
+			log.WithFields(fields).WithError(err).Warn("Invalid import file")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		default:
+			err = errors.Wrap(err, "[Handler.ImportProducts]: internal server error")
+
+			log.WithFields(fields).WithError(err).Error("Internal server error while importing products")
+
+			return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+	}
+
+	if len(result.Errors) > 0 && !dryRun {
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+			"errors": len(result.Errors),
+		}).Warn("Product import rejected")
+
+		return c.JSON(http.StatusUnprocessableEntity, entity.Response{
+			Success: false,
+			Message: "Product import has invalid rows, nothing was imported",
+			Data:    result,
+			Status:  http.StatusUnprocessableEntity,
+		})
+	}
+
+	message := "Products imported successfully"
+	if dryRun {
+		message = "Product import validated"
+	}
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: message,
+		Data:    result,
+		Status:  http.StatusOK,
+	})
+}
+
+// ExportProducts streams the shop's catalog as CSV. Once the first byte is
+// out the status can no longer change, so later failures are only logged.
+func (h *Handler) ExportProducts(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.ExportProducts]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="products.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	if err := h.usecase.ExportProducts(c.Request().Context(), shop.ID, c.Response()); err != nil {
+		err = errors.Wrap(err, "[Handler.ExportProducts]: failed to export products")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Product export aborted")
+	}
+	return nil
+}
+
+func importFormat(typeOrExt string) string {
+	switch strings.ToLower(typeOrExt) {
+	case "text/csv", ".csv":
+		return entity.ProductFileCSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines", ".jsonl", ".ndjson":
+		return entity.ProductFileJSONL
+	}
+	return ""
+}
+
+// maxImportSize is product.importmaxsize in bytes, 10 MiB by default
+func maxImportSize() int64 {
+	if size := viper.GetInt64("product.importmaxsize"); size > 0 {
+		return size
+	}
+	return 10 << 20
+}
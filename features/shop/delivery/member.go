@@ -0,0 +1,501 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/entity"
+	"order-management/middleware"
+	"order-management/utils"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+func (h *Handler) GetMembers(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetMembers]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	members, err := h.usecase.GetMembers(c.Request().Context(), shop.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetMembers]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while getting members")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Members retrieved successfully",
+		Data:    members,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) AddMember(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.AddMember]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.MemberRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.AddMember]: invalid member")
+
+		log.WithError(err).Warn("Invalid member data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if req.Email == "" {
+		err := errors.New("[Handler.AddMember]: email is required")
+
+		log.Warn("Email is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	member, err := h.usecase.AddMember(c.Request().Context(), shop.ID, req)
+	if err != nil {
+		return memberError(c, errors.Wrap(err, "[Handler.AddMember]: failed to add member"), shop.ID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Member added successfully",
+		Data:    member,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) UpdateMember(c echo.Context) error {
+	req, err := parseMemberManagementRequest(c, "UpdateMember")
+	if err != nil {
+		return err
+	}
+
+	member := entity.MemberRequest{}
+	if err := c.Bind(&member); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateMember]: invalid member")
+
+		log.WithError(err).Warn("Invalid member data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if err := h.usecase.UpdateMemberRole(c.Request().Context(), req, member.Role); err != nil {
+		return memberError(c, errors.Wrap(err, "[Handler.UpdateMember]: failed to update member"), req.ShopID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Member updated successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) RemoveMember(c echo.Context) error {
+	req, err := parseMemberManagementRequest(c, "RemoveMember")
+	if err != nil {
+		return err
+	}
+
+	if err := h.usecase.RemoveMember(c.Request().Context(), req); err != nil {
+		return memberError(c, errors.Wrap(err, "[Handler.RemoveMember]: failed to remove member"), req.ShopID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Member removed successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) GetMemberships(c echo.Context) error {
+	user, ok := c.Get("user").(*entity.UserJWT)
+	if !ok {
+		err := errors.New("[Handler.GetMemberships]: no user claims found")
+
+		log.Warn("No user claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	memberships, err := h.usecase.GetMemberships(c.Request().Context(), user.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetMemberships]: internal server error")
+
+		log.WithFields(log.Fields{
+			"userID": user.ID,
+		}).WithError(err).Error("Internal server error while getting memberships")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Memberships retrieved successfully",
+		Data:    memberships,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) SwitchContext(c echo.Context) error {
+	user, ok := c.Get("user").(*entity.UserJWT)
+	if !ok {
+		err := errors.New("[Handler.SwitchContext]: no user claims found")
+
+		log.Warn("No user claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.SwitchContextRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.SwitchContext]: invalid context")
+
+		log.WithError(err).Warn("Invalid context data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	token, err := h.usecase.SwitchContext(c.Request().Context(), user, req.ShopID)
+	if err != nil {
+		if err.Error() == "[ShopUsecase.SwitchContext]: not a member of the shop" {
+			err = errors.Wrap(err, "[Handler.SwitchContext]: not a member of the shop")
+
+			log.WithFields(log.Fields{
+				"userID": user.ID,
+				"shopID": req.ShopID,
+			}).WithError(err).Warn("User is not a member of the shop")
+
+			return c.JSON(http.StatusForbidden, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.SwitchContext]: internal server error")
+
+		log.WithFields(log.Fields{
+			"userID": user.ID,
+			"shopID": req.ShopID,
+		}).WithError(err).Error("Internal server error while switching context")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if err := middleware.IssueToken(c, middleware.UserSessionCookie, token); err != nil {
+		err = errors.Wrap(err, "[Handler.SwitchContext]: failed to issue token")
+
+		log.WithError(err).Error("Failed to issue token")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Context switched successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) LinkShop(c echo.Context) error {
+	user, ok := c.Get("user").(*entity.UserJWT)
+	if !ok {
+		err := errors.New("[Handler.LinkShop]: no user claims found")
+
+		log.Warn("No user claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.LinkShopRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.LinkShop]: invalid shop credentials")
+
+		log.WithError(err).Warn("Invalid shop credentials format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if req.Name == "" || req.Password == "" {
+		err := errors.New("[Handler.LinkShop]: name and password are required")
+
+		log.Warn("Name and password are required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	membership, err := h.usecase.LinkShop(c.Request().Context(), user.ID, req, c.RealIP())
+	if err != nil {
+		if locked := (*entity.LoginLockedError)(nil); errors.As(err, &locked) {
+			err = errors.Wrap(err, "[Handler.LinkShop]: too many failed logins")
+
+			log.WithFields(log.Fields{
+				"userID":   user.ID,
+				"shopName": req.Name,
+			}).WithError(err).Warn("Linking refused after repeated failures")
+
+			c.Response().Header().Set("Retry-After", utils.RetryAfter(locked.RetryAfter))
+			return c.JSON(http.StatusTooManyRequests, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		switch err.Error() {
+		case "[ShopUsecase.LinkShop]: shop not found":
+			err = errors.Wrap(err, "[Handler.LinkShop]: shop not found")
+
+			log.WithFields(log.Fields{
+				"userID":   user.ID,
+				"shopName": req.Name,
+			}).WithError(err).Warn("Shop not found while linking")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.LinkShop]: invalid password":
+			err = errors.Wrap(err, "[Handler.LinkShop]: invalid password")
+
+			log.WithFields(log.Fields{
+				"userID":   user.ID,
+				"shopName": req.Name,
+			}).WithError(err).Warn("Invalid shop password while linking")
+
+			return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.LinkShop]: invalid code":
+			err = errors.Wrap(err, "[Handler.LinkShop]: invalid code")
+
+			log.WithFields(log.Fields{
+				"userID":   user.ID,
+				"shopName": req.Name,
+			}).WithError(err).Warn("Invalid two-factor code while linking")
+
+			return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.LinkShop]: internal server error")
+
+		log.WithFields(log.Fields{
+			"userID": user.ID,
+		}).WithError(err).Error("Internal server error while linking shop")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Shop linked successfully",
+		Data:    membership,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) CreateOwnedShop(c echo.Context) error {
+	user, ok := c.Get("user").(*entity.UserJWT)
+	if !ok {
+		err := errors.New("[Handler.CreateOwnedShop]: no user claims found")
+
+		log.Warn("No user claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ShopUpdateRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.CreateOwnedShop]: invalid shop")
+
+		log.WithError(err).Warn("Invalid shop data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if req.Name == "" {
+		err := errors.New("[Handler.CreateOwnedShop]: name is required")
+
+		log.Warn("Name is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	membership, err := h.usecase.CreateOwnedShop(c.Request().Context(), user.ID, req)
+	if err != nil {
+		if err.Error() == "[ShopUsecase.CreateOwnedShop]: shop already exists" {
+			err = errors.Wrap(err, "[Handler.CreateOwnedShop]: shop already exists")
+
+			log.WithFields(log.Fields{
+				"userID":   user.ID,
+				"shopName": req.Name,
+			}).WithError(err).Warn("Shop already exists")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.CreateOwnedShop]: internal server error")
+
+		log.WithFields(log.Fields{
+			"userID": user.ID,
+		}).WithError(err).Error("Internal server error while creating shop")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Shop created successfully",
+		Data:    membership,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) GetFinances(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetFinances]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	finances, err := h.usecase.GetFinances(c.Request().Context(), shop.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetFinances]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while getting finances")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Finances retrieved successfully",
+		Data:    finances,
+		Status:  http.StatusOK,
+	})
+}
+
+// parseMemberManagementRequest returns an echo.HTTPError carrying the
+// response, callers only have to return it.
+func parseMemberManagementRequest(c echo.Context, method string) (*entity.MemberManagementRequest, error) {
+	memberID, err := strconv.ParseUint(c.Param("member_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler."+method+"]: invalid member id")
+
+		log.WithError(err).Warn("Invalid member ID format")
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler." + method + "]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return &entity.MemberManagementRequest{
+		ShopID:   shop.ID,
+		MemberID: uint32(memberID),
+	}, nil
+}
+
+func memberError(c echo.Context, err error, shopID uint32) error {
+	fields := log.Fields{
+		"shopID": shopID,
+	}
+
+	switch utils.StandardError(err) {
+	case "invalid role":
+		log.WithFields(fields).WithError(err).Warn("Invalid member role")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "user not found", "member not found":
+		log.WithFields(fields).WithError(err).Warn("User or member not found")
+
+		return c.JSON(http.StatusNotFound, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "member already exists":
+		log.WithFields(fields).WithError(err).Warn("User already a member")
+
+		return c.JSON(http.StatusConflict, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	default:
+		log.WithFields(fields).WithError(err).Error("Internal server error while managing members")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package delivery
+
+import (
+	"net/http"
+
+	"order-management/entity"
+	"order-management/middleware"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// mfaError maps the errors shared by the two-factor authentication endpoints
+func mfaError(c echo.Context, err error, handler string) error {
+	if locked := (*entity.LoginLockedError)(nil); errors.As(err, &locked) {
+		err = errors.Wrap(err, "[Handler."+handler+"]: too many failed logins")
+
+		log.WithError(err).Warn("Two-factor code refused after repeated failures")
+
+		c.Response().Header().Set("Retry-After", utils.RetryAfter(locked.RetryAfter))
+		return c.JSON(http.StatusTooManyRequests, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	switch utils.StandardError(err) {
+	case "invalid mfa token", "invalid code":
+		err = errors.Wrap(err, "[Handler."+handler+"]: unauthorized")
+
+		log.WithError(err).Warn("Invalid two-factor authentication")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{Error: utils.StandardError(err)})
+	case "mfa already enabled", "mfa not enabled", "mfa not enrolled", "mfa enrolment required", "mfa required":
+		err = errors.Wrap(err, "[Handler."+handler+"]: conflict")
+		return c.JSON(http.StatusConflict, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	err = errors.Wrap(err, "[Handler."+handler+"]: internal server error")
+
+	log.WithError(err).Error("Internal server error during two-factor authentication")
+
+	return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+}
+
+func (h *Handler) LoginMFA(c echo.Context) error {
+	req := entity.MFALoginRequest{}
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		err = errors.New("[Handler.LoginMFA]: mfaToken and code are required")
+
+		log.WithError(err).Warn("Invalid two-factor login request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	login, err := h.usecase.LoginMFA(c.Request().Context(), req, c.RealIP())
+	if err != nil {
+		return mfaError(c, err, "LoginMFA")
+	}
+
+	if err := middleware.IssueToken(c, middleware.ShopSessionCookie, login.Token); err != nil {
+		err = errors.Wrap(err, "[Handler.LoginMFA]: failed to issue token")
+
+		log.WithError(err).Error("Failed to issue token")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Login successful",
+		Data:    login,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) EnrolMFALogin(c echo.Context) error {
+	req := entity.MFALoginRequest{}
+	if err := c.Bind(&req); err != nil || req.MFAToken == "" {
+		err = errors.New("[Handler.EnrolMFALogin]: mfaToken is required")
+
+		log.WithError(err).Warn("Invalid two-factor enrolment request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	enrolment, err := h.usecase.EnrolMFALogin(c.Request().Context(), req.MFAToken)
+	if err != nil {
+		return mfaError(c, err, "EnrolMFALogin")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Confirm the enrolment with a code to log in",
+		Data:    enrolment,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) GetMFAStatus(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetMFAStatus]: no shop claims found")
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	status, err := h.usecase.GetMFAStatus(c.Request().Context(), shop.ID)
+	if err != nil {
+		return mfaError(c, err, "GetMFAStatus")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Two-factor authentication status fetched successfully",
+		Data:    status,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) EnrolMFA(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.EnrolMFA]: no shop claims found")
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	enrolment, err := h.usecase.EnrolMFA(c.Request().Context(), shop.ID)
+	if err != nil {
+		return mfaError(c, err, "EnrolMFA")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Confirm the enrolment with a code",
+		Data:    enrolment,
+		Status:  http.StatusOK,
+	})
+}
+
+// bindMFACode reads the code of the endpoints that take one
+func bindMFACode(c echo.Context, handler string) (string, error) {
+	req := entity.MFACodeRequest{}
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		err = errors.New("[Handler." + handler + "]: code is required")
+
+		log.WithError(err).Warn("Missing two-factor code")
+
+		return "", c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	return req.Code, nil
+}
+
+func (h *Handler) ConfirmMFA(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.ConfirmMFA]: no shop claims found")
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	code, err := bindMFACode(c, "ConfirmMFA")
+	if code == "" {
+		return err
+	}
+
+	codes, err := h.usecase.ConfirmMFA(c.Request().Context(), shop.ID, code)
+	if err != nil {
+		return mfaError(c, err, "ConfirmMFA")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Two-factor authentication enabled, store the recovery codes safely",
+		Data:    entity.RecoveryCodesResponse{RecoveryCodes: codes},
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) DisableMFA(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.DisableMFA]: no shop claims found")
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	code, err := bindMFACode(c, "DisableMFA")
+	if code == "" {
+		return err
+	}
+
+	if err := h.usecase.DisableMFA(c.Request().Context(), shop.ID, code); err != nil {
+		return mfaError(c, err, "DisableMFA")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) RegenerateRecoveryCodes(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.RegenerateRecoveryCodes]: no shop claims found")
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+	code, err := bindMFACode(c, "RegenerateRecoveryCodes")
+	if code == "" {
+		return err
+	}
+
+	codes, err := h.usecase.RegenerateRecoveryCodes(c.Request().Context(), shop.ID, code)
+	if err != nil {
+		return mfaError(c, err, "RegenerateRecoveryCodes")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Recovery codes replaced, store them safely",
+		Data:    entity.RecoveryCodesResponse{RecoveryCodes: codes},
+		Status:  http.StatusOK,
+	})
+}
@@ -0,0 +1,456 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/entity"
+	"order-management/middleware"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// VerifyToken runs after middleware.ShopAuth and rejects tokens revoked by a
+// password change or by removing the member, and the API keys of deactivated
+// shops. It also replaces the role claim with the member's current role. It
+// lives here rather than in the middleware package since it needs the shop
+// usecase.
+func (h *Handler) VerifyToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		shop, ok := c.Get("shop").(*entity.ShopJWT)
+		if !ok {
+			err := errors.New("[Handler.VerifyToken]: no shop claims found")
+
+			log.Warn("No shop claims found in context")
+
+			return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		role, err := h.usecase.VerifyToken(c.Request().Context(), shop)
+		if err != nil {
+			if err.Error() == "[ShopUsecase.VerifyToken]: shop deactivated" {
+				err = errors.Wrap(err, "[Handler.VerifyToken]: shop deactivated")
+
+				log.WithFields(log.Fields{
+					"shopID": shop.ID,
+					"keyID":  shop.APIKey.ID,
+				}).WithError(err).Warn("API key of a deactivated shop used")
+
+				return c.JSON(http.StatusForbidden, entity.ResponseError{
+					Error: utils.StandardError(err),
+				})
+			}
+			if err.Error() == "[ShopUsecase.VerifyToken]: token revoked" {
+				err = errors.Wrap(err, "[Handler.VerifyToken]: token revoked")
+
+				log.WithFields(log.Fields{
+					"shopID": shop.ID,
+				}).WithError(err).Warn("Revoked shop token used")
+
+				return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+					Error: utils.StandardError(err),
+				})
+			}
+			err = errors.Wrap(err, "[Handler.VerifyToken]: internal server error")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Error("Internal server error while verifying shop token")
+
+			return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		shop.Role = role
+
+		return next(c)
+	}
+}
+
+// Require lets the request through when the role of the token grants the
+// permission, and for API keys one of the key's scopes covers it. It has to
+// run after VerifyToken.
+func (h *Handler) Require(permission entity.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			shop, ok := c.Get("shop").(*entity.ShopJWT)
+			if !ok {
+				err := errors.New("[Handler.Require]: no shop claims found")
+
+				log.Warn("No shop claims found in context")
+
+				return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+					Error: utils.StandardError(err),
+				})
+			}
+
+			if !shop.Role.Can(permission) {
+				err := errors.Errorf("[Handler.Require]: permission %s required", permission)
+
+				log.WithFields(log.Fields{
+					"shopID": shop.ID,
+					"member": shop.UserID,
+					"role":   shop.Role,
+				}).WithError(err).Warn("Shop member lacks permission")
+
+				return c.JSON(http.StatusForbidden, entity.ResponseError{
+					Error: utils.StandardError(err),
+				})
+			}
+
+			if shop.APIKey != nil && !shop.APIKey.Allows(permission, c.Request().Method) {
+				err := errors.Errorf("[Handler.Require]: api key scope for %s required", permission)
+
+				log.WithFields(log.Fields{
+					"shopID": shop.ID,
+					"keyID":  shop.APIKey.ID,
+					"scopes": shop.APIKey.Scopes,
+				}).WithError(err).Warn("API key lacks scope")
+
+				return c.JSON(http.StatusForbidden, entity.ResponseError{
+					Error: utils.StandardError(err),
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// TokenOnly refuses API keys on the routes of a logged in shop or member
+// that need no permission, such as reading the profile or logging out
+func (h *Handler) TokenOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		shop, ok := c.Get("shop").(*entity.ShopJWT)
+		if !ok {
+			err := errors.New("[Handler.TokenOnly]: no shop claims found")
+
+			log.Warn("No shop claims found in context")
+
+			return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		if shop.APIKey != nil {
+			err := errors.New("[Handler.TokenOnly]: not available to api keys")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+				"keyID":  shop.APIKey.ID,
+			}).WithError(err).Warn("API key used on a login only route")
+
+			return c.JSON(http.StatusForbidden, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+
+		return next(c)
+	}
+}
+
+func (h *Handler) UpdateProfile(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.UpdateProfile]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ShopUpdateRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateProfile]: invalid shop")
+
+		log.WithError(err).Warn("Invalid shop data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if req.Name == "" {
+		err := errors.New("[Handler.UpdateProfile]: name is required")
+
+		log.Warn("Name is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	profile, token, err := h.usecase.UpdateProfile(c.Request().Context(), shop, req)
+	if err != nil {
+		switch err.Error() {
+		case "[ShopUsecase.UpdateProfile]: shop not found":
+			err = errors.Wrap(err, "[Handler.UpdateProfile]: shop not found")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Shop not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.UpdateProfile]: shop already exists":
+			err = errors.Wrap(err, "[Handler.UpdateProfile]: shop already exists")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+				"name":   req.Name,
+			}).WithError(err).Warn("Shop name already taken")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.UpdateProfile]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while updating shop profile")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if token != "" {
+		if err := middleware.IssueToken(c, middleware.ShopSessionCookie, token); err != nil {
+			err = errors.Wrap(err, "[Handler.UpdateProfile]: failed to issue token")
+
+			log.WithError(err).Error("Failed to issue token")
+
+			return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Shop profile updated successfully",
+		Data:    profile,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) ChangePassword(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.ChangePassword]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ShopPasswordRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.ChangePassword]: invalid password request")
+
+		log.WithError(err).Warn("Invalid password request format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if req.OldPassword == "" || req.NewPassword == "" {
+		err := errors.New("[Handler.ChangePassword]: old and new password are required")
+
+		log.Warn("Old and new password are required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	token, err := h.usecase.ChangePassword(c.Request().Context(), shop, req)
+	if err != nil {
+		switch err.Error() {
+		case "[ShopUsecase.ChangePassword]: shop not found":
+			err = errors.Wrap(err, "[Handler.ChangePassword]: shop not found")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Shop not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.ChangePassword]: invalid password":
+			err = errors.Wrap(err, "[Handler.ChangePassword]: invalid password")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Invalid old password")
+
+			return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.ChangePassword]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while changing shop password")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if token != "" {
+		if err := middleware.IssueToken(c, middleware.ShopSessionCookie, token); err != nil {
+			err = errors.Wrap(err, "[Handler.ChangePassword]: failed to issue token")
+
+			log.WithError(err).Error("Failed to issue token")
+
+			return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Password changed successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) Deactivate(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.Deactivate]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ShopDeactivateRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.Deactivate]: invalid deactivate request")
+
+		log.WithError(err).Warn("Invalid deactivate request format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if req.Password == "" {
+		err := errors.New("[Handler.Deactivate]: password is required")
+
+		log.Warn("Password is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if err := h.usecase.Deactivate(c.Request().Context(), shop.ID, req.Password); err != nil {
+		switch err.Error() {
+		case "[ShopUsecase.Deactivate]: shop not found":
+			err = errors.Wrap(err, "[Handler.Deactivate]: shop not found")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Shop not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.Deactivate]: invalid password":
+			err = errors.Wrap(err, "[Handler.Deactivate]: invalid password")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Invalid password on deactivation")
+
+			return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.Deactivate]: shop already deactivated":
+			err = errors.Wrap(err, "[Handler.Deactivate]: shop already deactivated")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Shop already deactivated")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.Deactivate]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while deactivating shop")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Shop deactivated successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) Reactivate(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.Reactivate]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if err := h.usecase.Reactivate(c.Request().Context(), shop.ID); err != nil {
+		if err.Error() == "[ShopUsecase.Reactivate]: shop not found" {
+			err = errors.Wrap(err, "[Handler.Reactivate]: shop not found")
+
+			log.WithFields(log.Fields{
+				"shopID": shop.ID,
+			}).WithError(err).Warn("Shop not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.Reactivate]: internal server error")
+
+		log.WithFields(log.Fields{
+			"shopID": shop.ID,
+		}).WithError(err).Error("Internal server error while reactivating shop")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Shop reactivated successfully",
+		Status:  http.StatusOK,
+	})
+}
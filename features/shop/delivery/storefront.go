@@ -0,0 +1,76 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/entity"
+	"order-management/utils"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStorefrontLimit = 20
+	maxStorefrontLimit     = 100
+)
+
+func (h *Handler) GetStorefront(c echo.Context) error {
+	slug := c.Param("slug")
+
+	limit := defaultStorefrontLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxStorefrontLimit {
+			err := errors.New("[Handler.GetStorefront]: limit must be between 1 and 100")
+
+			log.WithField("limit", s).Warn("Invalid storefront limit")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		limit = n
+	}
+
+	storefront, err := h.usecase.GetStorefront(c.Request().Context(), slug, limit, c.QueryParam("cursor"))
+	if err != nil {
+		switch err.Error() {
+		case "[ShopUsecase.GetStorefront]: shop not found":
+			err = errors.Wrap(err, "[Handler.GetStorefront]: shop not found")
+
+			log.WithFields(log.Fields{
+				"slug": slug,
+			}).WithError(err).Warn("Shop not found")
+
+			return c.JSON(http.StatusNotFound, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		case "[ShopUsecase.GetStorefront]: invalid cursor":
+			err = errors.Wrap(err, "[Handler.GetStorefront]: invalid cursor")
+
+			log.WithError(err).Warn("Invalid storefront cursor")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+		err = errors.Wrap(err, "[Handler.GetStorefront]: internal server error")
+
+		log.WithFields(log.Fields{
+			"slug": slug,
+		}).WithError(err).Error("Internal server error while getting storefront")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Storefront retrieved successfully",
+		Data:    storefront,
+		Status:  http.StatusOK,
+	})
+}
@@ -0,0 +1,206 @@
+package delivery
+
+import (
+	"net/http"
+	"order-management/entity"
+	"order-management/utils"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+func (h *Handler) CreateVariant(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.CreateVariant]: invalid product id")
+
+		log.WithError(err).Warn("Invalid product ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.CreateVariant]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	variant := entity.VariantRequest{}
+	if err := c.Bind(&variant); err != nil {
+		err = errors.Wrap(err, "[Handler.CreateVariant]: invalid variant")
+
+		log.WithError(err).Warn("Invalid variant data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if variant.SKU == "" {
+		err := errors.New("[Handler.CreateVariant]: sku is required")
+
+		log.Warn("SKU is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.ProductManagementRequest{
+		ShopID:    shop.ID,
+		ProductID: uint32(productID),
+	}
+
+	created, err := h.usecase.CreateVariant(c.Request().Context(), &req, variant)
+	if err != nil {
+		return variantError(c, errors.Wrap(err, "[Handler.CreateVariant]: failed to create variant"), shop.ID, req.ProductID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Variant created successfully",
+		Data:    created,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) UpdateVariant(c echo.Context) error {
+	req, err := parseVariantManagementRequest(c, "UpdateVariant")
+	if err != nil {
+		return err
+	}
+
+	variant := entity.VariantRequest{}
+	if err := c.Bind(&variant); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateVariant]: invalid variant")
+
+		log.WithError(err).Warn("Invalid variant data format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if variant.SKU == "" {
+		err := errors.New("[Handler.UpdateVariant]: sku is required")
+
+		log.Warn("SKU is required")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if err := h.usecase.UpdateVariant(c.Request().Context(), req, variant); err != nil {
+		return variantError(c, errors.Wrap(err, "[Handler.UpdateVariant]: failed to update variant"), req.ShopID, req.ProductID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Variant updated successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) DeleteVariant(c echo.Context) error {
+	req, err := parseVariantManagementRequest(c, "DeleteVariant")
+	if err != nil {
+		return err
+	}
+
+	if err := h.usecase.DeleteVariant(c.Request().Context(), req); err != nil {
+		return variantError(c, errors.Wrap(err, "[Handler.DeleteVariant]: failed to delete variant"), req.ShopID, req.ProductID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Variant deleted successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+// parseVariantManagementRequest returns an echo.HTTPError carrying the
+// response, callers only have to return it.
+func parseVariantManagementRequest(c echo.Context, method string) (*entity.VariantManagementRequest, error) {
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler."+method+"]: invalid product id")
+
+		log.WithError(err).Warn("Invalid product ID format")
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler."+method+"]: invalid variant id")
+
+		log.WithError(err).Warn("Invalid variant ID format")
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler." + method + "]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return &entity.VariantManagementRequest{
+		ShopID:    shop.ID,
+		ProductID: uint32(productID),
+		VariantID: uint32(variantID),
+	}, nil
+}
+
+func variantError(c echo.Context, err error, shopID uint32, productID uint32) error {
+	fields := log.Fields{
+		"shopID":    shopID,
+		"productID": productID,
+	}
+
+	switch utils.StandardError(err) {
+	case "product not found", "variant not found":
+		log.WithFields(fields).WithError(err).Warn("Variant or product not found")
+
+		return c.JSON(http.StatusNotFound, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "product does not belong to shop":
+		log.WithFields(fields).WithError(err).Warn("Product does not belong to shop")
+
+		return c.JSON(http.StatusForbidden, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "variant already exists":
+		log.WithFields(fields).WithError(err).Warn("Variant SKU already used")
+
+		return c.JSON(http.StatusConflict, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	default:
+		log.WithFields(fields).WithError(err).Error("Internal server error while managing variant")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+}
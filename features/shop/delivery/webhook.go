@@ -0,0 +1,251 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+func (h *Handler) GetWebhooks(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.GetWebhooks]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	webhooks, err := h.webhookUsecase.GetWebhooks(c.Request().Context(), shop.ID)
+	if err != nil {
+		return webhookError(c, errors.Wrap(err, "[Handler.GetWebhooks]: failed to get webhooks"), shop.ID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Webhooks retrieved successfully",
+		Data:    webhooks,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) CreateWebhook(c echo.Context) error {
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.New("[Handler.CreateWebhook]: no shop claims found")
+
+		log.Warn("No shop claims found in context")
+
+		return c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	req := entity.WebhookRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.CreateWebhook]: invalid request body")
+
+		log.WithError(err).Warn("Invalid webhook request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	webhook, err := h.webhookUsecase.CreateWebhook(c.Request().Context(), shop.ID, req)
+	if err != nil {
+		return webhookError(c, errors.Wrap(err, "[Handler.CreateWebhook]: failed to create webhook"), shop.ID)
+	}
+
+	return c.JSON(http.StatusCreated, entity.Response{
+		Success: true,
+		Message: "Webhook created, store the secret safely as it is not shown again",
+		Data:    webhook,
+		Status:  http.StatusCreated,
+	})
+}
+
+func (h *Handler) UpdateWebhook(c echo.Context) error {
+	req, err := webhookRequest(c, "UpdateWebhook")
+	if err != nil {
+		return err
+	}
+
+	body := entity.WebhookRequest{}
+	if err := c.Bind(&body); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateWebhook]: invalid request body")
+
+		log.WithError(err).Warn("Invalid webhook request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	webhook, err := h.webhookUsecase.UpdateWebhook(c.Request().Context(), req, body)
+	if err != nil {
+		return webhookError(c, errors.Wrap(err, "[Handler.UpdateWebhook]: failed to update webhook"), req.ShopID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Webhook updated successfully",
+		Data:    webhook,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	req, err := webhookRequest(c, "DeleteWebhook")
+	if err != nil {
+		return err
+	}
+
+	if err := h.webhookUsecase.DeleteWebhook(c.Request().Context(), req); err != nil {
+		return webhookError(c, errors.Wrap(err, "[Handler.DeleteWebhook]: failed to delete webhook"), req.ShopID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Webhook deleted successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+// GetWebhookDeliveries takes an optional status and limit, at most 100
+func (h *Handler) GetWebhookDeliveries(c echo.Context) error {
+	req, err := webhookRequest(c, "GetWebhookDeliveries")
+	if err != nil {
+		return err
+	}
+
+	filter := entity.WebhookDeliveryFilter{
+		Status: entity.WebhookDeliveryStatus(c.QueryParam("status")),
+	}
+	switch filter.Status {
+	case "", entity.DeliveryPending, entity.DeliverySucceeded, entity.DeliveryFailed:
+	default:
+		err := errors.New("[Handler.GetWebhookDeliveries]: invalid status")
+
+		log.WithError(err).Warn("Invalid delivery status filter")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 {
+			err := errors.New("[Handler.GetWebhookDeliveries]: invalid limit")
+
+			log.WithError(err).Warn("Invalid delivery limit")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{
+				Error: utils.StandardError(err),
+			})
+		}
+	}
+
+	deliveries, err := h.webhookUsecase.GetDeliveries(c.Request().Context(), req, filter)
+	if err != nil {
+		return webhookError(c, errors.Wrap(err, "[Handler.GetWebhookDeliveries]: failed to get deliveries"), req.ShopID)
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Webhook deliveries retrieved successfully",
+		Data:    deliveries,
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) RedeliverWebhook(c echo.Context) error {
+	req, err := webhookRequest(c, "RedeliverWebhook")
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.RedeliverWebhook]: invalid delivery id")
+
+		log.WithError(err).Warn("Invalid delivery ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	if err := h.webhookUsecase.Redeliver(c.Request().Context(), req, deliveryID); err != nil {
+		return webhookError(c, errors.Wrap(err, "[Handler.RedeliverWebhook]: failed to redeliver"), req.ShopID)
+	}
+
+	return c.JSON(http.StatusAccepted, entity.Response{
+		Success: true,
+		Message: "Delivery queued again",
+		Status:  http.StatusAccepted,
+	})
+}
+
+// webhookRequest reads the webhook id and the shop claims. On failure it
+// has already written the response and returns its error.
+func webhookRequest(c echo.Context, method string) (*entity.WebhookManagementRequest, error) {
+	webhookID, err := strconv.ParseUint(c.Param("webhook_id"), 10, 32)
+	if err != nil {
+		err = errors.Wrapf(err, "[Handler.%s]: invalid webhook id", method)
+
+		log.WithError(err).Warn("Invalid webhook ID format")
+
+		return nil, c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	shop, ok := c.Get("shop").(*entity.ShopJWT)
+	if !ok {
+		err := errors.Errorf("[Handler.%s]: no shop claims found", method)
+
+		log.Warn("No shop claims found in context")
+
+		return nil, c.JSON(http.StatusUnauthorized, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return &entity.WebhookManagementRequest{ShopID: shop.ID, WebhookID: uint32(webhookID)}, nil
+}
+
+func webhookError(c echo.Context, err error, shopID uint32) error {
+	fields := log.Fields{
+		"shopID": shopID,
+	}
+
+	switch utils.StandardError(err) {
+	case "invalid url", "invalid event", "too many webhooks":
+		log.WithFields(fields).WithError(err).Warn("Invalid webhook request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	case "webhook not found", "delivery not found":
+		log.WithFields(fields).WithError(err).Warn("Webhook not found")
+
+		return c.JSON(http.StatusNotFound, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	default:
+		log.WithFields(fields).WithError(err).Error("Internal server error while managing webhooks")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/database"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// apiKeyTouchInterval keeps last-used tracking from writing on every request
+// of a busy integration
+const apiKeyTouchInterval = time.Minute
+
+func (r *shopRepository) CreateAPIKey(ctx context.Context, key *entity.ShopAPIKey) error {
+	if err := database.Conn(ctx, r.db).Omit("Shop").Create(key).Error; err != nil {
+		return errors.Wrap(err, "[ShopRepository.CreateAPIKey]: failed to create api key")
+	}
+	return nil
+}
+
+// GetAPIKeys lists the shop's keys, revoked ones included
+func (r *shopRepository) GetAPIKeys(ctx context.Context, shopID uint32) (keys []entity.ShopAPIKey, err error) {
+	if err := database.Conn(ctx, r.db).
+		Where("shop_id = ?", shopID).
+		Order("id").
+		Find(&keys).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetAPIKeys]: failed to get api keys")
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *shopRepository) RevokeAPIKey(ctx context.Context, req *entity.APIKeyManagementRequest) error {
+	result := database.Conn(ctx, r.db).Model(&entity.ShopAPIKey{}).
+		Where("id = ? AND shop_id = ? AND revoked_at IS NULL", req.KeyID, req.ShopID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[ShopRepository.RevokeAPIKey]: failed to revoke api key")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ShopRepository.RevokeAPIKey]: api key not found")
+	}
+	return nil
+}
+
+// GetAPIKeyByHash finds a key with its shop's id and name, revoked or not
+func (r *shopRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (key entity.ShopAPIKey, err error) {
+	if err := database.Conn(ctx, r.db).
+		Preload("Shop", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "name", "description", "deactivated_at")
+		}).
+		Where("key_hash = ?", keyHash).
+		First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ShopRepository.GetAPIKeyByHash]: api key not found")
+			return entity.ShopAPIKey{}, err
+		}
+		err = errors.Wrap(err, "[ShopRepository.GetAPIKeyByHash]: failed to get api key")
+		return entity.ShopAPIKey{}, err
+	}
+	return key, nil
+}
+
+// TouchAPIKey records the use of a key at most once per
+// apiKeyTouchInterval
+func (r *shopRepository) TouchAPIKey(ctx context.Context, id uint32, ip string, at time.Time) error {
+	if err := database.Conn(ctx, r.db).Model(&entity.ShopAPIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-apiKeyTouchInterval)).
+		Updates(map[string]interface{}{
+			"last_used_at": at,
+			"last_used_ip": ip,
+		}).Error; err != nil {
+		return errors.Wrap(err, "[ShopRepository.TouchAPIKey]: failed to update api key")
+	}
+	return nil
+}
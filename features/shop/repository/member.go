@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+
+	"order-management/database"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *shopRepository) GetMembers(ctx context.Context, shopID uint32) (members []entity.ShopMember, err error) {
+	if err := database.Conn(ctx, r.db).Preload("User").
+		Where("shop_id = ?", shopID).
+		Order("id").
+		Find(&members).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetMembers]: failed to get members")
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *shopRepository) GetMember(ctx context.Context, req *entity.MemberManagementRequest) (member entity.ShopMember, err error) {
+	if err := database.Conn(ctx, r.db).Preload("User").
+		Where("id = ? AND shop_id = ?", req.MemberID, req.ShopID).
+		First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ShopRepository.GetMember]: member not found")
+			return entity.ShopMember{}, err
+		}
+		err = errors.Wrap(err, "[ShopRepository.GetMember]: failed to get member")
+		return entity.ShopMember{}, err
+	}
+	return member, nil
+}
+
+func (r *shopRepository) GetMemberByUserID(ctx context.Context, shopID uint32, userID uint32) (member entity.ShopMember, err error) {
+	if err := database.Conn(ctx, r.db).Preload("Shop").
+		Where("shop_id = ? AND user_id = ?", shopID, userID).
+		First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ShopRepository.GetMemberByUserID]: member not found")
+			return entity.ShopMember{}, err
+		}
+		err = errors.Wrap(err, "[ShopRepository.GetMemberByUserID]: failed to get member")
+		return entity.ShopMember{}, err
+	}
+	return member, nil
+}
+
+// GetMembershipsByUserID lists the shops the user is a member of
+func (r *shopRepository) GetMembershipsByUserID(ctx context.Context, userID uint32) (members []entity.ShopMember, err error) {
+	if err := database.Conn(ctx, r.db).Preload("Shop").
+		Where("user_id = ?", userID).
+		Order("id").
+		Find(&members).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetMembershipsByUserID]: failed to get memberships")
+		return nil, err
+	}
+	return members, nil
+}
+
+// CreateMember adds the registered user with the given email to the shop
+func (r *shopRepository) CreateMember(ctx context.Context, shopID uint32, req entity.MemberRequest) (entity.ShopMember, error) {
+	log.WithFields(log.Fields{
+		"shopID": shopID,
+		"req":    req,
+	}).Debug("Creating shop member")
+
+	user := entity.User{}
+	if err := database.Conn(ctx, r.db).Select("id", "email").Where("email = ?", req.Email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ShopMember{}, errors.New("[ShopRepository.CreateMember]: user not found")
+		}
+		return entity.ShopMember{}, errors.Wrap(err, "[ShopRepository.CreateMember]: failed to get user by email")
+	}
+
+	member := entity.ShopMember{
+		ShopID: shopID,
+		UserID: user.ID,
+		Role:   req.Role,
+	}
+	if err := database.Conn(ctx, r.db).Omit("Shop", "User").Create(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return entity.ShopMember{}, errors.New("[ShopRepository.CreateMember]: member already exists")
+		}
+		return entity.ShopMember{}, errors.Wrap(err, "[ShopRepository.CreateMember]: failed to create member")
+	}
+	member.User = user
+	return member, nil
+}
+
+func (r *shopRepository) UpdateMemberRole(ctx context.Context, req *entity.MemberManagementRequest, role entity.MemberRole) error {
+	result := database.Conn(ctx, r.db).Model(&entity.ShopMember{}).
+		Where("id = ? AND shop_id = ?", req.MemberID, req.ShopID).
+		Update("role", role)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[ShopRepository.UpdateMemberRole]: failed to update member role")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ShopRepository.UpdateMemberRole]: member not found")
+	}
+	return nil
+}
+
+func (r *shopRepository) DeleteMember(ctx context.Context, req *entity.MemberManagementRequest) error {
+	result := database.Conn(ctx, r.db).
+		Where("id = ? AND shop_id = ?", req.MemberID, req.ShopID).
+		Delete(&entity.ShopMember{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[ShopRepository.DeleteMember]: failed to delete member")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ShopRepository.DeleteMember]: member not found")
+	}
+	return nil
+}
+
+// GetShopFinances sums price times amount of the order lines for the shop's
+// products, deleted products included since their sales still count.
+func (r *shopRepository) GetShopFinances(ctx context.Context, shopID uint32) (finances entity.ShopFinances, err error) {
+	if err := database.Conn(ctx, r.db).Table("order_products op").
+		Select(`count(DISTINCT o.id) FILTER (WHERE o.status = ?) AS completed_orders,
+			COALESCE(sum(op.price * op.amount) FILTER (WHERE o.status = ?), 0) AS revenue,
+			COALESCE(sum(op.price * op.amount) FILTER (WHERE o.status IN ?), 0) AS pending_revenue`,
+			entity.COMPLETED, entity.COMPLETED, []entity.Status{entity.PENDING, entity.SHIPPING}).
+		Joins("JOIN orders o ON o.id = op.order_id").
+		Joins("JOIN products p ON p.id = op.product_id").
+		Where("p.shop_id = ?", shopID).
+		Scan(&finances).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetShopFinances]: failed to sum order lines")
+		return entity.ShopFinances{}, err
+	}
+	return finances, nil
+}
+
+// UpsertOwner adds the user as OWNER, promoting them if already a member
+func (r *shopRepository) UpsertOwner(ctx context.Context, shopID uint32, userID uint32) error {
+	member := entity.ShopMember{
+		ShopID: shopID,
+		UserID: userID,
+		Role:   entity.OWNER,
+	}
+	if err := database.Conn(ctx, r.db).Omit("Shop", "User").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "shop_id"}, {Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"role": entity.OWNER, "updated_at": gorm.Expr("now()")}),
+		}).
+		Create(&member).Error; err != nil {
+		return errors.Wrap(err, "[ShopRepository.UpsertOwner]: failed to upsert owner")
+	}
+	return nil
+}
+
+// CreateShopWithOwner creates the shop and its first member in one
+// transaction
+func (r *shopRepository) CreateShopWithOwner(ctx context.Context, shop *entity.Shop, userID uint32) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(shop).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errors.New("[ShopRepository.CreateShopWithOwner]: shop already exists")
+			}
+			return errors.Wrap(err, "[ShopRepository.CreateShopWithOwner]: failed to create shop")
+		}
+
+		member := entity.ShopMember{
+			ShopID: shop.ID,
+			UserID: userID,
+			Role:   entity.OWNER,
+		}
+		if err := tx.Omit("Shop", "User").Create(&member).Error; err != nil {
+			return errors.Wrap(err, "[ShopRepository.CreateShopWithOwner]: failed to create owner")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// SetShopTOTPSecret stores the secret of an enrolment that is not confirmed
+// yet, replacing an earlier unconfirmed one
+func (r *shopRepository) SetShopTOTPSecret(ctx context.Context, id uint32, secret string) error {
+	result := r.db.WithContext(ctx).Model(&entity.Shop{}).
+		Where("id = ? AND totp_enabled_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_last_step": 0,
+		})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[ShopRepository.SetShopTOTPSecret]: failed to update shop")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ShopRepository.SetShopTOTPSecret]: mfa already enabled")
+	}
+	return nil
+}
+
+// EnableShopTOTP confirms the stored secret with the step of the code that
+// proved it and replaces the recovery codes in one transaction
+func (r *shopRepository) EnableShopTOTP(ctx context.Context, id uint32, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.Shop{}).
+			Where("id = ? AND totp_enabled_at IS NULL AND totp_secret <> ''", id).
+			Updates(map[string]interface{}{
+				"totp_enabled_at": time.Now(),
+				"totp_last_step":  step,
+			})
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "[ShopRepository.EnableShopTOTP]: failed to update shop")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("[ShopRepository.EnableShopTOTP]: mfa already enabled")
+		}
+
+		if err := replaceRecoveryCodes(tx, id, codeHashes); err != nil {
+			return errors.Wrap(err, "[ShopRepository.EnableShopTOTP]: failed to store recovery codes")
+		}
+		return nil
+	})
+}
+
+func (r *shopRepository) DisableShopTOTP(ctx context.Context, id uint32) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.Shop{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"totp_secret":     "",
+				"totp_enabled_at": nil,
+				"totp_last_step":  0,
+			}).Error; err != nil {
+			return errors.Wrap(err, "[ShopRepository.DisableShopTOTP]: failed to update shop")
+		}
+		if err := tx.Where("shop_id = ?", id).Delete(&entity.ShopRecoveryCode{}).Error; err != nil {
+			return errors.Wrap(err, "[ShopRepository.DisableShopTOTP]: failed to delete recovery codes")
+		}
+		return nil
+	})
+}
+
+// UseShopTOTPStep records step as the last accepted one. It fails when a
+// code of this or a later step was accepted already, so concurrent requests
+// with one code cannot both get in.
+func (r *shopRepository) UseShopTOTPStep(ctx context.Context, id uint32, step int64) error {
+	result := r.db.WithContext(ctx).Model(&entity.Shop{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[ShopRepository.UseShopTOTPStep]: failed to update shop")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ShopRepository.UseShopTOTPStep]: code already used")
+	}
+	return nil
+}
+
+func (r *shopRepository) ReplaceShopRecoveryCodes(ctx context.Context, id uint32, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := replaceRecoveryCodes(tx, id, codeHashes); err != nil {
+			return errors.Wrap(err, "[ShopRepository.ReplaceShopRecoveryCodes]: failed to store recovery codes")
+		}
+		return nil
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, shopID uint32, codeHashes []string) error {
+	if err := tx.Where("shop_id = ?", shopID).Delete(&entity.ShopRecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]entity.ShopRecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, entity.ShopRecoveryCode{ShopID: shopID, CodeHash: hash})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Omit("Shop").Create(&codes).Error
+}
+
+// UseShopRecoveryCode marks an unused code of the shop as used
+func (r *shopRepository) UseShopRecoveryCode(ctx context.Context, id uint32, codeHash string) error {
+	result := r.db.WithContext(ctx).Model(&entity.ShopRecoveryCode{}).
+		Where("shop_id = ? AND code_hash = ? AND used_at IS NULL", id, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[ShopRepository.UseShopRecoveryCode]: failed to update recovery code")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[ShopRepository.UseShopRecoveryCode]: recovery code not found")
+	}
+	return nil
+}
+
+// CountShopRecoveryCodes counts the unused codes
+func (r *shopRepository) CountShopRecoveryCodes(ctx context.Context, id uint32) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&entity.ShopRecoveryCode{}).
+		Where("shop_id = ? AND used_at IS NULL", id).
+		Count(&count).Error; err != nil {
+		return 0, errors.Wrap(err, "[ShopRepository.CountShopRecoveryCodes]: failed to count recovery codes")
+	}
+	return count, nil
+}
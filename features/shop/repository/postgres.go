@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"time"
+
+	"order-management/database"
 	"order-management/domain"
 	"order-management/entity"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type shopRepository struct {
@@ -26,16 +32,17 @@ func NewShopRepository(db *gorm.DB) domain.ShopRepository {
 // Focus to log on the failed case
 // Happy case is not that important
 
-func (r *shopRepository) CreateShop(shop entity.Shop) error {
+// CreateShop sets the shop's id
+func (r *shopRepository) CreateShop(ctx context.Context, shop *entity.Shop) error {
 
 	log.Trace("Entering function CreateShop()")
 	defer log.Trace("Exiting function CreateShop()")
 
 	log.WithFields(log.Fields{
-		"shop": shop,
+		"name": shop.Name,
 	}).Debug("Creating shop")
 
-	if err := r.db.Create(&shop).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Create(shop).Error; err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			err = errors.New("[ShopRepository.CreateShop]: shop already exists")
 
@@ -50,12 +57,12 @@ func (r *shopRepository) CreateShop(shop entity.Shop) error {
 }
 
 // ✅
-func (r *shopRepository) GetAllShops() (shops []entity.Shop, err error) {
+func (r *shopRepository) GetAllShops(ctx context.Context) (shops []entity.Shop, err error) {
 
 	log.Trace("Entering function GetAllShops()")
 	defer log.Trace("Exiting function GetAllShops()")
 
-	if err := r.db.Find(&shops).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Where("deactivated_at IS NULL").Find(&shops).Error; err != nil {
 		err = errors.Wrap(err, "[ShopRepository.GetAllShops]: failed to get all shops")
 		return nil, err
 	}
@@ -64,7 +71,7 @@ func (r *shopRepository) GetAllShops() (shops []entity.Shop, err error) {
 }
 
 // ✅
-// func (r *shopRepository) GetProductsByShopID(shopID uint32) (products []entity.Product, err error) {
+// func (r *shopRepository) GetProductsByShopID(ctx context.Context, shopID uint32) (products []entity.Product, err error) {
 
 // 	log.Trace("Entering function GetProductsByShopID()")
 // 	defer log.Trace("Exiting function GetProductsByShopID()")
@@ -73,7 +80,7 @@ func (r *shopRepository) GetAllShops() (shops []entity.Shop, err error) {
 // 		"shopID": shopID,
 // 	}).Debug("Getting products by shop id")
 
-// 	if err := r.db.Where("shop_id = ?", shopID).Find(&products).Error; err != nil {
+// 	if err := database.Conn(ctx, r.db).Where("shop_id = ?", shopID).Find(&products).Error; err != nil {
 // 		err = errors.Wrap(err, "[ShopRepository.GetProductsByShopID]: failed to get products by shop id")
 // 		return nil, err
 // 	}
@@ -82,7 +89,7 @@ func (r *shopRepository) GetAllShops() (shops []entity.Shop, err error) {
 // }
 
 // ✅
-func (r *shopRepository) GetShopByName(name string) (shop entity.ShopWithOutPassword, err error) {
+func (r *shopRepository) GetShopByName(ctx context.Context, name string) (shop entity.ShopWithOutPassword, err error) {
 	log.Trace("Entering function GetShopByName()")
 	defer log.Trace("Exiting function GetShopByName()")
 
@@ -90,7 +97,7 @@ func (r *shopRepository) GetShopByName(name string) (shop entity.ShopWithOutPass
 		"name": name,
 	}).Debug("Getting shop by name")
 
-	if err := r.db.Model(&entity.Shop{}).Select("id", "name", "description").Where("name = ?", name).First(&shop).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Model(&entity.Shop{}).Select("id", "name", "slug", "description").Where("name = ?", name).First(&shop).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = errors.New("[ShopRepository.GetShopByName]: shop not found")
 			return entity.ShopWithOutPassword{}, err
@@ -103,7 +110,7 @@ func (r *shopRepository) GetShopByName(name string) (shop entity.ShopWithOutPass
 }
 
 // ✅
-func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Shop, err error) {
+func (r *shopRepository) GetShopByNameWithPassword(ctx context.Context, name string) (shop entity.Shop, err error) {
 	log.Trace("Entering function GetShopByNameWithPassword()")
 	defer log.Trace("Exiting function GetShopByNameWithPassword()")
 
@@ -111,7 +118,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 		"name": name,
 	}).Debug("Getting shop by name with password")
 
-	if err := r.db.Where("name = ?", name).First(&shop).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Where("name = ?", name).First(&shop).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = errors.New("[ShopRepository.GetShopByNameWithPassword]: shop not found")
 			return entity.Shop{}, err
@@ -124,7 +131,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 }
 
 // ✅
-// func (r *shopRepository) UpdateProduct(req *entity.ProductManagementRequest, newProduct *entity.Product) error {
+// func (r *shopRepository) UpdateProduct(ctx context.Context, req *entity.ProductManagementRequest, newProduct *entity.Product) error {
 // 	log.Trace("Entering function UpdateProduct()")
 // 	defer log.Trace("Exiting function UpdateProduct()")
 
@@ -133,7 +140,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 // 		"shopID":    req.ShopID,
 // 	}).Debug("Updating product")
 
-// 	if err := r.db.Model(&entity.Product{}).Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Updates(newProduct).Error; err != nil {
+// 	if err := database.Conn(ctx, r.db).Model(&entity.Product{}).Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Updates(newProduct).Error; err != nil {
 // 		err = errors.Wrap(err, "[ShopRepository.UpdateProduct]: failed to update product")
 // 		return err
 // 	}
@@ -142,7 +149,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 // }
 
 // // ✅
-// func (r *shopRepository) GetProductByID(productID uint32) (product entity.Product, err error) {
+// func (r *shopRepository) GetProductByID(ctx context.Context, productID uint32) (product entity.Product, err error) {
 // 	log.Trace("Entering function GetProductByID()")
 // 	defer log.Trace("Exiting function GetProductByID()")
 
@@ -150,7 +157,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 // 		"productID": productID,
 // 	}).Debug("Getting product by id")
 
-// 	if err := r.db.First(&product, productID).Error; err != nil {
+// 	if err := database.Conn(ctx, r.db).First(&product, productID).Error; err != nil {
 // 		err = errors.Wrap(err, "[ShopRepository.GetProductByID]: failed to get product by id")
 // 		return entity.Product{}, err
 // 	}
@@ -159,7 +166,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 // }
 
 // // ✅
-// func (r *shopRepository) DeleteProduct(req *entity.ProductManagementRequest) error {
+// func (r *shopRepository) DeleteProduct(ctx context.Context, req *entity.ProductManagementRequest) error {
 // 	log.Trace("Entering function DeleteProduct()")
 // 	defer log.Trace("Exiting function DeleteProduct()")
 
@@ -168,7 +175,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 // 		"shopID":    req.ShopID,
 // 	}).Debug("Deleting product")
 
-// 	if err := r.db.Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Delete(&entity.Product{}).Error; err != nil {
+// 	if err := database.Conn(ctx, r.db).Where("id = ? AND shop_id = ?", req.ProductID, req.ShopID).Delete(&entity.Product{}).Error; err != nil {
 // 		err = errors.Wrap(err, "[ShopRepository.DeleteProduct]: failed to delete product")
 // 		return err
 // 	}
@@ -177,7 +184,7 @@ func (r *shopRepository) GetShopByNameWithPassword(name string) (shop entity.Sho
 // }
 
 // ✅
-func (r *shopRepository) ShopExists(id uint32) (bool, error) {
+func (r *shopRepository) ShopExists(ctx context.Context, id uint32) (bool, error) {
 	log.Trace("Entering function ShopExists()")
 	defer log.Trace("Exiting function ShopExists()")
 
@@ -186,7 +193,7 @@ func (r *shopRepository) ShopExists(id uint32) (bool, error) {
 	}).Debug("Checking shop existence")
 
 	var exists bool
-	err := r.db.Model(&entity.Shop{}).
+	err := database.Conn(ctx, r.db).Model(&entity.Shop{}).
 		Select("count(*) > 0").
 		Where("id = ?", id).
 		Find(&exists).
@@ -198,3 +205,184 @@ func (r *shopRepository) ShopExists(id uint32) (bool, error) {
 
 	return exists, nil
 }
+
+func (r *shopRepository) GetShopByID(ctx context.Context, id uint32) (shop entity.Shop, err error) {
+	log.Trace("Entering function GetShopByID()")
+	defer log.Trace("Exiting function GetShopByID()")
+
+	log.WithFields(log.Fields{
+		"id": id,
+	}).Debug("Getting shop by id")
+
+	if err := database.Conn(ctx, r.db).Where("id = ?", id).First(&shop).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ShopRepository.GetShopByID]: shop not found")
+			return entity.Shop{}, err
+		}
+		err = errors.Wrap(err, "[ShopRepository.GetShopByID]: failed to get shop by id")
+		return entity.Shop{}, err
+	}
+
+	return shop, nil
+}
+
+func (r *shopRepository) UpdateShopProfile(ctx context.Context, id uint32, req entity.ShopUpdateRequest) error {
+	log.Trace("Entering function UpdateShopProfile()")
+	defer log.Trace("Exiting function UpdateShopProfile()")
+
+	log.WithFields(log.Fields{
+		"id":  id,
+		"req": req,
+	}).Debug("Updating shop profile")
+
+	result := database.Conn(ctx, r.db).Model(&entity.Shop{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"name":        req.Name,
+			"description": req.Description,
+		})
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			err := errors.New("[ShopRepository.UpdateShopProfile]: shop already exists")
+			return err
+		}
+		err := errors.Wrap(result.Error, "[ShopRepository.UpdateShopProfile]: failed to update shop profile")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		err := errors.New("[ShopRepository.UpdateShopProfile]: shop not found")
+		return err
+	}
+
+	return nil
+}
+
+// UpdateShopPassword stores the new hash and bumps the token version in one
+// statement, returning the version new tokens have to carry.
+func (r *shopRepository) UpdateShopPassword(ctx context.Context, id uint32, hashedPassword string) (uint32, error) {
+	log.Trace("Entering function UpdateShopPassword()")
+	defer log.Trace("Exiting function UpdateShopPassword()")
+
+	log.WithFields(log.Fields{
+		"id": id,
+	}).Debug("Updating shop password")
+
+	shop := entity.Shop{}
+	result := database.Conn(ctx, r.db).Model(&shop).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "token_version"}}}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"password":      hashedPassword,
+			"token_version": gorm.Expr("token_version + 1"),
+		})
+	if result.Error != nil {
+		err := errors.Wrap(result.Error, "[ShopRepository.UpdateShopPassword]: failed to update shop password")
+		return 0, err
+	}
+	if result.RowsAffected == 0 {
+		err := errors.New("[ShopRepository.UpdateShopPassword]: shop not found")
+		return 0, err
+	}
+
+	return shop.TokenVersion, nil
+}
+
+// SetShopDeactivated deactivates the shop at the given time, or reactivates it
+// when at is nil.
+func (r *shopRepository) SetShopDeactivated(ctx context.Context, id uint32, at *time.Time) error {
+	log.Trace("Entering function SetShopDeactivated()")
+	defer log.Trace("Exiting function SetShopDeactivated()")
+
+	log.WithFields(log.Fields{
+		"id": id,
+		"at": at,
+	}).Debug("Setting shop deactivation")
+
+	result := database.Conn(ctx, r.db).Model(&entity.Shop{}).
+		Where("id = ?", id).
+		Update("deactivated_at", at)
+	if result.Error != nil {
+		err := errors.Wrap(result.Error, "[ShopRepository.SetShopDeactivated]: failed to update shop")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		err := errors.New("[ShopRepository.SetShopDeactivated]: shop not found")
+		return err
+	}
+
+	return nil
+}
+
+func (r *shopRepository) GetShopBySlug(ctx context.Context, slug string) (shop entity.Shop, err error) {
+	log.Trace("Entering function GetShopBySlug()")
+	defer log.Trace("Exiting function GetShopBySlug()")
+
+	log.WithFields(log.Fields{
+		"slug": slug,
+	}).Debug("Getting shop by slug")
+
+	if err := database.Conn(ctx, r.db).Where("slug = ?", slug).First(&shop).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[ShopRepository.GetShopBySlug]: shop not found")
+			return entity.Shop{}, err
+		}
+		err = errors.Wrap(err, "[ShopRepository.GetShopBySlug]: failed to get shop by slug")
+		return entity.Shop{}, err
+	}
+
+	return shop, nil
+}
+
+// GetSlugsLike returns the slugs equal to base or base followed by a dash,
+// the candidates a new slug derived from base could collide with.
+func (r *shopRepository) GetSlugsLike(ctx context.Context, base string) (slugs []string, err error) {
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(base) + "-%"
+	if err := database.Conn(ctx, r.db).Model(&entity.Shop{}).
+		Where("slug = ? OR slug LIKE ?", base, pattern).
+		Pluck("slug", &slugs).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetSlugsLike]: failed to get slugs")
+		return nil, err
+	}
+	return slugs, nil
+}
+
+// GetShopStats counts the shop's live products, the completed orders that
+// contain any of its products (deleted ones included) and the reviews of its
+// live products.
+func (r *shopRepository) GetShopStats(ctx context.Context, shopID uint32) (entity.ShopStats, error) {
+	log.Trace("Entering function GetShopStats()")
+	defer log.Trace("Exiting function GetShopStats()")
+
+	stats := entity.ShopStats{}
+	db := database.Conn(ctx, r.db)
+
+	if err := db.Model(&entity.Product{}).Where("shop_id = ?", shopID).Count(&stats.ProductCount).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetShopStats]: failed to count products")
+		return entity.ShopStats{}, err
+	}
+
+	shopOrders := r.db.Table("order_products op").
+		Select("op.order_id").
+		Joins("JOIN products p ON p.id = op.product_id").
+		Where("p.shop_id = ?", shopID)
+	if err := db.Model(&entity.Order{}).
+		Where("status = ? AND id IN (?)", entity.COMPLETED, shopOrders).
+		Count(&stats.CompletedOrders).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetShopStats]: failed to count completed orders")
+		return entity.ShopStats{}, err
+	}
+
+	var counts []entity.RatingCount
+	if err := db.Model(&entity.ProductReview{}).
+		Select("product_reviews.rating, count(*) AS count").
+		Joins("JOIN products ON products.id = product_reviews.product_id AND products.deleted_at IS NULL").
+		Where("products.shop_id = ?", shopID).
+		Group("product_reviews.rating").
+		Scan(&counts).Error; err != nil {
+		err = errors.Wrap(err, "[ShopRepository.GetShopStats]: failed to summarise ratings")
+		return entity.ShopStats{}, err
+	}
+	stats.Rating = entity.NewRatingSummary(counts)
+
+	return stats, nil
+}
@@ -0,0 +1,202 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// apiKeyPrefix marks the keys of this service, so secret scanners and
+// people can recognise a leaked one
+const apiKeyPrefix = "omk_"
+
+// apiKeyMaxRateLimit caps the requests per minute a shop can give a key
+func apiKeyMaxRateLimit() int {
+	if limit := viper.GetInt("apikey.maxratelimit"); limit > 0 {
+		return limit
+	}
+	return 600
+}
+
+// newAPIKey returns a key with 200 random bits and its hash
+func newAPIKey() (string, string, error) {
+	b := make([]byte, 25)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key := apiKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return key, hashAPIKey(key), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func toAPIKeyResponse(key entity.ShopAPIKey) entity.APIKeyResponse {
+	return entity.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		RateLimit:  key.RateLimit,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// CreateAPIKey returns the new key, which is not stored and cannot be shown
+// again
+func (u *shopUsecase) CreateAPIKey(ctx context.Context, claims *entity.ShopJWT, req entity.APIKeyRequest) (entity.APIKeyCreated, error) {
+	log.WithFields(log.Fields{
+		"shopID": claims.ID,
+		"req":    req,
+	}).Debug("Creating api key")
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		err := errors.New("[ShopUsecase.CreateAPIKey]: name is required")
+		return entity.APIKeyCreated{}, err
+	}
+	if len(req.Scopes) == 0 {
+		err := errors.New("[ShopUsecase.CreateAPIKey]: invalid scope")
+		return entity.APIKeyCreated{}, err
+	}
+	scopes := []string{}
+	seen := map[entity.APIScope]bool{}
+	for _, scope := range req.Scopes {
+		if !scope.Valid() {
+			err := errors.New("[ShopUsecase.CreateAPIKey]: invalid scope")
+			return entity.APIKeyCreated{}, err
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, string(scope))
+		}
+	}
+	if req.RateLimit < 0 || req.RateLimit > apiKeyMaxRateLimit() {
+		err := errors.New("[ShopUsecase.CreateAPIKey]: invalid rate limit")
+		return entity.APIKeyCreated{}, err
+	}
+
+	secret, hash, err := newAPIKey()
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.CreateAPIKey]: failed to generate api key")
+		return entity.APIKeyCreated{}, err
+	}
+
+	key := entity.ShopAPIKey{
+		ShopID:    claims.ID,
+		Name:      req.Name,
+		Prefix:    secret[:len(apiKeyPrefix)+8],
+		KeyHash:   hash,
+		Scopes:    strings.Join(scopes, " "),
+		RateLimit: req.RateLimit,
+		CreatedBy: claims.UserID,
+	}
+	if err := u.shopRepo.CreateAPIKey(ctx, &key); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.CreateAPIKey]: failed to create api key")
+		return entity.APIKeyCreated{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"shopID": claims.ID,
+		"member": claims.UserID,
+		"keyID":  key.ID,
+		"scopes": key.Scopes,
+	}).Info("Shop created an api key")
+
+	return entity.APIKeyCreated{
+		APIKeyResponse: toAPIKeyResponse(key),
+		Key:            secret,
+	}, nil
+}
+
+func (u *shopUsecase) GetAPIKeys(ctx context.Context, shopID uint32) ([]entity.APIKeyResponse, error) {
+	keys, err := u.shopRepo.GetAPIKeys(ctx, shopID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetAPIKeys]: failed to get api keys")
+		return nil, err
+	}
+
+	response := make([]entity.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, toAPIKeyResponse(key))
+	}
+	return response, nil
+}
+
+func (u *shopUsecase) RevokeAPIKey(ctx context.Context, req *entity.APIKeyManagementRequest) error {
+	if err := u.shopRepo.RevokeAPIKey(ctx, req); err != nil {
+		if err.Error() == "[ShopRepository.RevokeAPIKey]: api key not found" {
+			err = errors.New("[ShopUsecase.RevokeAPIKey]: api key not found")
+			return err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.RevokeAPIKey]: failed to revoke api key")
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"shopID": req.ShopID,
+		"keyID":  req.KeyID,
+	}).Info("Shop revoked an api key")
+	return nil
+}
+
+// AuthenticateAPIKey returns the claims a request with key acts under: the
+// key's shop as OWNER, limited to the key's scopes. Failing to record the
+// use does not fail the request.
+func (u *shopUsecase) AuthenticateAPIKey(ctx context.Context, key string, ip string) (*entity.ShopJWT, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		err := errors.New("[ShopUsecase.AuthenticateAPIKey]: invalid api key")
+		return nil, err
+	}
+
+	apiKey, err := u.shopRepo.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetAPIKeyByHash]: api key not found" {
+			err = errors.New("[ShopUsecase.AuthenticateAPIKey]: invalid api key")
+			return nil, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.AuthenticateAPIKey]: failed to get api key")
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		log.WithFields(log.Fields{
+			"shopID": apiKey.ShopID,
+			"keyID":  apiKey.ID,
+		}).Warn("Revoked api key used")
+
+		err = errors.New("[ShopUsecase.AuthenticateAPIKey]: invalid api key")
+		return nil, err
+	}
+
+	if err := u.shopRepo.TouchAPIKey(ctx, apiKey.ID, ip, time.Now()); err != nil {
+		log.WithFields(log.Fields{
+			"keyID": apiKey.ID,
+		}).WithError(err).Warn("Failed to record api key use")
+	}
+
+	// The claims keep what VerifyToken needs of the shop
+	shop := apiKey.Shop
+	apiKey.Shop = entity.Shop{DeactivatedAt: shop.DeactivatedAt}
+	return &entity.ShopJWT{
+		ID:          shop.ID,
+		Name:        shop.Name,
+		Description: shop.Description,
+		Role:        entity.OWNER,
+		APIKey:      &apiKey,
+	}, nil
+}
@@ -0,0 +1,264 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"order-management/entity"
+	productUsecase "order-management/features/product/usecase"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// thumbnailSizes are the bounding boxes, in pixels, thumbnails are scaled
+// down to. Images smaller than a box are stored as is.
+var thumbnailSizes = []struct {
+	Name string
+	Size int
+}{
+	{Name: "small", Size: 200},
+	{Name: "medium", Size: 600},
+}
+
+// Decoders for the content types accepted on upload, keyed by the sniffed
+// mime type rather than whatever the client claims.
+var imageDecoders = map[string]func(io.Reader) (image.Image, error){
+	"image/jpeg": jpeg.Decode,
+	"image/png":  png.Decode,
+	"image/gif":  gif.Decode,
+	"image/webp": webp.Decode,
+}
+
+var imageConfigDecoders = map[string]func(io.Reader) (image.Config, error){
+	"image/jpeg": jpeg.DecodeConfig,
+	"image/png":  png.DecodeConfig,
+	"image/gif":  gif.DecodeConfig,
+	"image/webp": webp.DecodeConfig,
+}
+
+// Refuse to decode anything bigger, a small file can still claim huge
+// dimensions
+const maxImagePixels = 40_000_000
+
+func (u *shopUsecase) UploadProductImage(ctx context.Context, req *entity.ProductManagementRequest, r io.Reader) (entity.ProductImageResponse, error) {
+	log.Trace("Entering function UploadProductImage()")
+	defer log.Trace("Exiting function UploadProductImage()")
+
+	log.WithFields(log.Fields{
+		"req": req,
+	}).Debug("Uploading product image")
+
+	if _, err := u.ownedProduct(ctx, "UploadProductImage", req.ShopID, req.ProductID); err != nil {
+		return entity.ProductImageResponse{}, err
+	}
+
+	count, err := u.productRepo.CountProductImages(ctx, req.ProductID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to count product images")
+		return entity.ProductImageResponse{}, err
+	}
+	if count >= int64(maxImagesPerProduct()) {
+		return entity.ProductImageResponse{}, errors.New("[ShopUsecase.UploadProductImage]: too many images")
+	}
+
+	maxSize := maxImageSize()
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to read image")
+		return entity.ProductImageResponse{}, err
+	}
+	if int64(len(data)) > maxSize {
+		return entity.ProductImageResponse{}, errors.New("[ShopUsecase.UploadProductImage]: image too large")
+	}
+
+	mtype := mimetype.Detect(data)
+	contentType := mtype.String()
+	decode, ok := imageDecoders[contentType]
+	if !ok {
+		return entity.ProductImageResponse{}, errors.New("[ShopUsecase.UploadProductImage]: unsupported image type")
+	}
+
+	config, err := imageConfigDecoders[contentType](bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImagePixels {
+		return entity.ProductImageResponse{}, errors.New("[ShopUsecase.UploadProductImage]: invalid image")
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return entity.ProductImageResponse{}, errors.New("[ShopUsecase.UploadProductImage]: invalid image")
+	}
+
+	name, err := randomName()
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to generate file name")
+		return entity.ProductImageResponse{}, err
+	}
+	base := fmt.Sprintf("products/%d/%s", req.ProductID, name)
+
+	productImage := entity.ProductImage{
+		ProductID:   req.ProductID,
+		Key:         base + mtype.Extension(),
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+
+	stored := []string{}
+	cleanup := func() {
+		for _, key := range stored {
+			if err := u.store.Delete(context.WithoutCancel(ctx), key); err != nil {
+				log.WithError(err).WithField("key", key).Warn("Failed to remove blob of a failed upload")
+			}
+		}
+	}
+
+	if err := u.store.Put(ctx, productImage.Key, bytes.NewReader(data), contentType); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to store image")
+		return entity.ProductImageResponse{}, err
+	}
+	stored = append(stored, productImage.Key)
+
+	for _, size := range thumbnailSizes {
+		thumbnail, err := encodeThumbnail(img, contentType, size.Size)
+		if err != nil {
+			cleanup()
+			err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to create thumbnail")
+			return entity.ProductImageResponse{}, err
+		}
+
+		key := base + "_" + size.Name + thumbnail.ext
+		if err := u.store.Put(ctx, key, bytes.NewReader(thumbnail.data), thumbnail.contentType); err != nil {
+			cleanup()
+			err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to store thumbnail")
+			return entity.ProductImageResponse{}, err
+		}
+		stored = append(stored, key)
+
+		productImage.Thumbnails = append(productImage.Thumbnails, entity.ProductImageThumbnail{
+			Size:   size.Name,
+			Key:    key,
+			Width:  thumbnail.width,
+			Height: thumbnail.height,
+		})
+	}
+
+	if err := u.productRepo.CreateProductImage(ctx, &productImage); err != nil {
+		cleanup()
+		err = errors.Wrap(err, "[ShopUsecase.UploadProductImage]: failed to save image")
+		return entity.ProductImageResponse{}, err
+	}
+
+	return productUsecase.ImageResponse(u.store, productImage), nil
+}
+
+func (u *shopUsecase) DeleteProductImage(ctx context.Context, req *entity.ProductImageManagementRequest) error {
+	log.Trace("Entering function DeleteProductImage()")
+	defer log.Trace("Exiting function DeleteProductImage()")
+
+	log.WithFields(log.Fields{
+		"req": req,
+	}).Debug("Deleting product image")
+
+	if _, err := u.ownedProduct(ctx, "DeleteProductImage", req.ShopID, req.ProductID); err != nil {
+		return err
+	}
+
+	image, err := u.productRepo.DeleteProductImage(ctx, req)
+	if err != nil {
+		if err.Error() == "[ProductRepository.DeleteProductImage]: image not found" {
+			return errors.New("[ShopUsecase.DeleteProductImage]: image not found")
+		}
+		err = errors.Wrap(err, "[ShopUsecase.DeleteProductImage]: failed to delete image")
+		return err
+	}
+
+	// The row is gone, a blob left behind is only wasted space
+	keys := []string{image.Key}
+	for _, thumbnail := range image.Thumbnails {
+		keys = append(keys, thumbnail.Key)
+	}
+	for _, key := range keys {
+		if err := u.store.Delete(ctx, key); err != nil {
+			log.WithError(err).WithField("key", key).Warn("Failed to remove image blob")
+		}
+	}
+	return nil
+}
+
+type encodedThumbnail struct {
+	data          []byte
+	width, height int
+	contentType   string
+	ext           string
+}
+
+// encodeThumbnail scales img to fit in a size x size box. JPEG sources stay
+// JPEG, everything else becomes PNG to keep transparency.
+func encodeThumbnail(img image.Image, contentType string, size int) (encodedThumbnail, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			height = max(1, height*size/width)
+			width = size
+		} else {
+			width = max(1, width*size/height)
+			height = size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+	thumbnail := encodedThumbnail{width: width, height: height}
+	buf := bytes.Buffer{}
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return encodedThumbnail{}, err
+		}
+		thumbnail.contentType, thumbnail.ext = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, dst); err != nil {
+			return encodedThumbnail{}, err
+		}
+		thumbnail.contentType, thumbnail.ext = "image/png", ".png"
+	}
+	thumbnail.data = buf.Bytes()
+	return thumbnail, nil
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// maxImageSize is product.maximagesize in bytes, 10 MiB by default
+func maxImageSize() int64 {
+	if size := viper.GetInt64("product.maximagesize"); size > 0 {
+		return size
+	}
+	return 10 << 20
+}
+
+// maxImagesPerProduct is product.maximages, 10 by default
+func maxImagesPerProduct() int {
+	if n := viper.GetInt("product.maximages"); n > 0 {
+		return n
+	}
+	return 10
+}
@@ -0,0 +1,357 @@
+package usecase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// productFileColumns is the CSV layout of exports, imports accept the
+// columns in any order and only require sku, name and price
+var productFileColumns = []string{"sku", "name", "description", "price", "category", "tags"}
+
+// Tags share one CSV column
+const tagSeparator = "|"
+
+// ImportProducts creates or updates the shop's products by SKU. Every row is
+// validated first, if any fails nothing is written and the errors are
+// reported per line. A dry run stops after validation.
+func (u *shopUsecase) ImportProducts(ctx context.Context, shopID uint32, format string, r io.Reader, dryRun bool) (entity.ProductImportResult, error) {
+	log.Trace("Entering function ImportProducts()")
+	defer log.Trace("Exiting function ImportProducts()")
+
+	log.WithFields(log.Fields{
+		"shopID": shopID,
+		"format": format,
+		"dryRun": dryRun,
+	}).Debug("Importing products")
+
+	result := entity.ProductImportResult{
+		DryRun: dryRun,
+		Errors: []entity.ProductImportError{},
+	}
+
+	var rows []entity.ProductImportRow
+	var err error
+	switch format {
+	case entity.ProductFileCSV:
+		rows, result.Errors, err = parseProductCSV(r)
+	case entity.ProductFileJSONL:
+		rows, result.Errors, err = parseProductJSONL(r)
+	default:
+		return entity.ProductImportResult{}, errors.New("[ShopUsecase.ImportProducts]: unsupported format")
+	}
+	if err != nil {
+		return entity.ProductImportResult{}, errors.Wrap(err, "[ShopUsecase.ImportProducts]: invalid file")
+	}
+
+	if len(rows)+len(result.Errors) > maxImportRows() {
+		return entity.ProductImportResult{}, errors.New("[ShopUsecase.ImportProducts]: too many rows")
+	}
+
+	skus := make([]string, 0, len(rows))
+	slugs := []string{}
+	for i := range rows {
+		skus = append(skus, rows[i].SKU)
+		if rows[i].Category != "" {
+			rows[i].Category = utils.Slugify(rows[i].Category)
+			slugs = append(slugs, rows[i].Category)
+		}
+	}
+
+	existing, err := u.productRepo.GetProductIDsBySKUs(ctx, shopID, skus)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.ImportProducts]: failed to get existing products")
+		return entity.ProductImportResult{}, err
+	}
+	categories, err := u.productRepo.GetCategoryIDsBySlugs(ctx, slugs)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.ImportProducts]: failed to get categories")
+		return entity.ProductImportResult{}, err
+	}
+
+	products := make([]entity.Product, 0, len(rows))
+	seen := map[string]int{}
+	for _, row := range rows {
+		if line, ok := seen[row.SKU]; ok {
+			result.Errors = append(result.Errors, entity.ProductImportError{
+				Line:  row.Line,
+				SKU:   row.SKU,
+				Error: "duplicate sku, first seen on line " + strconv.Itoa(line),
+			})
+			continue
+		}
+		seen[row.SKU] = row.Line
+
+		sku := row.SKU
+		product := entity.Product{
+			ID:          existing[row.SKU],
+			SKU:         &sku,
+			Name:        row.Name,
+			Description: row.Description,
+			Price:       *row.Price,
+			TagNames:    row.Tags,
+		}
+		if product.TagNames == nil {
+			product.TagNames = []string{}
+		}
+		if row.Category != "" {
+			categoryID, ok := categories[row.Category]
+			if !ok {
+				result.Errors = append(result.Errors, entity.ProductImportError{
+					Line:  row.Line,
+					SKU:   row.SKU,
+					Error: "unknown category " + row.Category,
+				})
+				continue
+			}
+			product.CategoryID = &categoryID
+		}
+
+		if product.ID == 0 {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		products = append(products, product)
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+
+	err = u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		ids := []uint32{}
+		for _, product := range products {
+			if product.ID != 0 {
+				ids = append(ids, product.ID)
+			}
+		}
+		before, err := u.productRepo.GetProductPrices(ctx, shopID, ids)
+		if err != nil {
+			return err
+		}
+		if err := u.productRepo.ImportProducts(ctx, shopID, products); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := u.productUpdated(ctx, shopID, id); err != nil {
+				return err
+			}
+		}
+
+		for _, product := range products {
+			oldPrice, ok := before[product.ID]
+			if !ok || product.Price == oldPrice {
+				continue
+			}
+			if err := u.events.Publish(ctx, entity.EventProductPriceChanged, product.ID, entity.ProductPriceChangedEvent{
+				ProductID: product.ID,
+				ShopID:    shopID,
+				OldPrice:  oldPrice,
+				NewPrice:  product.Price,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if err.Error() == "[ProductRepository.ImportProducts]: sku already exists" {
+			// Another request created one of the SKUs since we looked
+			return entity.ProductImportResult{}, errors.New("[ShopUsecase.ImportProducts]: sku already exists")
+		}
+		err = errors.Wrap(err, "[ShopUsecase.ImportProducts]: failed to import products")
+		return entity.ProductImportResult{}, err
+	}
+	return result, nil
+}
+
+// ExportProducts writes the shop's catalog as CSV in the layout
+// ImportProducts reads.
+func (u *shopUsecase) ExportProducts(ctx context.Context, shopID uint32, w io.Writer) error {
+	log.Trace("Entering function ExportProducts()")
+	defer log.Trace("Exiting function ExportProducts()")
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(productFileColumns); err != nil {
+		return errors.Wrap(err, "[ShopUsecase.ExportProducts]: failed to write csv")
+	}
+
+	err := u.productRepo.ExportProducts(ctx, shopID, func(products []entity.Product) error {
+		for _, product := range products {
+			sku, category := "", ""
+			if product.SKU != nil {
+				sku = *product.SKU
+			}
+			if product.Category != nil {
+				category = product.Category.Slug
+			}
+			tags := make([]string, 0, len(product.Tags))
+			for _, tag := range product.Tags {
+				tags = append(tags, tag.Name)
+			}
+
+			cw.Write([]string{
+				sku,
+				product.Name,
+				product.Description,
+				strconv.FormatUint(uint64(product.Price), 10),
+				category,
+				strings.Join(tags, tagSeparator),
+			})
+		}
+		// Hand each batch to the client instead of buffering the catalog
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return errors.Wrap(err, "[ShopUsecase.ExportProducts]: failed to export products")
+	}
+	return nil
+}
+
+// parseProductCSV returns the rows that parsed and an error per row that did
+// not. A malformed file, as opposed to a bad row, is an error.
+func parseProductCSV(r io.Reader) ([]entity.ProductImportRow, []entity.ProductImportError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, errors.New("[ShopUsecase.parseProductCSV]: missing header")
+		}
+		return nil, nil, errors.Wrap(err, "[ShopUsecase.parseProductCSV]: failed to read header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"sku", "name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, errors.New("[ShopUsecase.parseProductCSV]: missing column " + name)
+		}
+	}
+
+	rows := []entity.ProductImportRow{}
+	rowErrors := []entity.ProductImportError{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "[ShopUsecase.parseProductCSV]: failed to read row")
+		}
+		line, _ := cr.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := entity.ProductImportRow{
+			Line:        line,
+			SKU:         field("sku"),
+			Name:        field("name"),
+			Description: field("description"),
+			Category:    field("category"),
+		}
+		if tags := field("tags"); tags != "" {
+			row.Tags = strings.Split(tags, tagSeparator)
+		}
+		if price := field("price"); price != "" {
+			p, err := strconv.ParseUint(price, 10, 32)
+			if err != nil {
+				rowErrors = append(rowErrors, entity.ProductImportError{Line: line, SKU: row.SKU, Error: "invalid price"})
+				continue
+			}
+			p32 := uint32(p)
+			row.Price = &p32
+		}
+
+		if message := validateImportRow(row); message != "" {
+			rowErrors = append(rowErrors, entity.ProductImportError{Line: line, SKU: row.SKU, Error: message})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// parseProductJSONL reads one JSON object per line, blank lines are skipped
+func parseProductJSONL(r io.Reader) ([]entity.ProductImportRow, []entity.ProductImportError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	rows := []entity.ProductImportRow{}
+	rowErrors := []entity.ProductImportError{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := entity.ProductImportRow{}
+		if err := json.Unmarshal(text, &row); err != nil {
+			rowErrors = append(rowErrors, entity.ProductImportError{Line: line, Error: "invalid json"})
+			continue
+		}
+		row.Line = line
+		row.SKU = strings.TrimSpace(row.SKU)
+		row.Name = strings.TrimSpace(row.Name)
+
+		if message := validateImportRow(row); message != "" {
+			rowErrors = append(rowErrors, entity.ProductImportError{Line: line, SKU: row.SKU, Error: message})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "[ShopUsecase.parseProductJSONL]: failed to read line")
+	}
+	return rows, rowErrors, nil
+}
+
+func validateImportRow(row entity.ProductImportRow) string {
+	switch {
+	case row.SKU == "":
+		return "sku is required"
+	case row.Name == "":
+		return "name is required"
+	case row.Price == nil:
+		return "price is required"
+	}
+	return ""
+}
+
+// maxImportRows is product.importmaxrows, 5000 by default
+func maxImportRows() int {
+	if n := viper.GetInt("product.importmaxrows"); n > 0 {
+		return n
+	}
+	return 5000
+}
@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"context"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func toMemberResponse(member entity.ShopMember) entity.MemberResponse {
+	return entity.MemberResponse{
+		ID:          member.ID,
+		UserID:      member.UserID,
+		Email:       member.User.Email,
+		Role:        member.Role,
+		Permissions: member.Role.Permissions(),
+		CreatedAt:   member.CreatedAt,
+	}
+}
+
+func (u *shopUsecase) GetMembers(ctx context.Context, shopID uint32) ([]entity.MemberResponse, error) {
+	members, err := u.shopRepo.GetMembers(ctx, shopID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetMembers]: failed to get members")
+		return nil, err
+	}
+
+	membersResponse := make([]entity.MemberResponse, 0, len(members))
+	for _, member := range members {
+		membersResponse = append(membersResponse, toMemberResponse(member))
+	}
+	return membersResponse, nil
+}
+
+// AddMember gives an already registered user access to the shop
+func (u *shopUsecase) AddMember(ctx context.Context, shopID uint32, req entity.MemberRequest) (entity.MemberResponse, error) {
+	log.WithFields(log.Fields{
+		"shopID": shopID,
+		"req":    req,
+	}).Debug("Adding shop member")
+
+	if !req.Role.Valid() {
+		err := errors.New("[ShopUsecase.AddMember]: invalid role")
+		return entity.MemberResponse{}, err
+	}
+
+	member, err := u.shopRepo.CreateMember(ctx, shopID, req)
+	if err != nil {
+		switch err.Error() {
+		case "[ShopRepository.CreateMember]: user not found":
+			err = errors.New("[ShopUsecase.AddMember]: user not found")
+			return entity.MemberResponse{}, err
+		case "[ShopRepository.CreateMember]: member already exists":
+			err = errors.New("[ShopUsecase.AddMember]: member already exists")
+			return entity.MemberResponse{}, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.AddMember]: failed to create member")
+		return entity.MemberResponse{}, err
+	}
+
+	return toMemberResponse(member), nil
+}
+
+func (u *shopUsecase) UpdateMemberRole(ctx context.Context, req *entity.MemberManagementRequest, role entity.MemberRole) error {
+	if !role.Valid() {
+		err := errors.New("[ShopUsecase.UpdateMemberRole]: invalid role")
+		return err
+	}
+
+	if err := u.shopRepo.UpdateMemberRole(ctx, req, role); err != nil {
+		if err.Error() == "[ShopRepository.UpdateMemberRole]: member not found" {
+			err = errors.New("[ShopUsecase.UpdateMemberRole]: member not found")
+			return err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.UpdateMemberRole]: failed to update member role")
+		return err
+	}
+	return nil
+}
+
+// RemoveMember revokes the member's shop tokens right away, VerifyToken no
+// longer finds the membership.
+func (u *shopUsecase) RemoveMember(ctx context.Context, req *entity.MemberManagementRequest) error {
+	if err := u.shopRepo.DeleteMember(ctx, req); err != nil {
+		if err.Error() == "[ShopRepository.DeleteMember]: member not found" {
+			err = errors.New("[ShopUsecase.RemoveMember]: member not found")
+			return err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.RemoveMember]: failed to delete member")
+		return err
+	}
+	return nil
+}
+
+func (u *shopUsecase) GetMemberships(ctx context.Context, userID uint32) ([]entity.MembershipResponse, error) {
+	members, err := u.shopRepo.GetMembershipsByUserID(ctx, userID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetMemberships]: failed to get memberships")
+		return nil, err
+	}
+
+	memberships := make([]entity.MembershipResponse, 0, len(members))
+	for _, member := range members {
+		memberships = append(memberships, entity.MembershipResponse{
+			ShopID:      member.ShopID,
+			ShopName:    member.Shop.Name,
+			ShopSlug:    member.Shop.Slug,
+			Role:        member.Role,
+			Permissions: member.Role.Permissions(),
+		})
+	}
+	return memberships, nil
+}
+
+// SwitchContext reissues the account token with the given shop as active
+// context, or without one for shopID zero to act as a buyer again.
+func (u *shopUsecase) SwitchContext(ctx context.Context, user *entity.UserJWT, shopID uint32) (string, error) {
+	log.WithFields(log.Fields{
+		"userID": user.ID,
+		"shopID": shopID,
+	}).Debug("Switching account context")
+
+	claims := map[string]interface{}{
+		"id":      user.ID,
+		"email":   user.Email,
+		"address": user.Address,
+		"role":    user.Role,
+	}
+
+	if shopID != 0 {
+		member, err := u.shopRepo.GetMemberByUserID(ctx, shopID, user.ID)
+		if err != nil {
+			if err.Error() == "[ShopRepository.GetMemberByUserID]: member not found" {
+				err = errors.New("[ShopUsecase.SwitchContext]: not a member of the shop")
+				return "", err
+			}
+			err = errors.Wrap(err, "[ShopUsecase.SwitchContext]: failed to get member")
+			return "", err
+		}
+		claims["shop"] = member.ShopID
+		claims["shopName"] = member.Shop.Name
+		claims["shopRole"] = member.Role
+	}
+
+	t, err := utils.GenerateJWT(claims, entity.UserTokenAudience)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.SwitchContext]: failed to generate user jwt")
+		return "", err
+	}
+	return t, nil
+}
+
+// LinkShop makes the account an owner of a shop registered with its own
+// name and password, the migration path off shop credentials.
+// LinkShop checks the shop password like Login does, so it shares the
+// shop's failed login count, and the shop's second factor when it has one
+func (u *shopUsecase) LinkShop(ctx context.Context, userID uint32, req entity.LinkShopRequest, ip string) (entity.MembershipResponse, error) {
+	log.WithFields(log.Fields{
+		"userID": userID,
+		"name":   req.Name,
+		"ip":     ip,
+	}).Debug("Linking shop to account")
+
+	shopKey := entity.ShopLoginKey(req.Name)
+	keys := []string{shopKey, entity.IPLoginKey(ip)}
+
+	wait, err := u.guard.Check(ctx, keys...)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.LinkShop]: failed to check login attempts")
+		return entity.MembershipResponse{}, err
+	}
+	if wait > 0 {
+		err = errors.Wrap(&entity.LoginLockedError{RetryAfter: wait}, "[ShopUsecase.LinkShop]")
+		return entity.MembershipResponse{}, err
+	}
+
+	shop, err := u.shopRepo.GetShopByNameWithPassword(ctx, req.Name)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByNameWithPassword]: shop not found" {
+			u.loginFailed(ctx, keys)
+			err = errors.New("[ShopUsecase.LinkShop]: shop not found")
+			return entity.MembershipResponse{}, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.LinkShop]: failed to get shop by name with password")
+		return entity.MembershipResponse{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(shop.Password), []byte(req.Password)); err != nil {
+		u.loginFailed(ctx, keys)
+		err = errors.New("[ShopUsecase.LinkShop]: invalid password")
+		return entity.MembershipResponse{}, err
+	}
+
+	// The password alone must not get around the shop's second factor
+	if shop.TOTPEnabledAt != nil {
+		if err := u.verifyCode(ctx, shop, req.Code, true, keys); err != nil {
+			if err.Error() == "[ShopUsecase.verifyCode]: invalid code" {
+				err = errors.New("[ShopUsecase.LinkShop]: invalid code")
+				return entity.MembershipResponse{}, err
+			}
+			err = errors.Wrap(err, "[ShopUsecase.LinkShop]")
+			return entity.MembershipResponse{}, err
+		}
+	}
+
+	u.loginSucceeded(ctx, shopKey)
+
+	if err := u.shopRepo.UpsertOwner(ctx, shop.ID, userID); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.LinkShop]: failed to add owner")
+		return entity.MembershipResponse{}, err
+	}
+
+	return entity.MembershipResponse{
+		ShopID:      shop.ID,
+		ShopName:    shop.Name,
+		ShopSlug:    shop.Slug,
+		Role:        entity.OWNER,
+		Permissions: entity.OWNER.Permissions(),
+	}, nil
+}
+
+// CreateOwnedShop registers a shop without credentials of its own, only its
+// members can act for it.
+func (u *shopUsecase) CreateOwnedShop(ctx context.Context, userID uint32, req entity.ShopUpdateRequest) (entity.MembershipResponse, error) {
+	log.WithFields(log.Fields{
+		"userID": userID,
+		"req":    req,
+	}).Debug("Creating shop owned by account")
+
+	slug, err := u.uniqueSlug(ctx, req.Name)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.CreateOwnedShop]: failed to generate slug")
+		return entity.MembershipResponse{}, err
+	}
+
+	shop := entity.Shop{
+		Name:        req.Name,
+		Slug:        slug,
+		Description: req.Description,
+	}
+	err = u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := u.shopRepo.CreateShopWithOwner(ctx, &shop, userID); err != nil {
+			return err
+		}
+		return u.events.Publish(ctx, entity.EventShopRegistered, shop.ID, entity.ShopRegisteredEvent{
+			ShopID:  shop.ID,
+			Name:    shop.Name,
+			Slug:    shop.Slug,
+			OwnerID: userID,
+		})
+	})
+	if err != nil {
+		if err.Error() == "[ShopRepository.CreateShopWithOwner]: shop already exists" {
+			err = errors.New("[ShopUsecase.CreateOwnedShop]: shop already exists")
+			return entity.MembershipResponse{}, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.CreateOwnedShop]: failed to create shop")
+		return entity.MembershipResponse{}, err
+	}
+
+	return entity.MembershipResponse{
+		ShopID:      shop.ID,
+		ShopName:    shop.Name,
+		ShopSlug:    shop.Slug,
+		Role:        entity.OWNER,
+		Permissions: entity.OWNER.Permissions(),
+	}, nil
+}
+
+func (u *shopUsecase) GetFinances(ctx context.Context, shopID uint32) (entity.ShopFinances, error) {
+	finances, err := u.shopRepo.GetShopFinances(ctx, shopID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetFinances]: failed to get finances")
+		return entity.ShopFinances{}, err
+	}
+	return finances, nil
+}
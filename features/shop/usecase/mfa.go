@@ -0,0 +1,413 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const recoveryCodeCount = 10
+
+// mfaTokenTTL is how long a shop has to enter its code after the password
+func mfaTokenTTL() time.Duration {
+	if ttl := viper.GetDuration("mfa.tokenttl"); ttl > 0 {
+		return ttl
+	}
+	return 5 * time.Minute
+}
+
+// mfaIssuer names this service in authenticator apps
+func mfaIssuer() string {
+	if issuer := viper.GetString("mfa.issuer"); issuer != "" {
+		return issuer
+	}
+	return "Order Management"
+}
+
+// newRecoveryCodes returns the codes to show once and the hashes to store.
+// Codes are ten base32 characters split by a dash, e.g. 7kq2m-xw4ta.
+func newRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b)[:10])
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, which people get wrong
+// when typing codes off paper
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (u *shopUsecase) mfaRequired(ctx context.Context) (bool, error) {
+	policy, err := u.mfa.GetMFAPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return policy.RequireShops, nil
+}
+
+// startMFALogin answers a correct password of a shop that needs a second
+// factor with an MFA token instead of the shop token
+func startMFALogin(shop entity.Shop) (entity.ShopLogin, error) {
+	token, err := utils.GenerateJWTWithTTL(map[string]interface{}{
+		"id":  shop.ID,
+		"ver": shop.TokenVersion,
+	}, entity.ShopMFATokenAudience, mfaTokenTTL())
+	if err != nil {
+		return entity.ShopLogin{}, err
+	}
+	return entity.ShopLogin{
+		MFAToken:    token,
+		MFAEnrolled: shop.TOTPEnabledAt != nil,
+	}, nil
+}
+
+// parseMFAToken returns the shop of a pending login. A password change since
+// the token was issued voids it.
+func (u *shopUsecase) parseMFAToken(ctx context.Context, token string) (entity.Shop, error) {
+	claims := &entity.ShopMFAJWT{}
+	if err := utils.ParseJWT(token, entity.ShopMFATokenAudience, claims); err != nil {
+		log.WithError(err).Warn("Invalid MFA token")
+		return entity.Shop{}, errors.New("[ShopUsecase.parseMFAToken]: invalid mfa token")
+	}
+
+	shop, err := u.shopRepo.GetShopByID(ctx, claims.ID)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByID]: shop not found" {
+			return entity.Shop{}, errors.New("[ShopUsecase.parseMFAToken]: invalid mfa token")
+		}
+		return entity.Shop{}, errors.Wrap(err, "[ShopUsecase.parseMFAToken]: failed to get shop")
+	}
+	if shop.TokenVersion != claims.Version {
+		return entity.Shop{}, errors.New("[ShopUsecase.parseMFAToken]: invalid mfa token")
+	}
+	return shop, nil
+}
+
+// checkCode accepts a current authenticator code of an enabled shop or, with
+// allowRecovery, one of its unused recovery codes
+func (u *shopUsecase) checkCode(ctx context.Context, shop entity.Shop, code string, allowRecovery bool) error {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+
+	if step, ok := utils.MatchTOTP(shop.TOTPSecret, code, time.Now()); ok {
+		if err := u.shopRepo.UseShopTOTPStep(ctx, shop.ID, step); err != nil {
+			if err.Error() == "[ShopRepository.UseShopTOTPStep]: code already used" {
+				return errors.New("[ShopUsecase.checkCode]: invalid code")
+			}
+			return errors.Wrap(err, "[ShopUsecase.checkCode]: failed to use code")
+		}
+		return nil
+	}
+
+	if !allowRecovery || code == "" {
+		return errors.New("[ShopUsecase.checkCode]: invalid code")
+	}
+	if err := u.shopRepo.UseShopRecoveryCode(ctx, shop.ID, hashRecoveryCode(code)); err != nil {
+		if err.Error() == "[ShopRepository.UseShopRecoveryCode]: recovery code not found" {
+			return errors.New("[ShopUsecase.checkCode]: invalid code")
+		}
+		return errors.Wrap(err, "[ShopUsecase.checkCode]: failed to use recovery code")
+	}
+
+	log.WithFields(log.Fields{
+		"shopID": shop.ID,
+	}).Info("Shop used a recovery code")
+	return nil
+}
+
+// verifyCode is checkCode behind the shop's login guard, so codes cannot be
+// guessed any faster than passwords
+func (u *shopUsecase) verifyCode(ctx context.Context, shop entity.Shop, code string, allowRecovery bool, keys []string) error {
+	wait, err := u.guard.Check(ctx, keys...)
+	if err != nil {
+		return errors.Wrap(err, "[ShopUsecase.verifyCode]: failed to check login attempts")
+	}
+	if wait > 0 {
+		return errors.Wrap(&entity.LoginLockedError{RetryAfter: wait}, "[ShopUsecase.verifyCode]")
+	}
+
+	if err := u.checkCode(ctx, shop, code, allowRecovery); err != nil {
+		if err.Error() == "[ShopUsecase.checkCode]: invalid code" {
+			u.loginFailed(ctx, keys)
+			return errors.New("[ShopUsecase.verifyCode]: invalid code")
+		}
+		return errors.Wrap(err, "[ShopUsecase.verifyCode]: failed to check code")
+	}
+	return nil
+}
+
+// enrol stores a new secret that becomes active once a code of it is
+// confirmed
+func (u *shopUsecase) enrol(ctx context.Context, shop entity.Shop) (entity.MFAEnrolment, error) {
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return entity.MFAEnrolment{}, errors.Wrap(err, "[ShopUsecase.enrol]: failed to generate secret")
+	}
+	if err := u.shopRepo.SetShopTOTPSecret(ctx, shop.ID, secret); err != nil {
+		if err.Error() == "[ShopRepository.SetShopTOTPSecret]: mfa already enabled" {
+			return entity.MFAEnrolment{}, errors.New("[ShopUsecase.enrol]: mfa already enabled")
+		}
+		return entity.MFAEnrolment{}, errors.Wrap(err, "[ShopUsecase.enrol]: failed to store secret")
+	}
+
+	return entity.MFAEnrolment{
+		Secret: secret,
+		URI:    utils.TOTPURI(mfaIssuer(), shop.Name, secret),
+	}, nil
+}
+
+// confirm enables the enrolled secret with a code of it and returns fresh
+// recovery codes
+func (u *shopUsecase) confirm(ctx context.Context, shop entity.Shop, code string) ([]string, error) {
+	step, ok := utils.MatchTOTP(shop.TOTPSecret, strings.ReplaceAll(strings.TrimSpace(code), " ", ""), time.Now())
+	if !ok {
+		return nil, errors.New("[ShopUsecase.confirm]: invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "[ShopUsecase.confirm]: failed to generate recovery codes")
+	}
+	if err := u.shopRepo.EnableShopTOTP(ctx, shop.ID, step, hashes); err != nil {
+		if err.Error() == "[ShopRepository.EnableShopTOTP]: mfa already enabled" {
+			return nil, errors.New("[ShopUsecase.confirm]: mfa already enabled")
+		}
+		return nil, errors.Wrap(err, "[ShopUsecase.confirm]: failed to enable mfa")
+	}
+
+	log.WithFields(log.Fields{
+		"shopID": shop.ID,
+	}).Info("Shop enabled two-factor authentication")
+	return codes, nil
+}
+
+// LoginMFA finishes a login with the MFA token and an authenticator or
+// recovery code. A shop that enrolled during the login confirms its
+// enrolment with the code and gets its recovery codes here.
+func (u *shopUsecase) LoginMFA(ctx context.Context, req entity.MFALoginRequest, ip string) (entity.ShopLogin, error) {
+	log.Trace("Entering function LoginMFA()")
+	defer log.Trace("Exiting function LoginMFA()")
+
+	shop, err := u.parseMFAToken(ctx, req.MFAToken)
+	if err != nil {
+		if err.Error() == "[ShopUsecase.parseMFAToken]: invalid mfa token" {
+			return entity.ShopLogin{}, errors.New("[ShopUsecase.LoginMFA]: invalid mfa token")
+		}
+		return entity.ShopLogin{}, errors.Wrap(err, "[ShopUsecase.LoginMFA]: failed to check mfa token")
+	}
+
+	shopKey := entity.ShopLoginKey(shop.Name)
+	keys := []string{shopKey, entity.IPLoginKey(ip)}
+	login := entity.ShopLogin{MFAEnrolled: true}
+
+	if shop.TOTPEnabledAt == nil {
+		if shop.TOTPSecret == "" {
+			return entity.ShopLogin{}, errors.New("[ShopUsecase.LoginMFA]: mfa enrolment required")
+		}
+
+		wait, err := u.guard.Check(ctx, keys...)
+		if err != nil {
+			return entity.ShopLogin{}, errors.Wrap(err, "[ShopUsecase.LoginMFA]: failed to check login attempts")
+		}
+		if wait > 0 {
+			return entity.ShopLogin{}, errors.Wrap(&entity.LoginLockedError{RetryAfter: wait}, "[ShopUsecase.LoginMFA]")
+		}
+
+		codes, err := u.confirm(ctx, shop, req.Code)
+		if err != nil {
+			if err.Error() == "[ShopUsecase.confirm]: invalid code" {
+				u.loginFailed(ctx, keys)
+				return entity.ShopLogin{}, errors.New("[ShopUsecase.LoginMFA]: invalid code")
+			}
+			return entity.ShopLogin{}, errors.Wrap(err, "[ShopUsecase.LoginMFA]: failed to confirm enrolment")
+		}
+		login.RecoveryCodes = codes
+	} else if err := u.verifyCode(ctx, shop, req.Code, true, keys); err != nil {
+		if err.Error() == "[ShopUsecase.verifyCode]: invalid code" {
+			return entity.ShopLogin{}, errors.New("[ShopUsecase.LoginMFA]: invalid code")
+		}
+		return entity.ShopLogin{}, errors.Wrap(err, "[ShopUsecase.LoginMFA]")
+	}
+
+	u.loginSucceeded(ctx, shopKey)
+
+	login.Token, err = generateShopToken(shop)
+	if err != nil {
+		return entity.ShopLogin{}, errors.Wrap(err, "[ShopUsecase.LoginMFA]: failed to generate shop jwt")
+	}
+	return login, nil
+}
+
+// EnrolMFALogin lets a shop that has to use two-factor authentication but
+// has not enrolled yet do so in the middle of its login
+func (u *shopUsecase) EnrolMFALogin(ctx context.Context, mfaToken string) (entity.MFAEnrolment, error) {
+	shop, err := u.parseMFAToken(ctx, mfaToken)
+	if err != nil {
+		if err.Error() == "[ShopUsecase.parseMFAToken]: invalid mfa token" {
+			return entity.MFAEnrolment{}, errors.New("[ShopUsecase.EnrolMFALogin]: invalid mfa token")
+		}
+		return entity.MFAEnrolment{}, errors.Wrap(err, "[ShopUsecase.EnrolMFALogin]: failed to check mfa token")
+	}
+
+	enrolment, err := u.enrol(ctx, shop)
+	if err != nil {
+		if err.Error() == "[ShopUsecase.enrol]: mfa already enabled" {
+			return entity.MFAEnrolment{}, errors.New("[ShopUsecase.EnrolMFALogin]: mfa already enabled")
+		}
+		return entity.MFAEnrolment{}, errors.Wrap(err, "[ShopUsecase.EnrolMFALogin]: failed to enrol")
+	}
+	return enrolment, nil
+}
+
+func (u *shopUsecase) GetMFAStatus(ctx context.Context, shopID uint32) (entity.MFAStatus, error) {
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return entity.MFAStatus{}, errors.Wrap(err, "[ShopUsecase.GetMFAStatus]: failed to get shop")
+	}
+	required, err := u.mfaRequired(ctx)
+	if err != nil {
+		return entity.MFAStatus{}, errors.Wrap(err, "[ShopUsecase.GetMFAStatus]: failed to get mfa policy")
+	}
+
+	status := entity.MFAStatus{
+		Enabled:   shop.TOTPEnabledAt != nil,
+		EnabledAt: shop.TOTPEnabledAt,
+		Required:  required,
+	}
+	if status.Enabled {
+		status.RecoveryCodesLeft, err = u.shopRepo.CountShopRecoveryCodes(ctx, shopID)
+		if err != nil {
+			return entity.MFAStatus{}, errors.Wrap(err, "[ShopUsecase.GetMFAStatus]: failed to count recovery codes")
+		}
+	}
+	return status, nil
+}
+
+func (u *shopUsecase) EnrolMFA(ctx context.Context, shopID uint32) (entity.MFAEnrolment, error) {
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return entity.MFAEnrolment{}, errors.Wrap(err, "[ShopUsecase.EnrolMFA]: failed to get shop")
+	}
+
+	enrolment, err := u.enrol(ctx, shop)
+	if err != nil {
+		if err.Error() == "[ShopUsecase.enrol]: mfa already enabled" {
+			return entity.MFAEnrolment{}, errors.New("[ShopUsecase.EnrolMFA]: mfa already enabled")
+		}
+		return entity.MFAEnrolment{}, errors.Wrap(err, "[ShopUsecase.EnrolMFA]: failed to enrol")
+	}
+	return enrolment, nil
+}
+
+func (u *shopUsecase) ConfirmMFA(ctx context.Context, shopID uint32, code string) ([]string, error) {
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return nil, errors.Wrap(err, "[ShopUsecase.ConfirmMFA]: failed to get shop")
+	}
+	if shop.TOTPEnabledAt != nil {
+		return nil, errors.New("[ShopUsecase.ConfirmMFA]: mfa already enabled")
+	}
+	if shop.TOTPSecret == "" {
+		return nil, errors.New("[ShopUsecase.ConfirmMFA]: mfa not enrolled")
+	}
+
+	codes, err := u.confirm(ctx, shop, code)
+	if err != nil {
+		switch err.Error() {
+		case "[ShopUsecase.confirm]: invalid code":
+			return nil, errors.New("[ShopUsecase.ConfirmMFA]: invalid code")
+		case "[ShopUsecase.confirm]: mfa already enabled":
+			return nil, errors.New("[ShopUsecase.ConfirmMFA]: mfa already enabled")
+		}
+		return nil, errors.Wrap(err, "[ShopUsecase.ConfirmMFA]: failed to confirm enrolment")
+	}
+	return codes, nil
+}
+
+// DisableMFA takes an authenticator or recovery code, so a shop that lost
+// its authenticator can turn it off and enrol a new one. It is refused while
+// admins require two-factor authentication.
+func (u *shopUsecase) DisableMFA(ctx context.Context, shopID uint32, code string) error {
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return errors.Wrap(err, "[ShopUsecase.DisableMFA]: failed to get shop")
+	}
+	if shop.TOTPEnabledAt == nil {
+		return errors.New("[ShopUsecase.DisableMFA]: mfa not enabled")
+	}
+	required, err := u.mfaRequired(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[ShopUsecase.DisableMFA]: failed to get mfa policy")
+	}
+	if required {
+		return errors.New("[ShopUsecase.DisableMFA]: mfa required")
+	}
+
+	if err := u.verifyCode(ctx, shop, code, true, []string{entity.ShopLoginKey(shop.Name)}); err != nil {
+		if err.Error() == "[ShopUsecase.verifyCode]: invalid code" {
+			return errors.New("[ShopUsecase.DisableMFA]: invalid code")
+		}
+		return errors.Wrap(err, "[ShopUsecase.DisableMFA]")
+	}
+
+	if err := u.shopRepo.DisableShopTOTP(ctx, shopID); err != nil {
+		return errors.Wrap(err, "[ShopUsecase.DisableMFA]: failed to disable mfa")
+	}
+
+	log.WithFields(log.Fields{
+		"shopID": shopID,
+	}).Info("Shop disabled two-factor authentication")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. It takes an
+// authenticator code only, a recovery code would replace itself.
+func (u *shopUsecase) RegenerateRecoveryCodes(ctx context.Context, shopID uint32, code string) ([]string, error) {
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		return nil, errors.Wrap(err, "[ShopUsecase.RegenerateRecoveryCodes]: failed to get shop")
+	}
+	if shop.TOTPEnabledAt == nil {
+		return nil, errors.New("[ShopUsecase.RegenerateRecoveryCodes]: mfa not enabled")
+	}
+
+	if err := u.verifyCode(ctx, shop, code, false, []string{entity.ShopLoginKey(shop.Name)}); err != nil {
+		if err.Error() == "[ShopUsecase.verifyCode]: invalid code" {
+			return nil, errors.New("[ShopUsecase.RegenerateRecoveryCodes]: invalid code")
+		}
+		return nil, errors.Wrap(err, "[ShopUsecase.RegenerateRecoveryCodes]")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "[ShopUsecase.RegenerateRecoveryCodes]: failed to generate recovery codes")
+	}
+	if err := u.shopRepo.ReplaceShopRecoveryCodes(ctx, shopID, hashes); err != nil {
+		return nil, errors.Wrap(err, "[ShopUsecase.RegenerateRecoveryCodes]: failed to store recovery codes")
+	}
+	return codes, nil
+}
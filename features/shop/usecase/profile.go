@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"order-management/entity"
+	productUsecase "order-management/features/product/usecase"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// generateShopToken issues a token for the shop's own credentials, which act
+// as OWNER.
+func generateShopToken(shop entity.Shop) (string, error) {
+	return utils.GenerateJWT(map[string]interface{}{
+		"id":          shop.ID,
+		"name":        shop.Name,
+		"description": shop.Description,
+		"ver":         shop.TokenVersion,
+		"role":        entity.OWNER,
+	}, entity.ShopTokenAudience)
+}
+
+// GetShopProfile looks the shop up by the id of the token rather than its
+// name, which may have changed since the token was issued.
+func (u *shopUsecase) GetShopProfile(ctx context.Context, shopID uint32) (entity.ShopWithProducts, error) {
+	log.Trace("Entering function GetShopProfile()")
+	defer log.Trace("Exiting function GetShopProfile()")
+
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByID]: shop not found" {
+			err = errors.New("[ShopUsecase.GetShopProfile]: shop not found")
+			return entity.ShopWithProducts{}, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.GetShopProfile]: failed to get shop by id")
+		return entity.ShopWithProducts{}, err
+	}
+
+	products, err := u.productRepo.GetProductsByShopID(ctx, shop.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetShopProfile]: failed to get products by shop id")
+		return entity.ShopWithProducts{}, err
+	}
+
+	productsResponse := []entity.ProductWithOutShop{}
+	for _, product := range products {
+		productsResponse = append(productsResponse, entity.ProductWithOutShop{
+			ID:          product.ID,
+			Name:        product.Name,
+			Description: product.Description,
+			Price:       product.Price,
+		})
+	}
+	if err := productUsecase.AttachImages(ctx, u.productRepo, u.store, productsResponse); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetShopProfile]: failed to attach images")
+		return entity.ShopWithProducts{}, err
+	}
+
+	return entity.ShopWithProducts{
+		ID:          shop.ID,
+		Name:        shop.Name,
+		Description: shop.Description,
+		Products:    productsResponse,
+	}, nil
+}
+
+// VerifyToken rejects tokens of shops that no longer exist, shop credential
+// tokens issued before the last password change, tokens of members that
+// were removed and API keys of deactivated shops, whose logins can still
+// reactivate them. It returns the current role, so role changes apply
+// without a new login.
+func (u *shopUsecase) VerifyToken(ctx context.Context, claims *entity.ShopJWT) (entity.MemberRole, error) {
+	// API keys were looked up in the database while authenticating already
+	if claims.APIKey != nil {
+		if claims.APIKey.Shop.DeactivatedAt != nil {
+			err := errors.New("[ShopUsecase.VerifyToken]: shop deactivated")
+			return "", err
+		}
+		return claims.Role, nil
+	}
+
+	shop, err := u.shopRepo.GetShopByID(ctx, claims.ID)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByID]: shop not found" {
+			err = errors.New("[ShopUsecase.VerifyToken]: token revoked")
+			return "", err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.VerifyToken]: failed to get shop by id")
+		return "", err
+	}
+
+	if claims.UserID == 0 {
+		if shop.TokenVersion != claims.Version {
+			err = errors.New("[ShopUsecase.VerifyToken]: token revoked")
+			return "", err
+		}
+		return entity.OWNER, nil
+	}
+
+	member, err := u.shopRepo.GetMemberByUserID(ctx, claims.ID, claims.UserID)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetMemberByUserID]: member not found" {
+			err = errors.New("[ShopUsecase.VerifyToken]: token revoked")
+			return "", err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.VerifyToken]: failed to get member")
+		return "", err
+	}
+
+	return member.Role, nil
+}
+
+// UpdateProfile returns a fresh token as well when called with the shop's own
+// credentials, since the old one still carries the previous name and
+// description. Account tokens are left alone.
+func (u *shopUsecase) UpdateProfile(ctx context.Context, claims *entity.ShopJWT, req entity.ShopUpdateRequest) (entity.ShopProfile, string, error) {
+	log.Trace("Entering function UpdateProfile()")
+	defer log.Trace("Exiting function UpdateProfile()")
+
+	log.WithFields(log.Fields{
+		"shopID": claims.ID,
+		"req":    req,
+	}).Debug("Updating shop profile")
+
+	if err := u.shopRepo.UpdateShopProfile(ctx, claims.ID, req); err != nil {
+		switch err.Error() {
+		case "[ShopRepository.UpdateShopProfile]: shop not found":
+			err = errors.New("[ShopUsecase.UpdateProfile]: shop not found")
+			return entity.ShopProfile{}, "", err
+		case "[ShopRepository.UpdateShopProfile]: shop already exists":
+			err = errors.New("[ShopUsecase.UpdateProfile]: shop already exists")
+			return entity.ShopProfile{}, "", err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.UpdateProfile]: failed to update shop profile")
+		return entity.ShopProfile{}, "", err
+	}
+
+	shop, err := u.shopRepo.GetShopByID(ctx, claims.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.UpdateProfile]: failed to get shop by id")
+		return entity.ShopProfile{}, "", err
+	}
+
+	profile := entity.ShopProfile{
+		ID:            shop.ID,
+		Name:          shop.Name,
+		Slug:          shop.Slug,
+		Description:   shop.Description,
+		DeactivatedAt: shop.DeactivatedAt,
+	}
+	if claims.UserID != 0 {
+		return profile, "", nil
+	}
+
+	t, err := generateShopToken(shop)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.UpdateProfile]: failed to generate shop jwt")
+		return entity.ShopProfile{}, "", err
+	}
+
+	return profile, t, nil
+}
+
+// ChangePassword revokes every shop credential token issued so far and
+// returns a new one when the caller used one. Account tokens of members are
+// not affected.
+func (u *shopUsecase) ChangePassword(ctx context.Context, claims *entity.ShopJWT, req entity.ShopPasswordRequest) (string, error) {
+	log.Trace("Entering function ChangePassword()")
+	defer log.Trace("Exiting function ChangePassword()")
+
+	log.WithFields(log.Fields{
+		"shopID": claims.ID,
+	}).Debug("Changing shop password")
+
+	shop, err := u.shopRepo.GetShopByID(ctx, claims.ID)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByID]: shop not found" {
+			err = errors.New("[ShopUsecase.ChangePassword]: shop not found")
+			return "", err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.ChangePassword]: failed to get shop by id")
+		return "", err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(shop.Password), []byte(req.OldPassword)); err != nil {
+		err = errors.New("[ShopUsecase.ChangePassword]: invalid password")
+		return "", err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.ChangePassword]: failed to hash password")
+		return "", err
+	}
+
+	version, err := u.shopRepo.UpdateShopPassword(ctx, claims.ID, string(hashedPassword))
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.ChangePassword]: failed to update shop password")
+		return "", err
+	}
+	shop.TokenVersion = version
+	if claims.UserID != 0 {
+		return "", nil
+	}
+
+	t, err := generateShopToken(shop)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.ChangePassword]: failed to generate shop jwt")
+		return "", err
+	}
+
+	return t, nil
+}
+
+// Deactivate hides the shop and its products from public listings and stops
+// them from being ordered. The shop can still log in to reactivate.
+func (u *shopUsecase) Deactivate(ctx context.Context, shopID uint32, password string) error {
+	log.Trace("Entering function Deactivate()")
+	defer log.Trace("Exiting function Deactivate()")
+
+	shop, err := u.shopRepo.GetShopByID(ctx, shopID)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByID]: shop not found" {
+			err = errors.New("[ShopUsecase.Deactivate]: shop not found")
+			return err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.Deactivate]: failed to get shop by id")
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(shop.Password), []byte(password)); err != nil {
+		err = errors.New("[ShopUsecase.Deactivate]: invalid password")
+		return err
+	}
+
+	if shop.DeactivatedAt != nil {
+		err = errors.New("[ShopUsecase.Deactivate]: shop already deactivated")
+		return err
+	}
+
+	now := time.Now()
+	if err := u.shopRepo.SetShopDeactivated(ctx, shopID, &now); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.Deactivate]: failed to deactivate shop")
+		return err
+	}
+
+	return nil
+}
+
+func (u *shopUsecase) Reactivate(ctx context.Context, shopID uint32) error {
+	log.Trace("Entering function Reactivate()")
+	defer log.Trace("Exiting function Reactivate()")
+
+	if err := u.shopRepo.SetShopDeactivated(ctx, shopID, nil); err != nil {
+		if err.Error() == "[ShopRepository.SetShopDeactivated]: shop not found" {
+			err = errors.New("[ShopUsecase.Reactivate]: shop not found")
+			return err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.Reactivate]: failed to reactivate shop")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"order-management/entity"
+	productUsecase "order-management/features/product/usecase"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// uniqueSlug derives the storefront slug from the shop name. Two shops whose
+// names only differ in punctuation or case get -2, -3, ... appended.
+func (u *shopUsecase) uniqueSlug(ctx context.Context, name string) (string, error) {
+	base := utils.Slugify(name)
+	if base == "" {
+		base = "shop"
+	}
+
+	slugs, err := u.shopRepo.GetSlugsLike(ctx, base)
+	if err != nil {
+		return "", err
+	}
+	taken := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		taken[slug] = true
+	}
+
+	return utils.UniqueSlug(base, func(slug string) bool {
+		return taken[slug] || entity.ReservedShopSlugs[slug]
+	}), nil
+}
+
+type storefrontCursor struct {
+	ID uint32 `json:"i"`
+}
+
+func (u *shopUsecase) GetStorefront(ctx context.Context, slug string, limit int, cursor string) (entity.ShopStorefront, error) {
+	log.Trace("Entering function GetStorefront()")
+	defer log.Trace("Exiting function GetStorefront()")
+
+	log.WithFields(log.Fields{
+		"slug":   slug,
+		"limit":  limit,
+		"cursor": cursor,
+	}).Debug("Getting storefront")
+
+	var after storefrontCursor
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || json.Unmarshal(b, &after) != nil {
+			err = errors.New("[ShopUsecase.GetStorefront]: invalid cursor")
+			return entity.ShopStorefront{}, err
+		}
+	}
+
+	shop, err := u.shopRepo.GetShopBySlug(ctx, slug)
+	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopBySlug]: shop not found" {
+			err = errors.New("[ShopUsecase.GetStorefront]: shop not found")
+			return entity.ShopStorefront{}, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.GetStorefront]: failed to get shop by slug")
+		return entity.ShopStorefront{}, err
+	}
+	if shop.DeactivatedAt != nil {
+		err = errors.New("[ShopUsecase.GetStorefront]: shop not found")
+		return entity.ShopStorefront{}, err
+	}
+
+	stats, err := u.shopRepo.GetShopStats(ctx, shop.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetStorefront]: failed to get shop stats")
+		return entity.ShopStorefront{}, err
+	}
+
+	products, err := u.productRepo.GetProductPageByShopID(ctx, shop.ID, limit, after.ID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetStorefront]: failed to get products")
+		return entity.ShopStorefront{}, err
+	}
+
+	storefront := entity.ShopStorefront{
+		ID:              shop.ID,
+		Name:            shop.Name,
+		Slug:            shop.Slug,
+		Description:     shop.Description,
+		ProductCount:    stats.ProductCount,
+		CompletedOrders: stats.CompletedOrders,
+		Rating:          stats.Rating,
+	}
+
+	// The repository fetched one product past the limit
+	if len(products) > limit {
+		products = products[:limit]
+		b, _ := json.Marshal(storefrontCursor{ID: products[len(products)-1].ID})
+		storefront.NextCursor = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if err := productUsecase.AttachImages(ctx, u.productRepo, u.store, products); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetStorefront]: failed to attach images")
+		return entity.ShopStorefront{}, err
+	}
+	if products == nil {
+		products = []entity.ProductWithOutShop{}
+	}
+	storefront.Products = products
+
+	return storefront, nil
+}
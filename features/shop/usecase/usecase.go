@@ -1,29 +1,42 @@
 package usecase
 
 import (
+	"context"
+	"sort"
+
 	"order-management/domain"
 	"order-management/entity"
-	"order-management/utils"
+	productUsecase "order-management/features/product/usecase"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type shopUsecase struct {
 	shopRepo    domain.ShopRepository
 	productRepo domain.ProductRepository
+	store       domain.BlobStore
+	guard       domain.LoginGuard
+	mfa         domain.MFAPolicy
+	tx          domain.Transactor
+	events      domain.EventPublisher
 }
 
-func NewShopUsecase(repo domain.ShopRepository, productRepo domain.ProductRepository) domain.ShopUsecase {
+func NewShopUsecase(repo domain.ShopRepository, productRepo domain.ProductRepository, store domain.BlobStore, guard domain.LoginGuard, mfa domain.MFAPolicy, tx domain.Transactor, events domain.EventPublisher) domain.ShopUsecase {
 	return &shopUsecase{
 		shopRepo:    repo,
 		productRepo: productRepo,
+		store:       store,
+		guard:       guard,
+		mfa:         mfa,
+		tx:          tx,
+		events:      events,
 	}
 }
 
-func (u *shopUsecase) CreateProduct(product entity.Product, shopID uint32) error {
+func (u *shopUsecase) CreateProduct(ctx context.Context, product entity.Product, shopID uint32) error {
 	log.Trace("Entering function CreateProduct()")
 	defer log.Trace("Exiting function CreateProduct()")
 
@@ -32,12 +45,20 @@ func (u *shopUsecase) CreateProduct(product entity.Product, shopID uint32) error
 		"shopID":  shopID,
 	}).Debug("Creating product")
 
-	if err := u.productRepo.CreateProduct(product, shopID); err != nil {
+	if err := u.productRepo.CreateProduct(ctx, product, shopID); err != nil {
+		if err.Error() == "[ProductRepository.CreateProduct]: category not found" {
+			err = errors.New("[ShopUsecase.CreateProduct]: category not found")
+			return err
+		}
+		if err.Error() == "[ProductRepository.CreateProduct]: sku already exists" {
+			err = errors.New("[ShopUsecase.CreateProduct]: sku already exists")
+			return err
+		}
 		err = errors.Wrap(err, "[ShopUsecase.CreateProduct]: failed to create product")
 		return err
 	}
 
-	// if err := u.repo.CreateProduct(product, shopID); err != nil {
+	// if err := u.repo.CreateProduct(ctx, product, shopID); err != nil {
 	// 	err = errors.Wrap(err, "[ShopUsecase.CreateProduct]: failed to create product")
 	// 	return err
 	// }
@@ -45,12 +66,12 @@ func (u *shopUsecase) CreateProduct(product entity.Product, shopID uint32) error
 	return nil
 }
 
-func (u *shopUsecase) CreateShop(shop entity.Shop) error {
+func (u *shopUsecase) CreateShop(ctx context.Context, shop entity.Shop) error {
 	log.Trace("Entering function CreateShop()")
 	defer log.Trace("Exiting function CreateShop()")
 
 	log.WithFields(log.Fields{
-		"shop": shop,
+		"name": shop.Name,
 	}).Debug("Creating shop")
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(shop.Password), bcrypt.DefaultCost)
@@ -61,7 +82,24 @@ func (u *shopUsecase) CreateShop(shop entity.Shop) error {
 
 	shop.Password = string(hashedPassword)
 
-	if err := u.shopRepo.CreateShop(shop); err != nil {
+	slug, err := u.uniqueSlug(ctx, shop.Name)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.CreateShop]: failed to generate slug")
+		return err
+	}
+	shop.Slug = slug
+
+	err = u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := u.shopRepo.CreateShop(ctx, &shop); err != nil {
+			return err
+		}
+		return u.events.Publish(ctx, entity.EventShopRegistered, shop.ID, entity.ShopRegisteredEvent{
+			ShopID: shop.ID,
+			Name:   shop.Name,
+			Slug:   shop.Slug,
+		})
+	})
+	if err != nil {
 		if err.Error() == "[ShopRepository.CreateShop]: shop already exists" {
 			err = errors.New("[ShopUsecase.CreateShop]: shop already exists")
 			return err
@@ -73,11 +111,11 @@ func (u *shopUsecase) CreateShop(shop entity.Shop) error {
 }
 
 // Too big O(n^2)
-func (u *shopUsecase) GetAllShopsWithProducts() ([]entity.ShopWithProducts, error) {
+func (u *shopUsecase) GetAllShopsWithProducts(ctx context.Context) ([]entity.ShopWithProducts, error) {
 	log.Trace("Entering function GetAllShopsWithProducts()")
 	defer log.Trace("Exiting function GetAllShopsWithProducts()")
 
-	shops, err := u.shopRepo.GetAllShops()
+	shops, err := u.shopRepo.GetAllShops(ctx)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.GetAllShopsWithProducts]: failed to get all shops")
 		return nil, err
@@ -90,7 +128,7 @@ func (u *shopUsecase) GetAllShopsWithProducts() ([]entity.ShopWithProducts, erro
 
 	shopsResponse := []entity.ShopWithProducts{}
 	for _, shop := range shops {
-		products, err := u.productRepo.GetProductsByShopID(shop.ID)
+		products, err := u.productRepo.GetProductsByShopID(ctx, shop.ID)
 		if err != nil {
 			err = errors.Wrap(err, "[ShopUsecase.GetAllShopsWithProducts]: failed to get products by shop id")
 			return nil, err
@@ -104,6 +142,10 @@ func (u *shopUsecase) GetAllShopsWithProducts() ([]entity.ShopWithProducts, erro
 				Price:       product.Price,
 			})
 		}
+		if err := productUsecase.AttachImages(ctx, u.productRepo, u.store, productsResponse); err != nil {
+			err = errors.Wrap(err, "[ShopUsecase.GetAllShopsWithProducts]: failed to attach images")
+			return nil, err
+		}
 		shopResponse := entity.ShopWithProducts{
 			ID:          shop.ID,
 			Name:        shop.Name,
@@ -116,11 +158,11 @@ func (u *shopUsecase) GetAllShopsWithProducts() ([]entity.ShopWithProducts, erro
 	return shopsResponse, nil
 }
 
-func (u *shopUsecase) GetAllShops() ([]entity.Shop, error) {
+func (u *shopUsecase) GetAllShops(ctx context.Context) ([]entity.Shop, error) {
 	log.Trace("Entering function GetAllShops()")
 	defer log.Trace("Exiting function GetAllShops()")
 
-	shops, err := u.shopRepo.GetAllShops()
+	shops, err := u.shopRepo.GetAllShops(ctx)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.GetAllShops]: failed to get all shops")
 		return nil, err
@@ -131,6 +173,7 @@ func (u *shopUsecase) GetAllShops() ([]entity.Shop, error) {
 		shopsResponse = append(shopsResponse, entity.Shop{
 			ID:          shop.ID,
 			Name:        shop.Name,
+			Slug:        shop.Slug,
 			Description: shop.Description,
 		})
 	}
@@ -138,7 +181,7 @@ func (u *shopUsecase) GetAllShops() ([]entity.Shop, error) {
 	return shopsResponse, nil
 }
 
-func (u *shopUsecase) GetShopByName(name string) (entity.ShopWithProducts, error) {
+func (u *shopUsecase) GetShopByName(ctx context.Context, name string) (entity.ShopWithProducts, error) {
 	log.Trace("Entering function GetShopByName()")
 	defer log.Trace("Exiting function GetShopByName()")
 
@@ -146,7 +189,7 @@ func (u *shopUsecase) GetShopByName(name string) (entity.ShopWithProducts, error
 		"name": name,
 	}).Debug("Getting shop by name")
 
-	shop, err := u.shopRepo.GetShopByName(name)
+	shop, err := u.shopRepo.GetShopByName(ctx, name)
 	if err != nil {
 		if err.Error() == "[ShopRepository.GetShopByName]: shop not found" {
 			err = errors.New("[ShopUsecase.GetShopByName]: shop not found")
@@ -157,7 +200,7 @@ func (u *shopUsecase) GetShopByName(name string) (entity.ShopWithProducts, error
 		return entity.ShopWithProducts{}, err
 	}
 
-	products, err := u.productRepo.GetProductsByShopID(shop.ID)
+	products, err := u.productRepo.GetProductsByShopID(ctx, shop.ID)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.GetShopByName]: failed to get products by shop id")
 		return entity.ShopWithProducts{}, err
@@ -172,6 +215,10 @@ func (u *shopUsecase) GetShopByName(name string) (entity.ShopWithProducts, error
 			Price:       product.Price,
 		})
 	}
+	if err := productUsecase.AttachImages(ctx, u.productRepo, u.store, productsResponse); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetShopByName]: failed to attach images")
+		return entity.ShopWithProducts{}, err
+	}
 
 	shopResponse := entity.ShopWithProducts{
 		ID:          shop.ID,
@@ -183,45 +230,94 @@ func (u *shopUsecase) GetShopByName(name string) (entity.ShopWithProducts, error
 	return shopResponse, nil
 }
 
-func (u *shopUsecase) Login(name string, password string) (string, error) {
+// Login returns an MFA token instead of the shop token for shops with
+// two-factor authentication, and for all shops while admins require it, which
+// LoginMFA exchanges for the shop token.
+func (u *shopUsecase) Login(ctx context.Context, name string, password string, ip string) (entity.ShopLogin, error) {
 	log.Trace("Entering function Login()")
 	defer log.Trace("Exiting function Login()")
 
 	log.WithFields(log.Fields{
 		"name": name,
+		"ip":   ip,
 	}).Debug("Logging in")
 
-	credentials, err := u.shopRepo.GetShopByNameWithPassword(name)
+	shopKey := entity.ShopLoginKey(name)
+	keys := []string{shopKey, entity.IPLoginKey(ip)}
+
+	wait, err := u.guard.Check(ctx, keys...)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.Login]: failed to check login attempts")
+		return entity.ShopLogin{}, err
+	}
+	if wait > 0 {
+		err = errors.Wrap(&entity.LoginLockedError{RetryAfter: wait}, "[ShopUsecase.Login]")
+		return entity.ShopLogin{}, err
+	}
+
+	credentials, err := u.shopRepo.GetShopByNameWithPassword(ctx, name)
 	if err != nil {
 		if err.Error() == "[ShopRepository.GetShopByNameWithPassword]: shop not found" {
+			u.loginFailed(ctx, keys)
 			err = errors.New("[ShopUsecase.Login]: shop not found")
-			return "", err
+			return entity.ShopLogin{}, err
 		}
 
 		err = errors.Wrap(err, "[ShopUsecase.Login]: failed to get shop by name with password")
-		return "", err
+		return entity.ShopLogin{}, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(credentials.Password), []byte(password)); err != nil {
+		u.loginFailed(ctx, keys)
 		err = errors.New("[ShopUsecase.Login]: invalid password")
-		return "", err
+		return entity.ShopLogin{}, err
+	}
+
+	// The failures are only forgotten once the second factor checked out as
+	// well, or every correct password would buy more code guesses
+	required, err := u.mfaRequired(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.Login]: failed to get mfa policy")
+		return entity.ShopLogin{}, err
+	}
+	if required || credentials.TOTPEnabledAt != nil {
+		login, err := startMFALogin(credentials)
+		if err != nil {
+			err = errors.Wrap(err, "[ShopUsecase.Login]: failed to generate mfa jwt")
+			return entity.ShopLogin{}, err
+		}
+		return login, nil
 	}
 
-	t, err := utils.GenerateJWT(map[string]interface{}{
-		"id":          credentials.ID,
-		"name":        credentials.Name,
-		"description": credentials.Description,
-	}, []byte(viper.GetString("jwt.shopsecret")))
+	u.loginSucceeded(ctx, shopKey)
 
+	t, err := generateShopToken(credentials)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.Login]: failed to generate shop jwt")
-		return "", err
+		return entity.ShopLogin{}, err
 	}
 
-	return t, nil
+	return entity.ShopLogin{Token: t}, nil
 }
 
-func (u *shopUsecase) GetProductsByShopID(id uint32) ([]entity.Product, error) {
+// loginFailed counts a failed shop password check. Shops have no email
+// address, so a locked shop waits out the lockout or is unlocked by an admin.
+// Failing to count must not hide the credential error, so it is only logged.
+func (u *shopUsecase) loginFailed(ctx context.Context, keys []string) {
+	if _, err := u.guard.Failed(ctx, keys...); err != nil {
+		log.WithError(err).Error("Failed to record failed login")
+	}
+}
+
+func (u *shopUsecase) loginSucceeded(ctx context.Context, shopKey string) {
+	if err := u.guard.Succeeded(ctx, shopKey); err != nil {
+		log.WithFields(log.Fields{
+			"key": shopKey,
+		}).WithError(err).Warn("Failed to reset login attempts")
+	}
+}
+
+func (u *shopUsecase) GetProductsByShopID(ctx context.Context, id uint32) ([]entity.Product, error) {
 	log.Trace("Entering function GetProductsByShopID()")
 	defer log.Trace("Exiting function GetProductsByShopID()")
 
@@ -229,19 +325,23 @@ func (u *shopUsecase) GetProductsByShopID(id uint32) ([]entity.Product, error) {
 		"id": id,
 	}).Debug("Getting products by shop id")
 
-	// First check if shop exists
-	exists, err := u.shopRepo.ShopExists(id)
+	// First check if shop exists, deactivated shops are hidden from the public
+	shop, err := u.shopRepo.GetShopByID(ctx, id)
 	if err != nil {
+		if err.Error() == "[ShopRepository.GetShopByID]: shop not found" {
+			err = errors.New("[ShopUsecase.GetProductsByShopID]: shop not found")
+			return nil, err
+		}
 		err = errors.Wrap(err, "[ShopUsecase.GetProductsByShopID]: failed to check shop existence")
 		return nil, err
 	}
-	if !exists {
+	if shop.DeactivatedAt != nil {
 		err = errors.New("[ShopUsecase.GetProductsByShopID]: shop not found")
 		return nil, err
 	}
 
 	// If shop exists, get its products
-	products, err := u.productRepo.GetProductsByShopID(id)
+	products, err := u.productRepo.GetProductsByShopID(ctx, id)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.GetProductsByShopID]: failed to get products by shop id")
 		return nil, err
@@ -261,7 +361,7 @@ func (u *shopUsecase) GetProductsByShopID(id uint32) ([]entity.Product, error) {
 	return productsResponse, nil
 }
 
-func (u *shopUsecase) UpdateProduct(req *entity.ProductManagementRequest, product *entity.Product) error {
+func (u *shopUsecase) UpdateProduct(ctx context.Context, req *entity.ProductManagementRequest, product *entity.Product) error {
 	log.Trace("Entering function UpdateProduct()")
 	defer log.Trace("Exiting function UpdateProduct()")
 
@@ -270,7 +370,7 @@ func (u *shopUsecase) UpdateProduct(req *entity.ProductManagementRequest, produc
 		"product": product,
 	}).Debug("Updating product")
 
-	exists, err := u.shopRepo.ShopExists(req.ShopID)
+	exists, err := u.shopRepo.ShopExists(ctx, req.ShopID)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.UpdateProduct]: failed to check shop existence")
 		return err
@@ -281,14 +381,51 @@ func (u *shopUsecase) UpdateProduct(req *entity.ProductManagementRequest, produc
 	}
 
 	product.ID = req.ProductID
-	if err := u.productRepo.UpdateProduct(req, product); err != nil {
+	err = u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		before, err := u.productRepo.GetProductPrices(ctx, req.ShopID, []uint32{req.ProductID})
+		if err != nil {
+			return err
+		}
+		if err := u.productRepo.UpdateProduct(ctx, req, product); err != nil {
+			return err
+		}
+
+		// Nothing was updated when the product is not the shop's
+		oldPrice, ok := before[req.ProductID]
+		if !ok {
+			return nil
+		}
+		if err := u.productUpdated(ctx, req.ShopID, req.ProductID); err != nil {
+			return err
+		}
+
+		// A zero price is left as it is by the update
+		if product.Price == 0 || product.Price == oldPrice {
+			return nil
+		}
+		return u.events.Publish(ctx, entity.EventProductPriceChanged, req.ProductID, entity.ProductPriceChangedEvent{
+			ProductID: req.ProductID,
+			ShopID:    req.ShopID,
+			OldPrice:  oldPrice,
+			NewPrice:  product.Price,
+		})
+	})
+	if err != nil {
+		if err.Error() == "[ProductRepository.UpdateProduct]: category not found" {
+			err = errors.New("[ShopUsecase.UpdateProduct]: category not found")
+			return err
+		}
+		if err.Error() == "[ProductRepository.UpdateProduct]: sku already exists" {
+			err = errors.New("[ShopUsecase.UpdateProduct]: sku already exists")
+			return err
+		}
 		err = errors.Wrap(err, "[ShopUsecase.UpdateProduct]: failed to update product")
 		return err
 	}
 	return nil
 }
 
-func (u *shopUsecase) DeleteProduct(req *entity.ProductManagementRequest) error {
+func (u *shopUsecase) DeleteProduct(ctx context.Context, req *entity.ProductManagementRequest) error {
 	log.Trace("Entering function DeleteProduct()")
 	defer log.Trace("Exiting function DeleteProduct()")
 
@@ -296,7 +433,7 @@ func (u *shopUsecase) DeleteProduct(req *entity.ProductManagementRequest) error
 		"req": req,
 	}).Debug("Deleting product")
 
-	exists, err := u.shopRepo.ShopExists(req.ShopID)
+	exists, err := u.shopRepo.ShopExists(ctx, req.ShopID)
 	if err != nil {
 		err = errors.Wrap(err, "[ShopUsecase.DeleteProduct]: failed to check shop existence")
 		return err
@@ -306,9 +443,207 @@ func (u *shopUsecase) DeleteProduct(req *entity.ProductManagementRequest) error
 		return err
 	}
 
-	if err := u.productRepo.DeleteProduct(req); err != nil {
+	if err := u.productRepo.DeleteProduct(ctx, req); err != nil {
+		if err.Error() == "[ProductRepository.DeleteProduct]: product not found" {
+			err = errors.New("[ShopUsecase.DeleteProduct]: product not found")
+			return err
+		}
 		err = errors.Wrap(err, "[ShopUsecase.DeleteProduct]: failed to delete product")
 		return err
 	}
 	return nil
 }
+
+func (u *shopUsecase) RestoreProduct(ctx context.Context, req *entity.ProductManagementRequest) error {
+	log.Trace("Entering function RestoreProduct()")
+	defer log.Trace("Exiting function RestoreProduct()")
+
+	log.WithFields(log.Fields{
+		"req": req,
+	}).Debug("Restoring product")
+
+	if err := u.productRepo.RestoreProduct(ctx, req); err != nil {
+		if err.Error() == "[ProductRepository.RestoreProduct]: product not found" {
+			err = errors.New("[ShopUsecase.RestoreProduct]: product not found")
+			return err
+		}
+		if err.Error() == "[ProductRepository.RestoreProduct]: sku already exists" {
+			err = errors.New("[ShopUsecase.RestoreProduct]: sku already exists")
+			return err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.RestoreProduct]: failed to restore product")
+		return err
+	}
+	return nil
+}
+
+func (u *shopUsecase) GetDeletedProducts(ctx context.Context, shopID uint32) ([]entity.ProductWithOutShop, error) {
+	log.Trace("Entering function GetDeletedProducts()")
+	defer log.Trace("Exiting function GetDeletedProducts()")
+
+	products, err := u.productRepo.GetDeletedProductsByShopID(ctx, shopID)
+	if err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetDeletedProducts]: failed to get deleted products")
+		return nil, err
+	}
+	if err := productUsecase.AttachImages(ctx, u.productRepo, u.store, products); err != nil {
+		err = errors.Wrap(err, "[ShopUsecase.GetDeletedProducts]: failed to attach images")
+		return nil, err
+	}
+	return products, nil
+}
+
+// ownedProduct loads the product and checks it belongs to the shop. The
+// errors carry the calling method so handlers can match on them.
+func (u *shopUsecase) ownedProduct(ctx context.Context, method string, shopID uint32, productID uint32) (entity.Product, error) {
+	product, err := u.productRepo.GetProductByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Product{}, errors.New("[ShopUsecase." + method + "]: product not found")
+		}
+		return entity.Product{}, errors.Wrap(err, "[ShopUsecase."+method+"]: failed to get product by id")
+	}
+	if product.ShopID != shopID {
+		return entity.Product{}, errors.New("[ShopUsecase." + method + "]: product does not belong to shop")
+	}
+	return product, nil
+}
+
+func (u *shopUsecase) CreateVariant(ctx context.Context, req *entity.ProductManagementRequest, variant entity.VariantRequest) (entity.VariantResponse, error) {
+	log.Trace("Entering function CreateVariant()")
+	defer log.Trace("Exiting function CreateVariant()")
+
+	log.WithFields(log.Fields{
+		"req":     req,
+		"variant": variant,
+	}).Debug("Creating variant")
+
+	if _, err := u.ownedProduct(ctx, "CreateVariant", req.ShopID, req.ProductID); err != nil {
+		return entity.VariantResponse{}, err
+	}
+
+	var created entity.ProductVariant
+	err := u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = u.productRepo.CreateVariant(ctx, req.ProductID, variant)
+		if err != nil {
+			return err
+		}
+		return u.productUpdated(ctx, req.ShopID, req.ProductID)
+	})
+	if err != nil {
+		if err.Error() == "[ProductRepository.CreateVariant]: variant already exists" {
+			err = errors.New("[ShopUsecase.CreateVariant]: variant already exists")
+			return entity.VariantResponse{}, err
+		}
+		err = errors.Wrap(err, "[ShopUsecase.CreateVariant]: failed to create variant")
+		return entity.VariantResponse{}, err
+	}
+
+	return entity.VariantResponse{
+		ID:      created.ID,
+		SKU:     created.SKU,
+		Price:   created.Price,
+		Stock:   created.Stock,
+		Options: variantOptions(variant.Options),
+	}, nil
+}
+
+func (u *shopUsecase) UpdateVariant(ctx context.Context, req *entity.VariantManagementRequest, variant entity.VariantRequest) error {
+	log.Trace("Entering function UpdateVariant()")
+	defer log.Trace("Exiting function UpdateVariant()")
+
+	log.WithFields(log.Fields{
+		"req":     req,
+		"variant": variant,
+	}).Debug("Updating variant")
+
+	if _, err := u.ownedProduct(ctx, "UpdateVariant", req.ShopID, req.ProductID); err != nil {
+		return err
+	}
+
+	err := u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		variants, err := u.productRepo.GetVariantsByProductID(ctx, req.ProductID)
+		if err != nil {
+			return err
+		}
+		if err := u.productRepo.UpdateVariant(ctx, req, variant); err != nil {
+			return err
+		}
+		if err := u.productUpdated(ctx, req.ShopID, req.ProductID); err != nil {
+			return err
+		}
+
+		for _, existing := range variants {
+			if existing.ID != req.VariantID || existing.Price == variant.Price {
+				continue
+			}
+			return u.events.Publish(ctx, entity.EventProductPriceChanged, req.ProductID, entity.ProductPriceChangedEvent{
+				ProductID: req.ProductID,
+				VariantID: req.VariantID,
+				ShopID:    req.ShopID,
+				OldPrice:  existing.Price,
+				NewPrice:  variant.Price,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		switch err.Error() {
+		case "[ProductRepository.UpdateVariant]: variant not found":
+			return errors.New("[ShopUsecase.UpdateVariant]: variant not found")
+		case "[ProductRepository.UpdateVariant]: variant already exists":
+			return errors.New("[ShopUsecase.UpdateVariant]: variant already exists")
+		}
+		err = errors.Wrap(err, "[ShopUsecase.UpdateVariant]: failed to update variant")
+		return err
+	}
+	return nil
+}
+
+func (u *shopUsecase) DeleteVariant(ctx context.Context, req *entity.VariantManagementRequest) error {
+	log.Trace("Entering function DeleteVariant()")
+	defer log.Trace("Exiting function DeleteVariant()")
+
+	log.WithFields(log.Fields{
+		"req": req,
+	}).Debug("Deleting variant")
+
+	if _, err := u.ownedProduct(ctx, "DeleteVariant", req.ShopID, req.ProductID); err != nil {
+		return err
+	}
+
+	err := u.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := u.productRepo.DeleteVariant(ctx, req); err != nil {
+			return err
+		}
+		return u.productUpdated(ctx, req.ShopID, req.ProductID)
+	})
+	if err != nil {
+		if err.Error() == "[ProductRepository.DeleteVariant]: variant not found" {
+			return errors.New("[ShopUsecase.DeleteVariant]: variant not found")
+		}
+		err = errors.Wrap(err, "[ShopUsecase.DeleteVariant]: failed to delete variant")
+		return err
+	}
+	return nil
+}
+
+// productUpdated publishes product.updated, in the transaction of ctx
+func (u *shopUsecase) productUpdated(ctx context.Context, shopID uint32, productID uint32) error {
+	return u.events.Publish(ctx, entity.EventProductUpdated, productID, entity.ProductUpdatedEvent{
+		ProductID: productID,
+		ShopID:    shopID,
+	})
+}
+
+func variantOptions(options map[string]string) []entity.VariantOption {
+	variantOptions := make([]entity.VariantOption, 0, len(options))
+	for name, value := range options {
+		variantOptions = append(variantOptions, entity.VariantOption{Name: name, Value: value})
+	}
+	sort.Slice(variantOptions, func(i, j int) bool {
+		return variantOptions[i].Name < variantOptions[j].Name
+	})
+	return variantOptions
+}
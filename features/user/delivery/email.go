@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"net/http"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenError maps the errors shared by the emailed token endpoints
+func tokenError(c echo.Context, err error, handler string) error {
+	switch utils.StandardError(err) {
+	case "invalid token", "token expired", "password is required":
+		err = errors.Wrap(err, "[Handler."+handler+"]: invalid request")
+
+		log.WithError(err).Warn("Invalid emailed token request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	case "email already verified":
+		err = errors.Wrap(err, "[Handler."+handler+"]: email already verified")
+		return c.JSON(http.StatusConflict, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	err = errors.Wrap(err, "[Handler."+handler+"]: internal server error")
+
+	log.WithError(err).Error("Internal server error during emailed token request")
+
+	return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+}
+
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	req := entity.VerifyEmailRequest{}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		err = errors.New("[Handler.VerifyEmail]: token is required")
+
+		log.WithError(err).Warn("Invalid verification request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.userUsecase.VerifyEmail(c.Request().Context(), req.Token); err != nil {
+		return tokenError(c, err, "VerifyEmail")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Email verified successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) ResendVerificationEmail(c echo.Context) error {
+	userID := c.Get("user").(*entity.UserJWT).ID
+
+	if err := h.userUsecase.SendVerificationEmail(c.Request().Context(), userID); err != nil {
+		if err.Error() == "[UserUsecase.SendVerificationEmail]: user not found" {
+			err = errors.Wrap(err, "[Handler.ResendVerificationEmail]: user not found")
+			return c.JSON(http.StatusNotFound, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+		return tokenError(c, err, "ResendVerificationEmail")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Verification email sent",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	req := entity.ForgotPasswordRequest{}
+	if err := c.Bind(&req); err != nil || req.Email == "" {
+		err = errors.New("[Handler.ForgotPassword]: email is required")
+
+		log.WithError(err).Warn("Invalid password reset request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.userUsecase.ForgotPassword(c.Request().Context(), req.Email); err != nil {
+		return tokenError(c, err, "ForgotPassword")
+	}
+
+	// Same answer whether or not the address is registered
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "If the email is registered, a reset link was sent",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) ResetPassword(c echo.Context) error {
+	req := entity.ResetPasswordRequest{}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		err = errors.New("[Handler.ResetPassword]: token is required")
+
+		log.WithError(err).Warn("Invalid password reset")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.userUsecase.ResetPassword(c.Request().Context(), req.Token, req.Password); err != nil {
+		return tokenError(c, err, "ResetPassword")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Password reset successfully",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) UnlockLogin(c echo.Context) error {
+	req := entity.UnlockLoginRequest{}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		err = errors.New("[Handler.UnlockLogin]: token is required")
+
+		log.WithError(err).Warn("Invalid unlock request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.userUsecase.UnlockLogin(c.Request().Context(), req.Token); err != nil {
+		return tokenError(c, err, "UnlockLogin")
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Account unlocked successfully",
+		Status:  http.StatusOK,
+	})
+}
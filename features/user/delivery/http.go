@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"order-management/domain"
 	"order-management/entity"
+	orderDelivery "order-management/features/order/delivery"
 	"order-management/utils"
 	"strconv"
 
@@ -15,27 +16,40 @@ import (
 )
 
 type Handler struct {
-	userUsecase  domain.UserUsecase
-	orderUsecase domain.OrderUsecase
+	userUsecase         domain.UserUsecase
+	orderUsecase        domain.OrderUsecase
+	notificationUsecase domain.NotificationUsecase
 }
 
-func NewHandler(e *echo.Group, u domain.UserUsecase, o domain.OrderUsecase) *Handler {
+func NewHandler(e *echo.Group, u domain.UserUsecase, o domain.OrderUsecase, n domain.NotificationUsecase) *Handler {
 	h := Handler{
-		userUsecase:  u,
-		orderUsecase: o,
+		userUsecase:         u,
+		orderUsecase:        o,
+		notificationUsecase: n,
 	}
 
 	publicGroup := e.Group("")
 	publicGroup.POST("/register", h.CreateUser)
 	publicGroup.POST("/login", h.Login)
 	publicGroup.GET("/:id", h.GetUserByID)
+	publicGroup.POST("/verify-email", h.VerifyEmail)
+	publicGroup.POST("/password/forgot", h.ForgotPassword)
+	publicGroup.POST("/password/reset", h.ResetPassword)
+	publicGroup.POST("/unlock", h.UnlockLogin)
 
 	authGroup := e.Group("")
 	authGroup.Use(middleware.UserAuth())
 	authGroup.PUT("/:id", h.UpdateUser)
+	authGroup.POST("/logout", h.Logout)
+	authGroup.POST("/verify-email/resend", h.ResendVerificationEmail)
 	authGroup.GET("/orders", h.GetOrdersByUserID)
 	authGroup.POST("/orders", h.CreateOrder)
 	authGroup.GET("/orders/:id", h.GetOrder)
+	authGroup.GET("/notifications", h.GetNotifications)
+	authGroup.POST("/notifications/read", h.MarkAllNotificationsRead)
+	authGroup.POST("/notifications/:notification_id/read", h.MarkNotificationRead)
+	authGroup.GET("/notifications/preferences", h.GetNotificationPreferences)
+	authGroup.PUT("/notifications/preferences", h.UpdateNotificationPreferences)
 	return &h
 }
 
@@ -46,7 +60,7 @@ func (h *Handler) GetOrder(c echo.Context) error {
 		err = errors.Wrap(err, "[Handler.GetOrder]: invalid order id")
 		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
 	}
-	order, err := h.orderUsecase.GetOrder(uint32(orderIDUint))
+	order, err := h.orderUsecase.GetOrder(c.Request().Context(), uint32(orderIDUint))
 	if err != nil {
 		err = errors.Wrap(err, "[Handler.GetOrder]: failed to get order")
 		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
@@ -60,9 +74,25 @@ func (h *Handler) GetOrder(c echo.Context) error {
 }
 
 func (h *Handler) GetOrdersByUserID(c echo.Context) error {
+	filter, err := orderDelivery.ParseOrderFilter(c)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetOrdersByUserID]: invalid filter")
+
+		log.WithError(err).Warn("Invalid order filter")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
 	userID := c.Get("user").(*entity.UserJWT).ID
-	orders, err := h.orderUsecase.GetOrdersByUserID(userID)
+	orders, err := h.orderUsecase.GetOrdersByUserID(c.Request().Context(), userID, filter)
 	if err != nil {
+		if err.Error() == "[OrderUsecase.GetOrdersByUserID]: invalid cursor" {
+			err = errors.Wrap(err, "[Handler.GetOrdersByUserID]: invalid cursor")
+
+			log.WithError(err).Warn("Invalid order cursor")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+		}
 		err = errors.Wrap(err, "[Handler.GetOrdersByUserID]: failed to get orders by user id")
 		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
 	}
@@ -90,7 +120,46 @@ func (h *Handler) CreateOrder(c echo.Context) error {
 
 	userID := c.Get("user").(*entity.UserJWT).ID
 
-	if err := h.orderUsecase.CreateOrder(req, userID); err != nil {
+	if err := h.userUsecase.RequireVerifiedEmail(c.Request().Context(), userID); err != nil {
+		if err.Error() == "[UserUsecase.RequireVerifiedEmail]: email not verified" {
+			err = errors.Wrap(err, "[Handler.CreateOrder]: email not verified")
+
+			log.WithFields(log.Fields{
+				"user_id": userID,
+			}).WithError(err).Warn("Order from unverified user")
+
+			return c.JSON(http.StatusForbidden, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+		err = errors.Wrap(err, "[Handler.CreateOrder]: internal server error")
+
+		log.WithFields(log.Fields{
+			"user_id": userID,
+		}).WithError(err).Error("Internal server error during email verification check")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.orderUsecase.CreateOrder(c.Request().Context(), req, userID); err != nil {
+		switch err.Error() {
+		case "[OrderUsecase.CreateOrder]: product not found",
+			"[OrderUsecase.CreateOrder]: variant not found",
+			"[OrderUsecase.CreateOrder]: variant is required",
+			"[OrderUsecase.CreateOrder]: variant does not belong to product",
+			"[OrderUsecase.CreateOrder]: invalid amount",
+			"[OrderUsecase.CreateOrder]: duplicate order line":
+			err = errors.Wrap(err, "[Handler.CreateOrder]: invalid order line")
+
+			log.WithError(err).Warn("Invalid order line")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+		case "[OrderUsecase.CreateOrder]: insufficient stock":
+			err = errors.Wrap(err, "[Handler.CreateOrder]: insufficient stock")
+
+			log.WithError(err).Warn("Insufficient stock for order")
+
+			return c.JSON(http.StatusConflict, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+
 		err = errors.Wrap(err, "[Handler.CreateOrder]: internal server error")
 
 		log.WithFields(log.Fields{
@@ -126,8 +195,19 @@ func (h *Handler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
 	}
 	//Login
-	user, err := h.userUsecase.Login(req.Email, req.Password)
+	user, err := h.userUsecase.Login(c.Request().Context(), req.Email, req.Password, c.RealIP())
 	if err != nil {
+		if locked := (*entity.LoginLockedError)(nil); errors.As(err, &locked) {
+			err = errors.Wrap(err, "[Handler.Login]: too many failed logins")
+
+			log.WithFields(log.Fields{
+				"email": req.Email,
+				"ip":    c.RealIP(),
+			}).WithError(err).Warn("Login refused after repeated failures")
+
+			c.Response().Header().Set("Retry-After", utils.RetryAfter(locked.RetryAfter))
+			return c.JSON(http.StatusTooManyRequests, entity.ResponseError{Error: utils.StandardError(err)})
+		}
 		if err.Error() == "[UserUsecase.Login]: user not found" {
 			err = errors.Wrap(err, "[Handler.Login]: user not found")
 
@@ -155,7 +235,15 @@ func (h *Handler) Login(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
 	}
 
-	c.Response().Header().Set("Authorization", "Bearer "+user)
+	if err := middleware.IssueToken(c, middleware.UserSessionCookie, user); err != nil {
+		err = errors.Wrap(err, "[Handler.Login]: failed to issue token")
+
+		log.WithError(err).Error("Failed to issue token")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
 
 	return c.JSON(http.StatusOK, entity.Response{
 		Success: true,
@@ -164,6 +252,18 @@ func (h *Handler) Login(c echo.Context) error {
 	})
 }
 
+// Logout ends a cookie session. Bearer tokens are not stored, clients just
+// drop them.
+func (h *Handler) Logout(c echo.Context) error {
+	middleware.ClearSession(c, middleware.UserSessionCookie)
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Logout successful",
+		Status:  http.StatusOK,
+	})
+}
+
 func (h *Handler) CreateUser(c echo.Context) error {
 	req := entity.User{}
 
@@ -182,7 +282,7 @@ func (h *Handler) CreateUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
 	}
 
-	if err := h.userUsecase.CreateUser(req); err != nil {
+	if err := h.userUsecase.CreateUser(c.Request().Context(), req); err != nil {
 		if err.Error() == "[UserUsecase.CreateUser]: user already exists" {
 			err = errors.Wrap(err, "[Handler.CreateUser]: user already exists")
 
@@ -218,7 +318,7 @@ func (h *Handler) GetUserByID(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
 	}
 
-	user, err := h.userUsecase.GetUserByID(uint32(id))
+	user, err := h.userUsecase.GetUserByID(c.Request().Context(), uint32(id))
 	if err != nil {
 		err = errors.Wrap(err, "[Handler.GetUserByID]: internal server error")
 
@@ -242,7 +342,7 @@ func (h *Handler) UpdateUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
 	}
 
-	user, err := h.userUsecase.GetUserByID(uint32(id))
+	user, err := h.userUsecase.GetUserByID(c.Request().Context(), uint32(id))
 	if err != nil {
 		if err.Error() == "[UserUsecase.GetUserByID]: user not found" {
 			err = errors.Wrap(err, "[Handler.UpdateUser]: user not found")
@@ -270,7 +370,7 @@ func (h *Handler) UpdateUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
 	}
 
-	if err := h.userUsecase.UpdateUser(user); err != nil {
+	if err := h.userUsecase.UpdateUser(c.Request().Context(), user); err != nil {
 		err = errors.Wrap(err, "[Handler.UpdateUser]: internal server error")
 
 		log.WithFields(log.Fields{
@@ -0,0 +1,177 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetNotifications takes optional unread, before and limit, at most 50
+func (h *Handler) GetNotifications(c echo.Context) error {
+	userID := c.Get("user").(*entity.UserJWT).ID
+
+	filter := entity.NotificationFilter{}
+	var err error
+	if unread := c.QueryParam("unread"); unread != "" {
+		filter.Unread, err = strconv.ParseBool(unread)
+		if err != nil {
+			err = errors.Wrap(err, "[Handler.GetNotifications]: invalid unread")
+
+			log.WithError(err).Warn("Invalid notification filter")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+	}
+	if before := c.QueryParam("before"); before != "" {
+		filter.Before, err = strconv.ParseUint(before, 10, 64)
+		if err != nil {
+			err = errors.Wrap(err, "[Handler.GetNotifications]: invalid before")
+
+			log.WithError(err).Warn("Invalid notification filter")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 {
+			err := errors.New("[Handler.GetNotifications]: invalid limit")
+
+			log.WithError(err).Warn("Invalid notification filter")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+	}
+
+	feed, err := h.notificationUsecase.GetNotifications(c.Request().Context(), userID, filter)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetNotifications]: failed to get notifications")
+
+		log.WithError(err).Error("Internal server error while getting notifications")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Notifications fetched successfully",
+		Status:  http.StatusOK,
+		Data:    feed,
+	})
+}
+
+func (h *Handler) MarkNotificationRead(c echo.Context) error {
+	userID := c.Get("user").(*entity.UserJWT).ID
+
+	notificationID, err := strconv.ParseUint(c.Param("notification_id"), 10, 64)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.MarkNotificationRead]: invalid notification id")
+
+		log.WithError(err).Warn("Invalid notification ID format")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	if err := h.notificationUsecase.MarkRead(c.Request().Context(), userID, notificationID); err != nil {
+		if err.Error() == "[NotificationUsecase.MarkRead]: notification not found" {
+			err = errors.Wrap(err, "[Handler.MarkNotificationRead]: notification not found")
+			return c.JSON(http.StatusNotFound, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+		err = errors.Wrap(err, "[Handler.MarkNotificationRead]: failed to mark notification read")
+
+		log.WithError(err).Error("Internal server error while marking notification read")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Notification marked as read",
+		Status:  http.StatusOK,
+	})
+}
+
+func (h *Handler) MarkAllNotificationsRead(c echo.Context) error {
+	userID := c.Get("user").(*entity.UserJWT).ID
+
+	marked, err := h.notificationUsecase.MarkAllRead(c.Request().Context(), userID)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.MarkAllNotificationsRead]: failed to mark notifications read")
+
+		log.WithError(err).Error("Internal server error while marking notifications read")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Notifications marked as read",
+		Status:  http.StatusOK,
+		Data:    map[string]int64{"marked": marked},
+	})
+}
+
+func (h *Handler) GetNotificationPreferences(c echo.Context) error {
+	userID := c.Get("user").(*entity.UserJWT).ID
+
+	preferences, err := h.notificationUsecase.GetPreferences(c.Request().Context(), userID)
+	if err != nil {
+		err = errors.Wrap(err, "[Handler.GetNotificationPreferences]: failed to get preferences")
+
+		log.WithError(err).Error("Internal server error while getting notification preferences")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Notification preferences fetched successfully",
+		Status:  http.StatusOK,
+		Data:    preferences,
+	})
+}
+
+func (h *Handler) UpdateNotificationPreferences(c echo.Context) error {
+	userID := c.Get("user").(*entity.UserJWT).ID
+
+	req := entity.NotificationPreferencesRequest{}
+	if err := c.Bind(&req); err != nil {
+		err = errors.Wrap(err, "[Handler.UpdateNotificationPreferences]: invalid request body")
+
+		log.WithError(err).Warn("Invalid notification preferences request")
+
+		return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	preferences, err := h.notificationUsecase.UpdatePreferences(c.Request().Context(), userID, req)
+	if err != nil {
+		switch err.Error() {
+		case "[NotificationUsecase.UpdatePreferences]: preferences are required",
+			"[NotificationUsecase.UpdatePreferences]: invalid notification type",
+			"[NotificationUsecase.UpdatePreferences]: duplicate notification type":
+			err = errors.Wrap(err, "[Handler.UpdateNotificationPreferences]: invalid request")
+
+			log.WithError(err).Warn("Invalid notification preferences request")
+
+			return c.JSON(http.StatusBadRequest, entity.ResponseError{Error: utils.StandardError(err)})
+		}
+		err = errors.Wrap(err, "[Handler.UpdateNotificationPreferences]: failed to update preferences")
+
+		log.WithError(err).Error("Internal server error while updating notification preferences")
+
+		return c.JSON(http.StatusInternalServerError, entity.ResponseError{Error: utils.StandardError(err)})
+	}
+
+	return c.JSON(http.StatusOK, entity.Response{
+		Success: true,
+		Message: "Notification preferences updated successfully",
+		Status:  http.StatusOK,
+		Data:    preferences,
+	})
+}
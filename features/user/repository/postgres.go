@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"order-management/domain"
 	"order-management/entity"
 
@@ -16,16 +19,20 @@ func NewUserRepository(db *gorm.DB) domain.UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) CreateUser(user entity.User) error {
-	if err := r.db.Create(&user).Error; err != nil {
+func (r *userRepository) CreateUser(ctx context.Context, user entity.User) error {
+	if err := r.db.WithContext(ctx).Create(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			err = errors.New("[UserRepository.CreateUser]: user already exists")
+			return err
+		}
 		err = errors.Wrap(err, "[UserRepository.CreateUser]: failed to create user")
 		return err
 	}
 	return nil
 }
 
-func (r *userRepository) GetUserByID(id uint32) (user entity.UserWithOutPassword, err error) {
-	if err := r.db.Model(&entity.User{}).Where("id = ?", id).First(&user).Error; err != nil {
+func (r *userRepository) GetUserByID(ctx context.Context, id uint32) (user entity.UserWithOutPassword, err error) {
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = errors.New("[UserRepository.GetUserByID]: user not found")
 			return entity.UserWithOutPassword{}, err
@@ -36,8 +43,8 @@ func (r *userRepository) GetUserByID(id uint32) (user entity.UserWithOutPassword
 	return user, nil
 }
 
-func (r *userRepository) GetUserByEmail(email string) (user entity.UserWithOutPassword, err error) {
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (user entity.UserWithOutPassword, err error) {
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = errors.New("[UserRepository.GetUserByEmail]: user not found")
 			return entity.UserWithOutPassword{}, err
@@ -48,8 +55,8 @@ func (r *userRepository) GetUserByEmail(email string) (user entity.UserWithOutPa
 	return user, nil
 }
 
-func (r *userRepository) GetUserWithPasswordByEmail(email string) (user entity.User, err error) {
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+func (r *userRepository) GetUserWithPasswordByEmail(ctx context.Context, email string) (user entity.User, err error) {
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = errors.New("[UserRepository.GetUserWithPasswordByEmail]: user not found")
 			return entity.User{}, err
@@ -60,10 +67,54 @@ func (r *userRepository) GetUserWithPasswordByEmail(email string) (user entity.U
 	return user, nil
 }
 
-func (r *userRepository) UpdateUser(user entity.UserWithOutPassword) error {
-	if err := r.db.Model(&entity.User{}).Where("id = ? AND email = ?", user.ID, user.Email).Updates(&user).Error; err != nil {
+func (r *userRepository) UpdateUser(ctx context.Context, user entity.UserWithOutPassword) error {
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).Omit("email_verified_at").Where("id = ? AND email = ?", user.ID, user.Email).Updates(&user).Error; err != nil {
 		err = errors.Wrap(err, "[UserRepository.UpdateUser]: failed to update user")
 		return err
 	}
 	return nil
 }
+
+func (r *userRepository) GetUserWithPasswordByID(ctx context.Context, id uint32) (user entity.User, err error) {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[UserRepository.GetUserWithPasswordByID]: user not found")
+			return entity.User{}, err
+		}
+		err = errors.Wrap(err, "[UserRepository.GetUserWithPasswordByID]: failed to get user with password by id")
+		return entity.User{}, err
+	}
+	return user, nil
+}
+
+// SetEmailVerified only touches users that are not verified yet, so two
+// requests racing with the same token cannot both succeed
+func (r *userRepository) SetEmailVerified(ctx context.Context, id uint32, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", at)
+	if result.Error != nil {
+		err := errors.Wrap(result.Error, "[UserRepository.SetEmailVerified]: failed to set email verified")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[UserRepository.SetEmailVerified]: email already verified")
+	}
+	return nil
+}
+
+// UpdatePassword replaces the hash only while it still is current, so a reset
+// token bound to the old hash works once even under concurrent requests
+func (r *userRepository) UpdatePassword(ctx context.Context, id uint32, current string, password string) error {
+	result := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND password = ?", id, current).
+		Update("password", password)
+	if result.Error != nil {
+		err := errors.Wrap(result.Error, "[UserRepository.UpdatePassword]: failed to update password")
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[UserRepository.UpdatePassword]: password already changed")
+	}
+	return nil
+}
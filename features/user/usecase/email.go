@@ -0,0 +1,229 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailPurpose   = "verify-email"
+	resetPasswordPurpose = "reset-password"
+)
+
+// Verification tokens are bound to the address, so changing it voids them,
+// and are refused once the address is verified. Reset tokens are bound to the
+// current password hash. See utils.SignActionToken.
+func verifyEmailState(user entity.UserWithOutPassword) string {
+	return user.Email
+}
+
+func resetPasswordState(user entity.User) string {
+	return user.Password
+}
+
+// actionLink appends the token to the frontend page configured under key
+func actionLink(key string, fallback string, token string) string {
+	link := viper.GetString(key)
+	if link == "" {
+		link = fallback
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link + "?token=" + url.QueryEscape(token)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (u *userUsecase) SendVerificationEmail(ctx context.Context, userID uint32) error {
+	log.Trace("Entering function SendVerificationEmail()")
+	defer log.Trace("Exiting function SendVerificationEmail()")
+
+	log.WithFields(log.Fields{
+		"userID": userID,
+	}).Debug("Sending verification email")
+
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if err.Error() == "[UserRepository.GetUserByID]: user not found" {
+			err = errors.New("[UserUsecase.SendVerificationEmail]: user not found")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.SendVerificationEmail]: failed to get user by id")
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		err = errors.New("[UserUsecase.SendVerificationEmail]: email already verified")
+		return err
+	}
+
+	ttl := utils.DurationOr("user.verifyttl", 48*time.Hour)
+	token := utils.SignActionToken(u.actionSecret, verifyEmailPurpose, user.ID, time.Now().Add(ttl), verifyEmailState(user))
+
+	if err := u.mailer.Send(ctx, entity.Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Welcome!\n\nConfirm your email address by opening the link below within %s:\n\n%s\n\nIf you did not create an account, ignore this email.\n",
+			ttl, actionLink("user.verifyurl", "http://localhost:3000/verify-email", token)),
+	}); err != nil {
+		err = errors.Wrap(err, "[UserUsecase.SendVerificationEmail]: failed to send email")
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) VerifyEmail(ctx context.Context, token string) error {
+	log.Trace("Entering function VerifyEmail()")
+	defer log.Trace("Exiting function VerifyEmail()")
+
+	verified := false
+	userID, err := utils.ParseActionToken(u.actionSecret, verifyEmailPurpose, token, func(userID uint32) (string, error) {
+		user, err := u.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		verified = user.EmailVerifiedAt != nil
+		return verifyEmailState(user), nil
+	})
+	if err != nil {
+		switch err.Error() {
+		case "[utils.ParseActionToken]: invalid token",
+			"[UserRepository.GetUserByID]: user not found":
+			err = errors.New("[UserUsecase.VerifyEmail]: invalid token")
+			return err
+		case "[utils.ParseActionToken]: token expired":
+			err = errors.New("[UserUsecase.VerifyEmail]: token expired")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.VerifyEmail]: failed to check token")
+		return err
+	}
+	if verified {
+		err = errors.New("[UserUsecase.VerifyEmail]: email already verified")
+		return err
+	}
+
+	if err := u.repo.SetEmailVerified(ctx, userID, time.Now()); err != nil {
+		if err.Error() == "[UserRepository.SetEmailVerified]: email already verified" {
+			err = errors.New("[UserUsecase.VerifyEmail]: email already verified")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.VerifyEmail]: failed to set email verified")
+		return err
+	}
+	return nil
+}
+
+// RequireVerifiedEmail is checked before placing an order. It only blocks
+// when user.requireverification is on.
+func (u *userUsecase) RequireVerifiedEmail(ctx context.Context, userID uint32) error {
+	if !viper.GetBool("user.requireverification") {
+		return nil
+	}
+
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		err = errors.Wrap(err, "[UserUsecase.RequireVerifiedEmail]: failed to get user by id")
+		return err
+	}
+	if user.EmailVerifiedAt == nil {
+		err = errors.New("[UserUsecase.RequireVerifiedEmail]: email not verified")
+		return err
+	}
+	return nil
+}
+
+// ForgotPassword mails a reset link when the address belongs to a user. An
+// unknown address is not an error, so the endpoint does not reveal which
+// addresses are registered.
+func (u *userUsecase) ForgotPassword(ctx context.Context, email string) error {
+	log.Trace("Entering function ForgotPassword()")
+	defer log.Trace("Exiting function ForgotPassword()")
+
+	log.WithFields(log.Fields{
+		"email": email,
+	}).Debug("Requesting password reset")
+
+	user, err := u.repo.GetUserWithPasswordByEmail(ctx, email)
+	if err != nil {
+		if err.Error() == "[UserRepository.GetUserWithPasswordByEmail]: user not found" {
+			return nil
+		}
+		err = errors.Wrap(err, "[UserUsecase.ForgotPassword]: failed to get user with password by email")
+		return err
+	}
+
+	ttl := utils.DurationOr("user.resetttl", time.Hour)
+	token := utils.SignActionToken(u.actionSecret, resetPasswordPurpose, user.ID, time.Now().Add(ttl), resetPasswordState(user))
+
+	if err := u.mailer.Send(ctx, entity.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Someone asked to reset the password of your account.\n\nChoose a new password by opening the link below within %s:\n\n%s\n\nThe link works once. If you did not ask for this, ignore this email and your password stays the same.\n",
+			ttl, actionLink("user.reseturl", "http://localhost:3000/reset-password", token)),
+	}); err != nil {
+		err = errors.Wrap(err, "[UserUsecase.ForgotPassword]: failed to send email")
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) ResetPassword(ctx context.Context, token string, password string) error {
+	log.Trace("Entering function ResetPassword()")
+	defer log.Trace("Exiting function ResetPassword()")
+
+	if password == "" {
+		return errors.New("[UserUsecase.ResetPassword]: password is required")
+	}
+
+	var current string
+	userID, err := utils.ParseActionToken(u.actionSecret, resetPasswordPurpose, token, func(userID uint32) (string, error) {
+		user, err := u.repo.GetUserWithPasswordByID(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		current = user.Password
+		return resetPasswordState(user), nil
+	})
+	if err != nil {
+		switch err.Error() {
+		case "[utils.ParseActionToken]: invalid token",
+			"[UserRepository.GetUserWithPasswordByID]: user not found":
+			err = errors.New("[UserUsecase.ResetPassword]: invalid token")
+			return err
+		case "[utils.ParseActionToken]: token expired":
+			err = errors.New("[UserUsecase.ResetPassword]: token expired")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.ResetPassword]: failed to check token")
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		err = errors.Wrap(err, "[UserUsecase.ResetPassword]: failed to hash password")
+		return err
+	}
+
+	if err := u.repo.UpdatePassword(ctx, userID, current, string(hashedPassword)); err != nil {
+		if err.Error() == "[UserRepository.UpdatePassword]: password already changed" {
+			err = errors.New("[UserUsecase.ResetPassword]: invalid token")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.ResetPassword]: failed to update password")
+		return err
+	}
+	return nil
+}
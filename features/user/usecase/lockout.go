@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const unlockLoginPurpose = "unlock-login"
+
+// unlockLoginState binds an unlock token to one lockout, once it is lifted
+// or replaced by a later one the token stops working
+func unlockLoginState(attempt entity.LoginAttempt) string {
+	if !attempt.Locked || attempt.BlockedUntil == nil {
+		return ""
+	}
+	return attempt.Key + "|" + strconv.FormatInt(attempt.BlockedUntil.Unix(), 10)
+}
+
+// loginFailed counts a failed login. Failing to count must not hide the
+// credential error from the caller, so problems are only logged. When the
+// account of a known user just got locked it is mailed an unlock link.
+func (u *userUsecase) loginFailed(ctx context.Context, user *entity.User, keys []string) {
+	locked, err := u.guard.Failed(ctx, keys...)
+	if err != nil {
+		log.WithError(err).Error("Failed to record failed login")
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	for _, attempt := range locked {
+		if attempt.Key != entity.UserLoginKey(user.Email) {
+			continue
+		}
+		if err := u.sendUnlockEmail(ctx, *user, attempt); err != nil {
+			log.WithFields(log.Fields{
+				"userID": user.ID,
+			}).WithError(err).Warn("Failed to send unlock email")
+		}
+	}
+}
+
+func (u *userUsecase) sendUnlockEmail(ctx context.Context, user entity.User, attempt entity.LoginAttempt) error {
+	token := utils.SignActionToken(u.actionSecret, unlockLoginPurpose, user.ID, *attempt.BlockedUntil, unlockLoginState(attempt))
+
+	if err := u.mailer.Send(ctx, entity.Email{
+		To:      user.Email,
+		Subject: "Your account was locked",
+		Text: fmt.Sprintf("There were too many failed attempts to log in to your account, so logging in is blocked until %s.\n\nIf it was you, unlock your account now by opening the link below:\n\n%s\n\nIf it was not you, someone may be guessing your password. Consider changing it once the account is unlocked.\n",
+			attempt.BlockedUntil.UTC().Format(time.RFC1123), actionLink("user.unlockurl", "http://localhost:3000/unlock-account", token)),
+	}); err != nil {
+		err = errors.Wrap(err, "[UserUsecase.sendUnlockEmail]: failed to send email")
+		return err
+	}
+	return nil
+}
+
+// UnlockLogin lifts the account lockout with the token from the unlock
+// email. A lockout of the client's IP stays in place.
+func (u *userUsecase) UnlockLogin(ctx context.Context, token string) error {
+	log.Trace("Entering function UnlockLogin()")
+	defer log.Trace("Exiting function UnlockLogin()")
+
+	var key string
+	_, err := utils.ParseActionToken(u.actionSecret, unlockLoginPurpose, token, func(userID uint32) (string, error) {
+		user, err := u.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		key = entity.UserLoginKey(user.Email)
+		attempt, err := u.guard.GetLoginAttempt(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		return unlockLoginState(attempt), nil
+	})
+	if err != nil {
+		switch err.Error() {
+		case "[utils.ParseActionToken]: invalid token",
+			"[UserRepository.GetUserByID]: user not found":
+			err = errors.New("[UserUsecase.UnlockLogin]: invalid token")
+			return err
+		case "[utils.ParseActionToken]: token expired":
+			err = errors.New("[UserUsecase.UnlockLogin]: token expired")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.UnlockLogin]: failed to check token")
+		return err
+	}
+
+	if err := u.guard.Unlock(ctx, key); err != nil {
+		if err.Error() == "[LoginGuard.Unlock]: lockout not found" {
+			err = errors.New("[UserUsecase.UnlockLogin]: invalid token")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.UnlockLogin]: failed to unlock login")
+		return err
+	}
+	return nil
+}
@@ -1,30 +1,36 @@
 package usecase
 
 import (
+	"context"
+	"time"
+
 	"order-management/domain"
 	"order-management/entity"
 	"order-management/utils"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type userUsecase struct {
-	repo domain.UserRepository
+	repo   domain.UserRepository
+	mailer domain.Mailer
+	guard  domain.LoginGuard
+	// actionSecret signs the links of verification, reset and unlock emails
+	actionSecret []byte
 }
 
-func NewUserUsecase(userRepository domain.UserRepository) domain.UserUsecase {
-	return &userUsecase{repo: userRepository}
+func NewUserUsecase(userRepository domain.UserRepository, mailer domain.Mailer, guard domain.LoginGuard, actionSecret []byte) domain.UserUsecase {
+	return &userUsecase{repo: userRepository, mailer: mailer, guard: guard, actionSecret: actionSecret}
 }
 
-func (u *userUsecase) CreateUser(user entity.User) error {
+func (u *userUsecase) CreateUser(ctx context.Context, user entity.User) error {
 	log.Trace("Entering function CreateUser()")
 	defer log.Trace("Exiting function CreateUser()")
 
 	log.WithFields(log.Fields{
-		"user": user,
+		"email": user.Email,
 	}).Debug("Creating user")
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
@@ -33,8 +39,10 @@ func (u *userUsecase) CreateUser(user entity.User) error {
 		return err
 	}
 	user.Password = string(hashedPassword)
+	// Registration is public, never trust a role coming from the request body
+	user.Role = entity.CUSTOMER
 
-	if err := u.repo.CreateUser(user); err != nil {
+	if err := u.repo.CreateUser(ctx, user); err != nil {
 		if err.Error() == "[UserRepository.CreateUser]: user already exists" {
 			err = errors.New("[UserUsecase.CreateUser]: user already exists")
 			return err
@@ -42,10 +50,50 @@ func (u *userUsecase) CreateUser(user entity.User) error {
 		err = errors.Wrap(err, "[UserUsecase.CreateUser]: failed to create user")
 		return err
 	}
+
+	// The account exists either way, a lost email can be sent again
+	created, err := u.repo.GetUserByEmail(ctx, user.Email)
+	if err == nil {
+		err = u.SendVerificationEmail(ctx, created.ID)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"email": user.Email,
+		}).WithError(err).Warn("Failed to send verification email")
+	}
 	return nil
 }
 
-func (u *userUsecase) UpdateUser(user entity.UserWithOutPassword) error {
+func (u *userUsecase) CreateAdmin(ctx context.Context, user entity.User) error {
+	log.Trace("Entering function CreateAdmin()")
+	defer log.Trace("Exiting function CreateAdmin()")
+
+	log.WithFields(log.Fields{
+		"email": user.Email,
+	}).Debug("Creating admin")
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		err = errors.Wrap(err, "[UserUsecase.CreateAdmin]: failed to hash password")
+		return err
+	}
+	user.Password = string(hashedPassword)
+	user.Role = entity.ADMIN
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+
+	if err := u.repo.CreateUser(ctx, user); err != nil {
+		if err.Error() == "[UserRepository.CreateUser]: user already exists" {
+			err = errors.New("[UserUsecase.CreateAdmin]: user already exists")
+			return err
+		}
+		err = errors.Wrap(err, "[UserUsecase.CreateAdmin]: failed to create admin")
+		return err
+	}
+	return nil
+}
+
+func (u *userUsecase) UpdateUser(ctx context.Context, user entity.UserWithOutPassword) error {
 	log.Trace("Entering function UpdateUser()")
 	defer log.Trace("Exiting function UpdateUser()")
 
@@ -53,25 +101,39 @@ func (u *userUsecase) UpdateUser(user entity.UserWithOutPassword) error {
 		"user": user,
 	}).Debug("Updating user")
 
-	if err := u.repo.UpdateUser(user); err != nil {
+	if err := u.repo.UpdateUser(ctx, user); err != nil {
 		err = errors.Wrap(err, "[UserUsecase.UpdateUser]: failed to update user")
 		return err
 	}
 	return nil
 }
 
-func (u *userUsecase) Login(email string, password string) (string, error) {
+func (u *userUsecase) Login(ctx context.Context, email string, password string, ip string) (string, error) {
 	log.Trace("Entering function Login()")
 	defer log.Trace("Exiting function Login()")
 
 	log.WithFields(log.Fields{
-		"email":    email,
-		"password": password,
+		"email": email,
+		"ip":    ip,
 	}).Debug("Logging in user")
 
-	credentials, err := u.repo.GetUserWithPasswordByEmail(email)
+	accountKey := entity.UserLoginKey(email)
+	keys := []string{accountKey, entity.IPLoginKey(ip)}
+
+	wait, err := u.guard.Check(ctx, keys...)
+	if err != nil {
+		err = errors.Wrap(err, "[UserUsecase.Login]: failed to check login attempts")
+		return "", err
+	}
+	if wait > 0 {
+		err = errors.Wrap(&entity.LoginLockedError{RetryAfter: wait}, "[UserUsecase.Login]")
+		return "", err
+	}
+
+	credentials, err := u.repo.GetUserWithPasswordByEmail(ctx, email)
 	if err != nil {
 		if err.Error() == "[UserRepository.GetUserWithPasswordByEmail]: user not found" {
+			u.loginFailed(ctx, nil, keys)
 			err = errors.New("[UserUsecase.Login]: user not found")
 			return "", err
 		}
@@ -80,15 +142,23 @@ func (u *userUsecase) Login(email string, password string) (string, error) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(credentials.Password), []byte(password)); err != nil {
+		u.loginFailed(ctx, &credentials, keys)
 		err = errors.New("[UserUsecase.Login]: invalid password")
 		return "", err
 	}
 
+	if err := u.guard.Succeeded(ctx, accountKey); err != nil {
+		log.WithFields(log.Fields{
+			"email": email,
+		}).WithError(err).Warn("Failed to reset login attempts")
+	}
+
 	t, err := utils.GenerateJWT(map[string]interface{}{
 		"id":      credentials.ID,
 		"email":   credentials.Email,
 		"address": credentials.Address,
-	}, []byte(viper.GetString("jwt.usersecret")))
+		"role":    credentials.Role,
+	}, entity.UserTokenAudience)
 
 	if err != nil {
 		err = errors.Wrap(err, "[UserUsecase.Login]: failed to generate user jwt")
@@ -97,7 +167,8 @@ func (u *userUsecase) Login(email string, password string) (string, error) {
 
 	return t, nil
 }
-func (u *userUsecase) GetUserByID(id uint32) (entity.UserWithOutPassword, error) {
+
+func (u *userUsecase) GetUserByID(ctx context.Context, id uint32) (entity.UserWithOutPassword, error) {
 	log.Trace("Entering function GetUserByID()")
 	defer log.Trace("Exiting function GetUserByID()")
 
@@ -105,7 +176,7 @@ func (u *userUsecase) GetUserByID(id uint32) (entity.UserWithOutPassword, error)
 		"id": id,
 	}).Debug("Getting user by id")
 
-	user, err := u.repo.GetUserByID(id)
+	user, err := u.repo.GetUserByID(ctx, id)
 	if err != nil {
 		err = errors.Wrap(err, "[UserUsecase.GetUserByID]: failed to get user by id")
 		return entity.UserWithOutPassword{}, err
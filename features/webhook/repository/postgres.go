@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) domain.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook *entity.Webhook) error {
+	if err := r.db.WithContext(ctx).Omit("Shop").Create(webhook).Error; err != nil {
+		return errors.Wrap(err, "[WebhookRepository.CreateWebhook]: failed to create webhook")
+	}
+	return nil
+}
+
+func (r *webhookRepository) GetWebhooks(ctx context.Context, shopID uint32) (webhooks []entity.Webhook, err error) {
+	if err := r.db.WithContext(ctx).
+		Where("shop_id = ?", shopID).
+		Order("id").
+		Find(&webhooks).Error; err != nil {
+		err = errors.Wrap(err, "[WebhookRepository.GetWebhooks]: failed to get webhooks")
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *webhookRepository) GetWebhook(ctx context.Context, req *entity.WebhookManagementRequest) (webhook entity.Webhook, err error) {
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND shop_id = ?", req.WebhookID, req.ShopID).
+		First(&webhook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errors.New("[WebhookRepository.GetWebhook]: webhook not found")
+			return entity.Webhook{}, err
+		}
+		err = errors.Wrap(err, "[WebhookRepository.GetWebhook]: failed to get webhook")
+		return entity.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepository) UpdateWebhook(ctx context.Context, webhook *entity.Webhook) error {
+	if err := r.db.WithContext(ctx).Model(webhook).
+		Select("URL", "Events", "Active").
+		Updates(webhook).Error; err != nil {
+		return errors.Wrap(err, "[WebhookRepository.UpdateWebhook]: failed to update webhook")
+	}
+	return nil
+}
+
+// DeleteWebhook deletes the webhook with its deliveries
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, req *entity.WebhookManagementRequest) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND shop_id = ?", req.WebhookID, req.ShopID).Delete(&entity.Webhook{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[WebhookRepository.DeleteWebhook]: failed to delete webhook")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[WebhookRepository.DeleteWebhook]: webhook not found")
+	}
+	return nil
+}
+
+// GetDeliveries returns the webhook's latest deliveries first
+func (r *webhookRepository) GetDeliveries(ctx context.Context, webhookID uint32, filter entity.WebhookDeliveryFilter) (deliveries []entity.WebhookDelivery, err error) {
+	query := r.db.WithContext(ctx).
+		Preload("Event", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "type")
+		}).
+		Where("webhook_id = ?", webhookID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if err := query.Order("id DESC").Limit(filter.Limit).Find(&deliveries).Error; err != nil {
+		err = errors.Wrap(err, "[WebhookRepository.GetDeliveries]: failed to get deliveries")
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ResetDelivery queues a delivery again with a fresh set of attempts
+func (r *webhookRepository) ResetDelivery(ctx context.Context, webhookID uint32, deliveryID uint64) error {
+	result := r.db.WithContext(ctx).Model(&entity.WebhookDelivery{}).
+		Where("id = ? AND webhook_id = ?", deliveryID, webhookID).
+		Updates(map[string]interface{}{
+			"status":          entity.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "[WebhookRepository.ResetDelivery]: failed to reset delivery")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("[WebhookRepository.ResetDelivery]: delivery not found")
+	}
+	return nil
+}
+
+// AddEvents adds each event with a pending delivery for every active
+// webhook of its shop subscribed to it, all or nothing. Events no webhook
+// wants are not stored, and neither are those of a source event that was
+// added before.
+func (r *webhookRepository) AddEvents(ctx context.Context, events []entity.WebhookEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	shopIDs := make([]uint32, 0, len(events))
+	for _, event := range events {
+		shopIDs = append(shopIDs, event.ShopID)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		webhooks := []entity.Webhook{}
+		if err := tx.Where("shop_id IN ? AND active", shopIDs).Find(&webhooks).Error; err != nil {
+			return errors.Wrap(err, "[WebhookRepository.AddEvents]: failed to get webhooks")
+		}
+
+		now := time.Now()
+		for i := range events {
+			event := &events[i]
+			deliveries := []entity.WebhookDelivery{}
+			for _, webhook := range webhooks {
+				if webhook.ShopID != event.ShopID || !webhook.Subscribes(event.Type) {
+					continue
+				}
+				deliveries = append(deliveries, entity.WebhookDelivery{
+					WebhookID:     webhook.ID,
+					Status:        entity.DeliveryPending,
+					NextAttemptAt: now,
+				})
+			}
+			if len(deliveries) == 0 {
+				continue
+			}
+
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+			if result.Error != nil {
+				return errors.Wrap(result.Error, "[WebhookRepository.AddEvents]: failed to create event")
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			for j := range deliveries {
+				deliveries[j].EventID = event.ID
+			}
+			if err := tx.Omit("Webhook", "Event").Create(&deliveries).Error; err != nil {
+				return errors.Wrap(err, "[WebhookRepository.AddEvents]: failed to create deliveries")
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries that are due,
+// with their webhook and event, and moves their next attempt lease ahead,
+// so no other instance sends them while they are in flight.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, lease time.Duration, limit int) ([]entity.WebhookDelivery, error) {
+	ids := []uint64{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&entity.WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", entity.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return errors.Wrap(err, "[WebhookRepository.ClaimDueDeliveries]: failed to get deliveries")
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&entity.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return errors.Wrap(err, "[WebhookRepository.ClaimDueDeliveries]: failed to claim deliveries")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	deliveries := []entity.WebhookDelivery{}
+	if err := r.db.WithContext(ctx).
+		Preload("Webhook").
+		Preload("Event").
+		Where("id IN ?", ids).
+		Find(&deliveries).Error; err != nil {
+		return nil, errors.Wrap(err, "[WebhookRepository.ClaimDueDeliveries]: failed to load deliveries")
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of the latest attempt of delivery
+func (r *webhookRepository) RecordAttempt(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	if err := r.db.WithContext(ctx).Model(&entity.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"last_response":    delivery.LastResponse,
+			"last_duration_ms": delivery.LastDurationMs,
+			"last_attempt_at":  delivery.LastAttemptAt,
+			"delivered_at":     delivery.DeliveredAt,
+		}).Error; err != nil {
+		return errors.Wrap(err, "[WebhookRepository.RecordAttempt]: failed to update delivery")
+	}
+	return nil
+}
+
+// PurgeWebhookEvents deletes events created before before, together with
+// their deliveries
+func (r *webhookRepository) PurgeWebhookEvents(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ?", before).
+		Delete(&entity.WebhookEvent{})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "[WebhookRepository.PurgeWebhookEvents]: failed to delete events")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// batchSize is how many deliveries one round handles
+	batchSize = 50
+	// maxResponseLog is how much of a response body the log keeps
+	maxResponseLog = 1024
+	// maxRetryDelay caps the backoff between attempts
+	maxRetryDelay = 6 * time.Hour
+)
+
+// newClient does not follow redirects and, unless webhook.allowprivate is
+// set, refuses to connect to loopback, private, link-local and blockedNets
+// addresses, so a webhook cannot reach into the service's own network
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !viper.GetBool("webhook.allowprivate") {
+		dialer.Control = publicOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   utils.DurationOr("webhook.timeout", 10*time.Second),
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// blockedNets are ranges the net.IP checks in publicOnly do not cover:
+// carrier-grade NAT, which cloud providers use internally, and the IETF
+// protocol assignments
+var blockedNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// publicOnly runs after name resolution, on the address actually dialled
+func publicOnly(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errors.Errorf("address %s is not allowed", host)
+	}
+	for _, network := range blockedNets {
+		if network.Contains(ip) {
+			return errors.Errorf("address %s is not allowed", host)
+		}
+	}
+	return nil
+}
+
+// Sign returns the X-Webhook-Signature of body sent at timestamp: the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay doubles webhook.retrydelay with every failed attempt up to
+// maxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	return utils.Backoff(utils.DurationOr("webhook.retrydelay", 30*time.Second), maxRetryDelay, attempts)
+}
+
+// DeliverDue sends due deliveries on webhook.workers connections at once.
+// A claimed delivery is leased past the client timeout, so when an
+// instance stops mid-send another one retries it after the lease.
+func (u *webhookUsecase) DeliverDue(ctx context.Context) (int, error) {
+	lease := u.client.Timeout + 30*time.Second
+	workers := utils.IntOr("webhook.workers", 4)
+
+	return utils.InRounds(ctx, batchSize, func() (int, error) {
+		deliveries, err := u.repo.ClaimDueDeliveries(ctx, lease, batchSize)
+		if err != nil {
+			return 0, errors.Wrap(err, "[WebhookUsecase.DeliverDue]: failed to claim deliveries")
+		}
+
+		queue := make(chan entity.WebhookDelivery)
+		wg := sync.WaitGroup{}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for delivery := range queue {
+					u.deliver(ctx, delivery)
+				}
+			}()
+		}
+		for _, delivery := range deliveries {
+			queue <- delivery
+		}
+		close(queue)
+		wg.Wait()
+
+		return len(deliveries), nil
+	})
+}
+
+// deliver makes one attempt and records it. Deliveries of a webhook paused
+// after they were created fail without a request.
+func (u *webhookUsecase) deliver(ctx context.Context, delivery entity.WebhookDelivery) {
+	fields := log.Fields{
+		"webhookID":  delivery.WebhookID,
+		"deliveryID": delivery.ID,
+		"event":      delivery.Event.Type,
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	delivery.LastResponse = ""
+	delivery.LastDurationMs = 0
+
+	if !delivery.Webhook.Active {
+		delivery.Status = entity.DeliveryFailed
+		delivery.LastError = "webhook is paused"
+	} else {
+		ok := u.send(ctx, &delivery)
+		switch {
+		case ok:
+			delivery.Status = entity.DeliverySucceeded
+			delivery.DeliveredAt = &now
+		case delivery.Attempts >= utils.IntOr("webhook.maxattempts", 8):
+			delivery.Status = entity.DeliveryFailed
+			log.WithFields(fields).Warn("Webhook delivery failed for good")
+		default:
+			delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+		}
+	}
+
+	// The attempt happened even if the job is being stopped
+	if err := u.repo.RecordAttempt(context.WithoutCancel(ctx), &delivery); err != nil {
+		log.WithFields(fields).WithError(err).Error("Failed to record webhook attempt")
+	}
+}
+
+// send posts the delivery and fills in the Last fields, a 2xx response is
+// a success
+func (u *webhookUsecase) send(ctx context.Context, delivery *entity.WebhookDelivery) bool {
+	body, err := json.Marshal(entity.WebhookEnvelope{
+		ID:        delivery.Event.ID,
+		Type:      delivery.Event.Type,
+		CreatedAt: delivery.Event.CreatedAt,
+		Data:      json.RawMessage(delivery.Event.Payload),
+	})
+	if err != nil {
+		delivery.LastError = err.Error()
+		return false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.LastError = err.Error()
+		return false
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-management-webhooks")
+	req.Header.Set("X-Webhook-Id", strconv.FormatUint(delivery.Event.ID, 10))
+	req.Header.Set("X-Webhook-Event", string(delivery.Event.Type))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(delivery.Webhook.Secret, timestamp, body))
+
+	start := time.Now()
+	res, err := u.client.Do(req)
+	delivery.LastDurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.LastError = err.Error()
+		return false
+	}
+	defer res.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseLog))
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	delivery.LastStatusCode = res.StatusCode
+	delivery.LastResponse = string(bytes.ToValidUTF8(response, nil))
+	return res.StatusCode >= 200 && res.StatusCode < 300
+}
+
+// PurgeWebhookEvents deletes events older than webhook.retention, with the
+// delivery log that goes with them
+func (u *webhookUsecase) PurgeWebhookEvents(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-utils.DurationOr("webhook.retention", 30*24*time.Hour))
+	deleted, err := u.repo.PurgeWebhookEvents(ctx, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "[WebhookUsecase.PurgeWebhookEvents]: failed to purge events")
+	}
+	return deleted, nil
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// HandleEvent subscribes webhooks to the event bus. It builds what each
+// shop is sent about the event and stores it with its deliveries, the
+// dispatcher sends them. Orders and products deleted since are skipped.
+func (u *webhookUsecase) HandleEvent(ctx context.Context, event entity.DomainEvent) error {
+	var events []entity.WebhookEvent
+	var err error
+	switch event.Type {
+	case entity.EventOrderPlaced, entity.EventOrderStatusChanged:
+		events, err = u.orderEvents(ctx, event)
+	case entity.EventProductUpdated:
+		events, err = u.productEvents(ctx, event)
+	default:
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.WithFields(log.Fields{
+			"eventID":     event.ID,
+			"type":        event.Type,
+			"aggregateID": event.AggregateID,
+		}).Warn("Subject of the event no longer exists, skipping webhooks")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "[WebhookUsecase.HandleEvent]: failed to build webhook events")
+	}
+
+	if err := u.repo.AddEvents(ctx, events); err != nil {
+		return errors.Wrap(err, "[WebhookUsecase.HandleEvent]: failed to add webhook events")
+	}
+	return nil
+}
+
+// orderEvents reports the order to every shop with products in it, each
+// shop gets its own lines and their subtotal
+func (u *webhookUsecase) orderEvents(ctx context.Context, event entity.DomainEvent) ([]entity.WebhookEvent, error) {
+	order, err := u.orderRepo.GetOrder(ctx, event.AggregateID)
+	if err != nil {
+		return nil, err
+	}
+
+	eventType := entity.OrderCreated
+	status, previous := entity.PENDING, entity.Status("")
+	updatedAt := order.CreatedAt
+	if event.Type == entity.EventOrderStatusChanged {
+		changed := entity.OrderStatusChangedEvent{}
+		if err := event.Decode(&changed); err != nil {
+			return nil, err
+		}
+		eventType = entity.OrderStatusChanged
+		status, previous = changed.To, changed.From
+		updatedAt = event.CreatedAt
+	}
+
+	shopIDs := []uint32{}
+	payloads := map[uint32]*entity.OrderWebhookPayload{}
+	for _, orderProduct := range order.OrderProducts {
+		shopID := orderProduct.Product.ShopID
+		payload, ok := payloads[shopID]
+		if !ok {
+			payload = &entity.OrderWebhookPayload{
+				ID:             order.ID,
+				Status:         status,
+				PreviousStatus: previous,
+				Courier:        order.Courier,
+				Lines:          []entity.OrderWebhookLine{},
+				CreatedAt:      order.CreatedAt,
+				UpdatedAt:      updatedAt,
+			}
+			payloads[shopID] = payload
+			shopIDs = append(shopIDs, shopID)
+		}
+		payload.Lines = append(payload.Lines, entity.OrderWebhookLine{
+			ProductID: orderProduct.ProductID,
+			VariantID: orderProduct.VariantID,
+			Amount:    orderProduct.Amount,
+			Price:     orderProduct.Price,
+		})
+		payload.Subtotal += uint64(orderProduct.Price) * uint64(orderProduct.Amount)
+	}
+
+	events := make([]entity.WebhookEvent, 0, len(shopIDs))
+	for _, shopID := range shopIDs {
+		webhookEvent, err := newWebhookEvent(event, shopID, eventType, payloads[shopID])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, webhookEvent)
+	}
+	return events, nil
+}
+
+// productEvents reports the product, variants included, to its shop
+func (u *webhookUsecase) productEvents(ctx context.Context, event entity.DomainEvent) ([]entity.WebhookEvent, error) {
+	product, err := u.productRepo.GetProductByID(ctx, event.AggregateID)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := u.productRepo.GetVariantsByProductID(ctx, product.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := entity.ProductWebhookPayload{
+		ID:          product.ID,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price,
+		SKU:         product.SKU,
+		CategoryID:  product.CategoryID,
+		Variants:    make([]entity.VariantResponse, 0, len(variants)),
+	}
+	for _, variant := range variants {
+		payload.Variants = append(payload.Variants, entity.VariantResponse{
+			ID:      variant.ID,
+			SKU:     variant.SKU,
+			Price:   variant.Price,
+			Stock:   variant.Stock,
+			Options: variant.Options(),
+		})
+	}
+
+	webhookEvent, err := newWebhookEvent(event, product.ShopID, entity.ProductUpdated, payload)
+	if err != nil {
+		return nil, err
+	}
+	return []entity.WebhookEvent{webhookEvent}, nil
+}
+
+func newWebhookEvent(source entity.DomainEvent, shopID uint32, eventType entity.WebhookEventType, payload interface{}) (entity.WebhookEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return entity.WebhookEvent{}, err
+	}
+	return entity.WebhookEvent{
+		SourceEventID: &source.ID,
+		ShopID:        shopID,
+		Type:          eventType,
+		Payload:       string(data),
+	}, nil
+}
@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxWebhooks is how many webhooks a shop can register
+const maxWebhooks = 10
+
+// maxDeliveryLog is the default and largest page of the delivery log
+const maxDeliveryLog = 100
+
+type webhookUsecase struct {
+	repo        domain.WebhookRepository
+	orderRepo   domain.OrderRepository
+	productRepo domain.ProductRepository
+	client      *http.Client
+}
+
+func NewWebhookUsecase(repo domain.WebhookRepository, orderRepo domain.OrderRepository, productRepo domain.ProductRepository) domain.WebhookUsecase {
+	return &webhookUsecase{repo: repo, orderRepo: orderRepo, productRepo: productRepo, client: newClient()}
+}
+
+// validURL accepts absolute https URLs without credentials, and http ones
+// with webhook.allowhttp for local development
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil || len(raw) > 2048 {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		return viper.GetBool("webhook.allowhttp")
+	}
+	return false
+}
+
+// validEvents returns the events as stored, space separated without
+// duplicates
+func validEvents(events []entity.WebhookEventType) (string, bool) {
+	if len(events) == 0 {
+		return "", false
+	}
+	names := []string{}
+	seen := map[entity.WebhookEventType]bool{}
+	for _, event := range events {
+		if !event.Valid() {
+			return "", false
+		}
+		if !seen[event] {
+			seen[event] = true
+			names = append(names, string(event))
+		}
+	}
+	return strings.Join(names, " "), true
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func toWebhookResponse(webhook entity.Webhook) entity.WebhookResponse {
+	return entity.WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.EventList(),
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+		UpdatedAt: webhook.UpdatedAt,
+	}
+}
+
+// CreateWebhook returns the signing secret, which cannot be shown again
+func (u *webhookUsecase) CreateWebhook(ctx context.Context, shopID uint32, req entity.WebhookRequest) (entity.WebhookCreated, error) {
+	log.WithFields(log.Fields{
+		"shopID": shopID,
+		"req":    req,
+	}).Debug("Creating webhook")
+
+	if !validURL(req.URL) {
+		err := errors.New("[WebhookUsecase.CreateWebhook]: invalid url")
+		return entity.WebhookCreated{}, err
+	}
+	events, ok := validEvents(req.Events)
+	if !ok {
+		err := errors.New("[WebhookUsecase.CreateWebhook]: invalid event")
+		return entity.WebhookCreated{}, err
+	}
+
+	existing, err := u.repo.GetWebhooks(ctx, shopID)
+	if err != nil {
+		err = errors.Wrap(err, "[WebhookUsecase.CreateWebhook]: failed to get webhooks")
+		return entity.WebhookCreated{}, err
+	}
+	if len(existing) >= maxWebhooks {
+		err := errors.New("[WebhookUsecase.CreateWebhook]: too many webhooks")
+		return entity.WebhookCreated{}, err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		err = errors.Wrap(err, "[WebhookUsecase.CreateWebhook]: failed to generate secret")
+		return entity.WebhookCreated{}, err
+	}
+
+	webhook := entity.Webhook{
+		ShopID: shopID,
+		URL:    req.URL,
+		Secret: secret,
+		Events: events,
+		Active: true,
+	}
+	if err := u.repo.CreateWebhook(ctx, &webhook); err != nil {
+		err = errors.Wrap(err, "[WebhookUsecase.CreateWebhook]: failed to create webhook")
+		return entity.WebhookCreated{}, err
+	}
+
+	return entity.WebhookCreated{
+		WebhookResponse: toWebhookResponse(webhook),
+		Secret:          secret,
+	}, nil
+}
+
+func (u *webhookUsecase) GetWebhooks(ctx context.Context, shopID uint32) ([]entity.WebhookResponse, error) {
+	webhooks, err := u.repo.GetWebhooks(ctx, shopID)
+	if err != nil {
+		err = errors.Wrap(err, "[WebhookUsecase.GetWebhooks]: failed to get webhooks")
+		return nil, err
+	}
+
+	response := make([]entity.WebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		response = append(response, toWebhookResponse(webhook))
+	}
+	return response, nil
+}
+
+// UpdateWebhook replaces the URL and events, and pauses or resumes the
+// webhook when body sets active. Paused webhooks get no new deliveries.
+func (u *webhookUsecase) UpdateWebhook(ctx context.Context, req *entity.WebhookManagementRequest, body entity.WebhookRequest) (entity.WebhookResponse, error) {
+	if !validURL(body.URL) {
+		err := errors.New("[WebhookUsecase.UpdateWebhook]: invalid url")
+		return entity.WebhookResponse{}, err
+	}
+	events, ok := validEvents(body.Events)
+	if !ok {
+		err := errors.New("[WebhookUsecase.UpdateWebhook]: invalid event")
+		return entity.WebhookResponse{}, err
+	}
+
+	webhook, err := u.repo.GetWebhook(ctx, req)
+	if err != nil {
+		if err.Error() == "[WebhookRepository.GetWebhook]: webhook not found" {
+			err = errors.New("[WebhookUsecase.UpdateWebhook]: webhook not found")
+			return entity.WebhookResponse{}, err
+		}
+		err = errors.Wrap(err, "[WebhookUsecase.UpdateWebhook]: failed to get webhook")
+		return entity.WebhookResponse{}, err
+	}
+
+	webhook.URL = body.URL
+	webhook.Events = events
+	if body.Active != nil {
+		webhook.Active = *body.Active
+	}
+	if err := u.repo.UpdateWebhook(ctx, &webhook); err != nil {
+		err = errors.Wrap(err, "[WebhookUsecase.UpdateWebhook]: failed to update webhook")
+		return entity.WebhookResponse{}, err
+	}
+
+	return toWebhookResponse(webhook), nil
+}
+
+func (u *webhookUsecase) DeleteWebhook(ctx context.Context, req *entity.WebhookManagementRequest) error {
+	if err := u.repo.DeleteWebhook(ctx, req); err != nil {
+		if err.Error() == "[WebhookRepository.DeleteWebhook]: webhook not found" {
+			err = errors.New("[WebhookUsecase.DeleteWebhook]: webhook not found")
+			return err
+		}
+		err = errors.Wrap(err, "[WebhookUsecase.DeleteWebhook]: failed to delete webhook")
+		return err
+	}
+	return nil
+}
+
+// GetDeliveries is the delivery log of a webhook, latest first
+func (u *webhookUsecase) GetDeliveries(ctx context.Context, req *entity.WebhookManagementRequest, filter entity.WebhookDeliveryFilter) ([]entity.WebhookDeliveryResponse, error) {
+	if _, err := u.repo.GetWebhook(ctx, req); err != nil {
+		if err.Error() == "[WebhookRepository.GetWebhook]: webhook not found" {
+			err = errors.New("[WebhookUsecase.GetDeliveries]: webhook not found")
+			return nil, err
+		}
+		err = errors.Wrap(err, "[WebhookUsecase.GetDeliveries]: failed to get webhook")
+		return nil, err
+	}
+
+	if filter.Limit <= 0 || filter.Limit > maxDeliveryLog {
+		filter.Limit = maxDeliveryLog
+	}
+	deliveries, err := u.repo.GetDeliveries(ctx, req.WebhookID, filter)
+	if err != nil {
+		err = errors.Wrap(err, "[WebhookUsecase.GetDeliveries]: failed to get deliveries")
+		return nil, err
+	}
+
+	response := make([]entity.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		item := entity.WebhookDeliveryResponse{
+			ID:            delivery.ID,
+			EventID:       delivery.EventID,
+			EventType:     delivery.Event.Type,
+			Status:        delivery.Status,
+			Attempts:      delivery.Attempts,
+			StatusCode:    delivery.LastStatusCode,
+			Error:         delivery.LastError,
+			Response:      delivery.LastResponse,
+			DurationMs:    delivery.LastDurationMs,
+			LastAttemptAt: delivery.LastAttemptAt,
+			DeliveredAt:   delivery.DeliveredAt,
+			CreatedAt:     delivery.CreatedAt,
+		}
+		if delivery.Status == entity.DeliveryPending {
+			next := delivery.NextAttemptAt
+			item.NextAttemptAt = &next
+		}
+		response = append(response, item)
+	}
+	return response, nil
+}
+
+// Redeliver queues a delivery again, e.g. after the endpoint was fixed
+func (u *webhookUsecase) Redeliver(ctx context.Context, req *entity.WebhookManagementRequest, deliveryID uint64) error {
+	if _, err := u.repo.GetWebhook(ctx, req); err != nil {
+		if err.Error() == "[WebhookRepository.GetWebhook]: webhook not found" {
+			err = errors.New("[WebhookUsecase.Redeliver]: webhook not found")
+			return err
+		}
+		err = errors.Wrap(err, "[WebhookUsecase.Redeliver]: failed to get webhook")
+		return err
+	}
+
+	if err := u.repo.ResetDelivery(ctx, req.WebhookID, deliveryID); err != nil {
+		if err.Error() == "[WebhookRepository.ResetDelivery]: delivery not found" {
+			err = errors.New("[WebhookUsecase.Redeliver]: delivery not found")
+			return err
+		}
+		err = errors.Wrap(err, "[WebhookUsecase.Redeliver]: failed to reset delivery")
+		return err
+	}
+	return nil
+}
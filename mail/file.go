@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes every email as an .eml file below dir instead of
+// sending it, for local development. The files open in any mail client.
+func NewFileMailer(dir string, from string) domain.Mailer {
+	return &fileMailer{dir: dir, from: from}
+}
+
+func (m *fileMailer) Send(ctx context.Context, email entity.Email) error {
+	msg, err := message(m.from, email)
+	if err != nil {
+		return errors.Wrap(err, "[FileMailer.Send]: invalid email")
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return errors.Wrap(err, "[FileMailer.Send]: failed to create directory")
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return errors.Wrap(err, "[FileMailer.Send]: failed to name file")
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), msg, 0o644); err != nil {
+		return errors.Wrap(err, "[FileMailer.Send]: failed to write file")
+	}
+	return nil
+}
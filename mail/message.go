@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"time"
+
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+)
+
+// message renders email as an RFC 5322 message with a UTF-8 plain text body.
+// Header values come from user input, so line breaks in them are rejected
+// instead of letting them add headers.
+func message(from string, email entity.Email) ([]byte, error) {
+	if strings.ContainsAny(from+email.To+email.Subject, "\r\n") {
+		return nil, errors.New("[mail.message]: header contains a line break")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + email.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", email.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(email.Text, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes(), nil
+}
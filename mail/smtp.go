@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+
+	"order-management/domain"
+	"order-management/entity"
+
+	"github.com/pkg/errors"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type smtpMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPMailer sends through an SMTP server. Port 465 uses implicit TLS,
+// other ports upgrade with STARTTLS when the server offers it. Credentials
+// are only sent over TLS.
+func NewSMTPMailer(cfg SMTPConfig) domain.Mailer {
+	return &smtpMailer{cfg: cfg}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, email entity.Email) error {
+	msg, err := message(m.cfg.From, email)
+	if err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: invalid email")
+	}
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: invalid sender")
+	}
+	to, err := mail.ParseAddress(email.To)
+	if err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: invalid recipient")
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: failed to connect")
+	}
+	defer client.Close()
+
+	if m.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return errors.Wrap(err, "[SMTPMailer.Send]: failed to authenticate")
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: sender rejected")
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: recipient rejected")
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: failed to start message")
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return errors.Wrap(err, "[SMTPMailer.Send]: failed to write message")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "[SMTPMailer.Send]: message rejected")
+	}
+
+	return client.Quit()
+}
+
+// dial connects within the context's deadline, net/smtp has no context
+// support of its own
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host}
+
+	var conn net.Conn
+	var err error
+	if m.cfg.Port == 465 {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		dialer := &net.Dialer{}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
@@ -3,23 +3,77 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"order-management/domain"
 	"order-management/entity"
 	"order-management/utils"
 	"strings"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
-// Todo: only failed case
-// Happy case is not that important
-func ShopAuth() echo.MiddlewareFunc {
+// claims is a pointer to a claims struct such as *entity.UserJWT, so
+// parseClaims can allocate the struct and hand it to jwt
+type claims[T any] interface {
+	*T
+	jwt.Claims
+}
+
+// parseClaims verifies token for audience and decodes it into a new T. A
+// missing or mistyped claim fails decoding or the claims' Validate instead
+// of panicking in a handler later.
+func parseClaims[T any, P claims[T]](token, audience string) (P, error) {
+	parsed := P(new(T))
+	if err := utils.ParseJWT(token, audience, parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// tokenAuth is the middleware shared by ShopAuth and UserAuth. It reads the
+// bearer token, lets authenticate turn it into the principal P and stores
+// that under key for the handlers. Any failure answers 401, authenticate
+// logs why. Without an Authorization header the session cookies are tried
+// in order, and a request authenticated by one has to pass the CSRF check.
+func tokenAuth[P any](name, key string, cookies []string, authenticate func(token string) (P, error)) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			bearerToken := c.Request().Header.Get("Authorization")
 			if bearerToken == "" {
-				err := errors.New("[Middleware.ShopAuth]: no authorization header found")
+				var sessionErr error
+				for _, cookie := range cookies {
+					token := sessionToken(c, cookie)
+					if token == "" {
+						continue
+					}
+					principal, err := authenticate(token)
+					if err != nil {
+						sessionErr = err
+						continue
+					}
+
+					if !validCSRF(c) {
+						err := errors.New("[Middleware." + name + "]: invalid csrf token")
+
+						log.WithError(err).Warn("Session request without valid CSRF token")
+
+						return echo.NewHTTPError(http.StatusForbidden, entity.ResponseError{
+							Error: utils.StandardError(err),
+						})
+					}
+
+					c.Set(key, principal)
+
+					return next(c)
+				}
+				if sessionErr != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
+						Error: utils.StandardError(sessionErr),
+					})
+				}
+
+				err := errors.New("[Middleware." + name + "]: no authorization header found")
 
 				log.WithError(err).Warn("Missing authorization header")
 
@@ -30,7 +84,7 @@ func ShopAuth() echo.MiddlewareFunc {
 
 			str := strings.Split(bearerToken, " ")
 			if len(str) != 2 {
-				err := errors.New("[Middleware.ShopAuth]: invalid authorization header format")
+				err := errors.New("[Middleware." + name + "]: invalid authorization header format")
 
 				log.WithError(err).Warn("Invalid authorization header format")
 
@@ -39,79 +93,111 @@ func ShopAuth() echo.MiddlewareFunc {
 				})
 			}
 
-			token := str[1]
-			claims, err := utils.ValidateJWT(token, []byte(viper.GetString("jwt.shopsecret")))
+			principal, err := authenticate(str[1])
 			if err != nil {
-				err := errors.New("[Middleware.ShopAuth]: JWT validation failed")
-
-				log.WithError(err).Error("JWT validation failed")
-
 				return echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
 					Error: utils.StandardError(err),
 				})
 			}
 
-			// Convert MapClaims to ShopWithOutPassword
-			shopClaims := &entity.ShopJWT{
-				ID:          uint32((*claims)["id"].(float64)),
-				Name:        (*claims)["name"].(string),
-				Description: (*claims)["description"].(string),
-			}
-
-			c.Set("shop", shopClaims)
+			c.Set(key, principal)
 
 			return next(c)
 		}
 	}
 }
 
-func UserAuth() echo.MiddlewareFunc {
+// APIKeyScheme is the Authorization scheme of shop API keys
+const APIKeyScheme = "ApiKey"
+
+// ShopAuth accepts tokens of the shop's own credentials, and account tokens
+// that switched into a shop, acting for that shop with the member's role.
+// "Authorization: ApiKey <key>" is handed to apiKeys instead.
+func ShopAuth(apiKeys domain.APIKeyAuthenticator) echo.MiddlewareFunc {
+	tokens := tokenAuth("ShopAuth", "shop", []string{ShopSessionCookie, UserSessionCookie}, func(token string) (*entity.ShopJWT, error) {
+		if shop, err := parseClaims[entity.ShopJWT](token, entity.ShopTokenAudience); err == nil {
+			return shop, nil
+		}
+
+		user, err := parseClaims[entity.UserJWT](token, entity.UserTokenAudience)
+		if err != nil {
+			log.WithError(err).Warn("JWT validation failed")
+			return nil, errors.New("[Middleware.ShopAuth]: JWT validation failed")
+		}
+		if user.ShopID == 0 {
+			err := errors.New("[Middleware.ShopAuth]: no active shop context")
+			log.WithError(err).Warn("Account token without shop context on shop route")
+			return nil, err
+		}
+
+		return &entity.ShopJWT{
+			ID:     user.ShopID,
+			Name:   user.ShopName,
+			UserID: user.ID,
+			Role:   user.ShopRole,
+		}, nil
+	})
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withToken := tokens(next)
 		return func(c echo.Context) error {
-			bearerToken := c.Request().Header.Get("Authorization")
-			if bearerToken == "" {
-				err := errors.New("[Middleware.UserAuth]: no authorization header found")
+			key, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), APIKeyScheme+" ")
+			if !ok {
+				return withToken(c)
+			}
 
-				log.WithError(err).Warn("Missing authorization header")
+			shop, err := apiKeys.AuthenticateAPIKey(c.Request().Context(), strings.TrimSpace(key), c.RealIP())
+			if err != nil {
+				if utils.StandardError(err) == "invalid api key" {
+					log.WithError(err).Warn("API key validation failed")
 
-				return echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
+					return echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
+						Error: utils.StandardError(err),
+					})
+				}
+
+				log.WithError(err).Error("Internal server error while authenticating api key")
+
+				return echo.NewHTTPError(http.StatusInternalServerError, entity.ResponseError{
 					Error: utils.StandardError(err),
 				})
 			}
 
-			str := strings.Split(bearerToken, " ")
-			if len(str) != 2 {
-				err := errors.New("[Middleware.UserAuth]: invalid authorization header format")
+			c.Set("shop", shop)
 
-				log.WithError(err).Warn("Invalid authorization header format")
+			return next(c)
+		}
+	}
+}
 
-				return echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
-					Error: utils.StandardError(err),
-				})
-			}
+func UserAuth() echo.MiddlewareFunc {
+	return tokenAuth("UserAuth", "user", []string{UserSessionCookie}, func(token string) (*entity.UserJWT, error) {
+		user, err := parseClaims[entity.UserJWT](token, entity.UserTokenAudience)
+		if err != nil {
+			log.WithError(err).Warn("JWT validation failed")
+			return nil, errors.New("[Middleware.UserAuth]: JWT validation failed")
+		}
+		return user, nil
+	})
+}
 
-			token := str[1]
-			claims, err := utils.ValidateJWT(token, []byte(viper.GetString("jwt.usersecret")))
-			if err != nil {
-				err := errors.New("[Middleware.UserAuth]: JWT validation failed")
+// AdminAuth accepts user tokens whose role claim is ADMIN
+func AdminAuth() echo.MiddlewareFunc {
+	userAuth := UserAuth()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return userAuth(func(c echo.Context) error {
+			user, ok := c.Get("user").(*entity.UserJWT)
+			if !ok || user.Role != entity.ADMIN {
+				err := errors.New("[Middleware.AdminAuth]: admin role required")
 
-				log.WithError(err).Error("JWT validation failed")
+				log.WithError(err).Warn("Non admin user on admin route")
 
-				return echo.NewHTTPError(http.StatusUnauthorized, entity.ResponseError{
+				return echo.NewHTTPError(http.StatusForbidden, entity.ResponseError{
 					Error: utils.StandardError(err),
 				})
 			}
 
-			// Convert MapClaims to UserWithOutPassword
-			userClaims := &entity.UserJWT{
-				ID:      uint32((*claims)["id"].(float64)),
-				Email:   (*claims)["email"].(string),
-				Address: (*claims)["address"].(string),
-			}
-
-			c.Set("user", userClaims)
-
 			return next(c)
-		}
+		})
 	}
 }
@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-management/entity"
+	"order-management/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+const testKID = "test-1"
+
+// setupJWTKeys configures a single Ed25519 key and returns its private half,
+// so tests can sign tokens the server has not issued itself
+func setupJWTKeys(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("jwt.issuer", "order-management")
+	viper.Set("jwt.keys", []map[string]interface{}{{
+		"kid":        testKID,
+		"privatekey": string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}})
+	if err := utils.InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func sign(t *testing.T, key ed25519.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// withClaims returns the registered claims of a token valid for audience
+// merged with extra
+func withClaims(audience string, extra jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": "order-management",
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return claims
+}
+
+// tamper swaps the payload of token for claims, keeping the signature
+func tamper(t *testing.T, token string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+// serve runs one request through auth and returns the status, 200 when the
+// handler was reached with a principal stored under key
+func serve(t *testing.T, auth echo.MiddlewareFunc, key string, authorization string) int {
+	t.Helper()
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		if c.Get(key) == nil {
+			t.Errorf("handler reached without %s principal", key)
+		}
+		return c.NoContent(http.StatusOK)
+	}, auth)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestUserAuth(t *testing.T) {
+	key := setupJWTKeys(t)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := jwt.MapClaims{"id": 1, "email": "alice@example.com", "role": entity.CUSTOMER}
+	valid := sign(t, key, testKID, withClaims(entity.UserTokenAudience, user))
+	issued, err := utils.GenerateJWT(user, entity.UserTokenAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"valid", "Bearer " + valid, http.StatusOK},
+		{"issued by the server", "Bearer " + issued, http.StatusOK},
+		{"no header", "", http.StatusUnauthorized},
+		{"scheme without token", "Bearer", http.StatusUnauthorized},
+		{"extra header parts", "Bearer " + valid + " x", http.StatusUnauthorized},
+		{"malformed token", "Bearer not.a.jwt", http.StatusUnauthorized},
+		{"expired", "Bearer " + sign(t, key, testKID, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"id": 1, "email": "alice@example.com", "role": entity.CUSTOMER,
+			"iat": time.Now().Add(-2 * time.Hour).Unix(),
+			"exp": time.Now().Add(-time.Hour).Unix(),
+		})), http.StatusUnauthorized},
+		{"without expiry", "Bearer " + sign(t, key, testKID, func() jwt.MapClaims {
+			claims := withClaims(entity.UserTokenAudience, user)
+			delete(claims, "exp")
+			return claims
+		}()), http.StatusUnauthorized},
+		{"shop audience", "Bearer " + sign(t, key, testKID, withClaims(entity.ShopTokenAudience, user)), http.StatusUnauthorized},
+		{"mfa audience", "Bearer " + sign(t, key, testKID, withClaims(entity.ShopMFATokenAudience, user)), http.StatusUnauthorized},
+		{"wrong issuer", "Bearer " + sign(t, key, testKID, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"id": 1, "email": "alice@example.com", "role": entity.CUSTOMER, "iss": "someone-else",
+		})), http.StatusUnauthorized},
+		{"unknown kid", "Bearer " + sign(t, otherKey, "other", withClaims(entity.UserTokenAudience, user)), http.StatusUnauthorized},
+		{"missing kid", "Bearer " + sign(t, key, "", withClaims(entity.UserTokenAudience, user)), http.StatusUnauthorized},
+		{"known kid, other key", "Bearer " + sign(t, otherKey, testKID, withClaims(entity.UserTokenAudience, user)), http.StatusUnauthorized},
+		{"tampered payload", "Bearer " + tamper(t, valid, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"id": 2, "email": "alice@example.com", "role": entity.ADMIN,
+		})), http.StatusUnauthorized},
+		{"alg none", "Bearer " + func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, withClaims(entity.UserTokenAudience, user))
+			token.Header["kid"] = testKID
+			signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}(), http.StatusUnauthorized},
+		{"missing id claim", "Bearer " + sign(t, key, testKID, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"email": "alice@example.com", "role": entity.CUSTOMER,
+		})), http.StatusUnauthorized},
+		{"mistyped id claim", "Bearer " + sign(t, key, testKID, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"id": "1", "email": "alice@example.com", "role": entity.CUSTOMER,
+		})), http.StatusUnauthorized},
+		{"invalid role claim", "Bearer " + sign(t, key, testKID, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"id": 1, "email": "alice@example.com", "role": "ROOT",
+		})), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, UserAuth(), "user", tt.authorization); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShopAuth(t *testing.T) {
+	key := setupJWTKeys(t)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shop := jwt.MapClaims{"id": 3, "name": "acme", "role": entity.OWNER, "ver": 1}
+	valid := sign(t, key, testKID, withClaims(entity.ShopTokenAudience, shop))
+	member := withClaims(entity.UserTokenAudience, jwt.MapClaims{
+		"id": 1, "email": "alice@example.com", "role": entity.CUSTOMER,
+		"shop": 3, "shopName": "acme", "shopRole": entity.PACKER,
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"valid shop token", "Bearer " + valid, http.StatusOK},
+		{"account token in shop context", "Bearer " + sign(t, key, testKID, member), http.StatusOK},
+		{"account token without shop context", "Bearer " + sign(t, key, testKID, withClaims(entity.UserTokenAudience, jwt.MapClaims{
+			"id": 1, "email": "alice@example.com", "role": entity.CUSTOMER,
+		})), http.StatusUnauthorized},
+		{"no header", "", http.StatusUnauthorized},
+		{"malformed header", "Bearer", http.StatusUnauthorized},
+		{"malformed token", "Bearer abc", http.StatusUnauthorized},
+		{"expired", "Bearer " + sign(t, key, testKID, withClaims(entity.ShopTokenAudience, jwt.MapClaims{
+			"id": 3, "name": "acme", "role": entity.OWNER,
+			"iat": time.Now().Add(-2 * time.Hour).Unix(),
+			"exp": time.Now().Add(-time.Hour).Unix(),
+		})), http.StatusUnauthorized},
+		{"mfa audience", "Bearer " + sign(t, key, testKID, withClaims(entity.ShopMFATokenAudience, shop)), http.StatusUnauthorized},
+		{"foreign audience", "Bearer " + sign(t, key, testKID, withClaims("billing", shop)), http.StatusUnauthorized},
+		{"wrong issuer", "Bearer " + sign(t, key, testKID, withClaims(entity.ShopTokenAudience, jwt.MapClaims{
+			"id": 3, "name": "acme", "role": entity.OWNER, "iss": "someone-else",
+		})), http.StatusUnauthorized},
+		{"unknown kid", "Bearer " + sign(t, otherKey, "other", withClaims(entity.ShopTokenAudience, shop)), http.StatusUnauthorized},
+		{"known kid, other key", "Bearer " + sign(t, otherKey, testKID, withClaims(entity.ShopTokenAudience, shop)), http.StatusUnauthorized},
+		{"tampered payload", "Bearer " + tamper(t, valid, withClaims(entity.ShopTokenAudience, jwt.MapClaims{
+			"id": 4, "name": "acme", "role": entity.OWNER, "ver": 1,
+		})), http.StatusUnauthorized},
+		{"invalid role claim", "Bearer " + sign(t, key, testKID, withClaims(entity.ShopTokenAudience, jwt.MapClaims{
+			"id": 3, "name": "acme", "role": "GOD",
+		})), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, ShopAuth(nil), "shop", tt.authorization); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
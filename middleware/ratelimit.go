@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"order-management/domain"
+	"order-management/entity"
+	"order-management/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimit takes one request per call from the bucket of the caller under
+// the matching policy and answers 429 once it is empty. It is registered
+// with e.Use, which runs after routing, so policies can match the route
+// pattern. Authentication runs later in the route's group, so the caller is
+// found by validating the bearer token here: user and shop tokens are limited
+// per account or shop, anything else per client IP. A failing store lets the
+// request through rather than taking the API down with it.
+func RateLimit(store domain.RateLimitStore, policies []entity.RateLimitPolicy) echo.MiddlewareFunc {
+	routes := map[string]entity.RateLimitPolicy{}
+	prefixed := []entity.RateLimitPolicy{}
+	var fallback *entity.RateLimitPolicy
+	for i, policy := range policies {
+		if policy.Limit <= 0 || policy.Window <= 0 {
+			continue
+		}
+		for _, route := range policy.Routes {
+			if _, ok := routes[route]; !ok {
+				routes[route] = policy
+			}
+		}
+		if policy.Prefix != "" {
+			prefixed = append(prefixed, policy)
+		}
+		if policy.Name == "default" && fallback == nil {
+			fallback = &policies[i]
+		}
+	}
+	// Longest prefix first, so /shops/products wins over /shops
+	sort.SliceStable(prefixed, func(i, j int) bool {
+		return len(prefixed[i].Prefix) > len(prefixed[j].Prefix)
+	})
+
+	match := func(c echo.Context) (entity.RateLimitPolicy, bool) {
+		if policy, ok := routes[c.Request().Method+" "+c.Path()]; ok {
+			return policy, true
+		}
+		for _, policy := range prefixed {
+			if c.Path() == policy.Prefix || strings.HasPrefix(c.Path(), strings.TrimSuffix(policy.Prefix, "/")+"/") {
+				return policy, true
+			}
+		}
+		if fallback != nil {
+			return *fallback, true
+		}
+		return entity.RateLimitPolicy{}, false
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			policy, ok := match(c)
+			if !ok {
+				return next(c)
+			}
+
+			return limit(c, next, store, policy.Name+":"+rateLimitPrincipal(c), policy)
+		}
+	}
+}
+
+// APIKeyRateLimit limits requests authenticated by an API key to the key's
+// own requests per minute, or defaultLimit for keys without one. It runs
+// after ShopAuth and lets all other requests through. RateLimit still
+// applies the route's policy before, per client IP, since it cannot look
+// keys up.
+func APIKeyRateLimit(store domain.RateLimitStore, defaultLimit int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			shop, ok := c.Get("shop").(*entity.ShopJWT)
+			if store == nil || !ok || shop.APIKey == nil {
+				return next(c)
+			}
+
+			policy := entity.RateLimitPolicy{Name: "apikey", Limit: defaultLimit, Window: time.Minute}
+			if shop.APIKey.RateLimit > 0 {
+				policy.Limit = shop.APIKey.RateLimit
+			}
+			if policy.Limit <= 0 {
+				return next(c)
+			}
+
+			return limit(c, next, store, "apikey:"+strconv.FormatUint(uint64(shop.APIKey.ID), 10), policy)
+		}
+	}
+}
+
+// limit takes a request from the bucket key of policy, sets the RateLimit
+// headers and answers 429 once the bucket is empty
+func limit(c echo.Context, next echo.HandlerFunc, store domain.RateLimitStore, key string, policy entity.RateLimitPolicy) error {
+	result, err := store.Take(c.Request().Context(), key, policy)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"policy": policy.Name,
+		}).WithError(err).Error("Rate limit store failed, request let through")
+
+		return next(c)
+	}
+
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(policy.Limit))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	header.Set(HeaderRateLimitReset, utils.RetryAfter(result.Reset))
+	header.Set(HeaderRateLimitPolicy, strconv.Itoa(policy.Limit)+";w="+strconv.Itoa(int(policy.Window.Seconds())))
+
+	if !result.Allowed {
+		err := errors.New("[Middleware.RateLimit]: rate limit exceeded")
+
+		log.WithFields(log.Fields{
+			"policy": policy.Name,
+			"key":    key,
+		}).WithError(err).Warn("Rate limit exceeded")
+
+		header.Set(echo.HeaderRetryAfter, utils.RetryAfter(result.RetryAfter))
+		return c.JSON(http.StatusTooManyRequests, entity.ResponseError{
+			Error: utils.StandardError(err),
+		})
+	}
+
+	return next(c)
+}
+
+// rateLimitPrincipal identifies the caller by a valid bearer token or
+// session cookie, falling back to the client IP. Account tokens count for the
+// user whichever shop they act for.
+func rateLimitPrincipal(c echo.Context) string {
+	tokens := []string{}
+	if token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer "); ok {
+		tokens = append(tokens, token)
+	} else {
+		tokens = append(tokens, sessionToken(c, UserSessionCookie), sessionToken(c, ShopSessionCookie))
+	}
+
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if user, err := parseClaims[entity.UserJWT](token, entity.UserTokenAudience); err == nil {
+			return "user:" + strconv.FormatUint(uint64(user.ID), 10)
+		}
+		if shop, err := parseClaims[entity.ShopJWT](token, entity.ShopTokenAudience); err == nil {
+			return "shop:" + strconv.FormatUint(uint64(shop.ID), 10)
+		}
+	}
+	return "ip:" + c.RealIP()
+}
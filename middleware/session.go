@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"order-management/utils"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+// Cookies of the session mode for browsers, turned on with session.enabled.
+// The token cookies are HttpOnly so scripts never see a token. The CSRF
+// cookie is readable on purpose: the frontend echoes it in the X-CSRF-Token
+// header of every state-changing request, which a forged cross-site request
+// cannot do.
+const (
+	UserSessionCookie = "user_session"
+	ShopSessionCookie = "shop_session"
+	CSRFCookie        = "csrf_token"
+)
+
+func sessionsEnabled() bool {
+	return viper.GetBool("session.enabled")
+}
+
+// newSessionCookie applies session.secure (default true), session.samesite
+// (lax, strict or none, default lax) and session.domain
+func newSessionCookie(name, value string, maxAge int) *http.Cookie {
+	secure := true
+	if viper.IsSet("session.secure") {
+		secure = viper.GetBool("session.secure")
+	}
+
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(viper.GetString("session.samesite")) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		// Browsers drop SameSite=None cookies that are not Secure
+		sameSite = http.SameSiteNoneMode
+		secure = true
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   viper.GetString("session.domain"),
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
+}
+
+// IssueToken hands a freshly issued token to the client, in the
+// Authorization header and, in session mode, in the cookie named cookie
+// along with a new CSRF token
+func IssueToken(c echo.Context, cookie, token string) error {
+	c.Response().Header().Set(echo.HeaderAuthorization, "Bearer "+token)
+	if !sessionsEnabled() {
+		return nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	csrf := base64.RawURLEncoding.EncodeToString(b)
+	maxAge := int(utils.JWTTTL().Seconds())
+
+	c.SetCookie(newSessionCookie(cookie, token, maxAge))
+	csrfCookie := newSessionCookie(CSRFCookie, csrf, maxAge)
+	csrfCookie.HttpOnly = false
+	c.SetCookie(csrfCookie)
+	c.Response().Header().Set(echo.HeaderXCSRFToken, csrf)
+	return nil
+}
+
+// ClearSession deletes the cookie named cookie. The CSRF cookie stays, it
+// is shared with the other session cookie and useless on its own.
+func ClearSession(c echo.Context, cookie string) {
+	c.SetCookie(newSessionCookie(cookie, "", -1))
+}
+
+// sessionToken is the token of the session cookie named cookie, empty when
+// session mode is off or there is none
+func sessionToken(c echo.Context, cookie string) string {
+	if !sessionsEnabled() {
+		return ""
+	}
+	session, err := c.Cookie(cookie)
+	if err != nil {
+		return ""
+	}
+	return session.Value
+}
+
+// validCSRF is the double-submit check for requests authenticated by a
+// session cookie. Safe methods change nothing and pass.
+func validCSRF(c echo.Context) bool {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	header := c.Request().Header.Get(echo.HeaderXCSRFToken)
+	cookie, err := c.Cookie(CSRFCookie)
+	if err != nil || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
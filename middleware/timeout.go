@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// QueryTimeout bounds the request context to the given duration. Usecases
+// and repositories run their queries on that context, so a slow query is
+// cancelled once the budget is spent, as is everything else in flight when
+// the client disconnects. A zero timeout leaves the context untouched.
+func QueryTimeout(timeout time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if timeout <= 0 {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
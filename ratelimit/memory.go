@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"order-management/domain"
+	"order-management/entity"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is full again, after that it equals a fresh one
+	full time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore keeps buckets in process memory. Every instance limits on
+// its own and the state is lost on restart.
+func NewMemoryStore() domain.RateLimitStore {
+	return &memoryStore{
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, policy entity.RateLimitPolicy) (entity.RateLimitResult, error) {
+	now := time.Now()
+	limit := float64(policy.Limit)
+	rate := limit / policy.Window.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, b := range s.buckets {
+			if now.After(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(limit, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := entity.RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((limit - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -1,6 +1,8 @@
 package seeders
 
 import (
+	"time"
+
 	"order-management/entity"
 
 	log "github.com/sirupsen/logrus"
@@ -122,6 +124,9 @@ func (s *Seeder) seedUsers() ([]uint32, error) {
 			return nil, err
 		}
 		user.Password = string(hashedPassword)
+		// Sample users can order right away even with user.requireverification
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
 		if err := s.db.Create(&user).Error; err != nil {
 			log.Error("Failed to create user:", user.Email, err)
 			return nil, err
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"order-management/domain"
+
+	"github.com/pkg/errors"
+)
+
+type localStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore stores blobs under dir on the local disk. The files are
+// expected to be served at baseURL, see app.newEcho.
+func NewLocalStore(dir string, baseURL string) domain.BlobStore {
+	return &localStore{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *localStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return errors.Wrap(err, "[LocalStore.Put]: invalid key")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return errors.Wrap(err, "[LocalStore.Put]: failed to create directory")
+	}
+
+	// Write to a temporary file first so readers never see a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return errors.Wrap(err, "[LocalStore.Put]: failed to create file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "[LocalStore.Put]: failed to write file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "[LocalStore.Put]: failed to write file")
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return errors.Wrap(err, "[LocalStore.Put]: failed to move file into place")
+	}
+	return nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return errors.Wrap(err, "[LocalStore.Delete]: invalid key")
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "[LocalStore.Delete]: failed to remove file")
+	}
+	return nil
+}
+
+func (s *localStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// path maps a key to a file below dir, rejecting keys that would escape it
+func (s *localStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", errors.New("[LocalStore.path]: key must be a clean relative path")
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
@@ -15,6 +15,20 @@ import (
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 )
 
+// RunEnv is the RUN_ENV environment variable, "local" when it is unset
+func RunEnv() string {
+	if runEnv := os.Getenv("RUN_ENV"); runEnv != "" {
+		return runEnv
+	}
+	return "local"
+}
+
+// IsLocal tells whether the process runs on a developer machine, where
+// insecure conveniences such as throwaway keys are allowed
+func IsLocal() bool {
+	return RunEnv() == "local"
+}
+
 func InitViper(runEnv string) {
 	if runEnv == "local" {
 		viper.AddConfigPath("configs")
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"strconv"
+	"time"
+)
+
+// RetryAfter formats d for a Retry-After header, in whole seconds rounded up
+// so clients never retry early
+func RetryAfter(d time.Duration) string {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
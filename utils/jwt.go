@@ -1,33 +1,311 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"sync"
+	"time"
+
+	"order-management/entity"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
-func GenerateJWT(payload map[string]interface{}, secret []byte) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
+// JWTKeyConfig is one entry of jwt.keys. Keys with a private key can sign,
+// keys with only a public key are kept to verify tokens issued before a
+// rotation.
+type JWTKeyConfig struct {
+	KID        string `mapstructure:"kid"`
+	PrivateKey string `mapstructure:"privatekey"`
+	PublicKey  string `mapstructure:"publickey"`
+}
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+type jwtKeySet struct {
+	issuer  string
+	ttl     time.Duration
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	// ordered keeps jwt.keys order for the JWKS document
+	ordered []*jwtKey
+}
+
+var (
+	jwtKeysMu sync.RWMutex
+	jwtKeys   *jwtKeySet
+)
+
+// InitJWTKeys loads the signing and verification keys from jwt.keys, so
+// utils.InitViper must have been called first. Without any key configured a
+// throwaway key is generated when running locally. Elsewhere that is an
+// error, every restart would log everyone out and replicas would reject each
+// other's tokens.
+func InitJWTKeys() error {
+	set := &jwtKeySet{
+		issuer: viper.GetString("jwt.issuer"),
+		ttl:    viper.GetDuration("jwt.ttl"),
+		keys:   map[string]*jwtKey{},
+	}
+	if set.issuer == "" {
+		set.issuer = "order-management"
+	}
+	if set.ttl <= 0 {
+		set.ttl = 24 * time.Hour
+	}
+
+	configs := []JWTKeyConfig{}
+	if err := viper.UnmarshalKey("jwt.keys", &configs); err != nil {
+		return errors.Wrap(err, "[utils.InitJWTKeys]: invalid jwt.keys")
+	}
+
+	for _, cfg := range configs {
+		key, err := parseJWTKey(cfg)
+		if err != nil {
+			return errors.Wrapf(err, "[utils.InitJWTKeys]: invalid key %q", cfg.KID)
+		}
+		if _, ok := set.keys[key.kid]; ok {
+			return errors.Errorf("[utils.InitJWTKeys]: duplicate key id %q", key.kid)
+		}
+		set.keys[key.kid] = key
+		set.ordered = append(set.ordered, key)
+	}
+
+	if len(set.keys) == 0 {
+		if !IsLocal() {
+			return errors.New("[utils.InitJWTKeys]: jwt.keys is required outside of local development")
+		}
+		key, err := ephemeralJWTKey()
+		if err != nil {
+			return errors.Wrap(err, "[utils.InitJWTKeys]: failed to generate key")
+		}
+		log.WithField("kid", key.kid).Warn("No jwt.keys configured, signing with a throwaway key")
+		set.keys[key.kid] = key
+		set.ordered = append(set.ordered, key)
+	}
+
+	signing := viper.GetString("jwt.signingkey")
+	if signing == "" && len(set.ordered) == 1 {
+		signing = set.ordered[0].kid
+	}
+	key, ok := set.keys[signing]
+	if !ok {
+		return errors.Errorf("[utils.InitJWTKeys]: jwt.signingkey %q is not in jwt.keys", signing)
+	}
+	if key.private == nil {
+		return errors.Errorf("[utils.InitJWTKeys]: signing key %q has no private key", signing)
+	}
+	set.signing = key
+
+	jwtKeysMu.Lock()
+	jwtKeys = set
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+func currentJWTKeys() (*jwtKeySet, error) {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	if jwtKeys == nil {
+		return nil, errors.New("[utils.currentJWTKeys]: jwt keys are not loaded, call InitJWTKeys")
+	}
+	return jwtKeys, nil
+}
+
+func parseJWTKey(cfg JWTKeyConfig) (*jwtKey, error) {
+	if cfg.KID == "" {
+		return nil, errors.New("kid is required")
+	}
+
+	key := &jwtKey{kid: cfg.KID}
+	switch {
+	case cfg.PrivateKey != "":
+		block, _ := pem.Decode([]byte(cfg.PrivateKey))
+		if block == nil {
+			return nil, errors.New("private key is not PEM encoded")
+		}
+		var parsed interface{}
+		var err error
+		if block.Type == "RSA PRIVATE KEY" {
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		} else {
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse private key")
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		key.private = signer
+		key.public = signer.Public()
+	case cfg.PublicKey != "":
+		block, _ := pem.Decode([]byte(cfg.PublicKey))
+		if block == nil {
+			return nil, errors.New("public key is not PEM encoded")
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse public key")
+		}
+		key.public = parsed
+	default:
+		return nil, errors.New("privatekey or publickey is required")
+	}
+
+	switch public := key.public.(type) {
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must have at least 2048 bits")
+		}
+		key.method = jwt.SigningMethodRS256
+	default:
+		return nil, errors.New("only Ed25519 and RSA keys are supported")
+	}
+	return key, nil
+}
+
+func ephemeralJWTKey() (*jwtKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	return &jwtKey{
+		kid:     "ephemeral-" + hex.EncodeToString(suffix),
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	}, nil
+}
+
+// GenerateJWT signs payload with the current signing key for audience,
+// adding the key id header and the iss, aud, iat and exp claims
+func GenerateJWT(payload map[string]interface{}, audience string) (string, error) {
+	return GenerateJWTWithTTL(payload, audience, JWTTTL())
+}
+
+// GenerateJWTWithTTL is GenerateJWT for tokens that live shorter than
+// jwt.ttl
+func GenerateJWTWithTTL(payload map[string]interface{}, audience string, ttl time.Duration) (string, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return "", errors.Wrap(err, "[utils.GenerateJWT]: failed to generate jwt")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
 	for k, v := range payload {
 		claims[k] = v
 	}
-	tokenString, err := token.SignedString([]byte(secret))
+	claims["iss"] = keys.issuer
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(keys.signing.method, claims)
+	token.Header["kid"] = keys.signing.kid
+	tokenString, err := token.SignedString(keys.signing.private)
 	if err != nil {
-		return "", errors.Wrap(err, "[utils.GenerateShopJWT]: failed to generate shop jwt")
+		return "", errors.Wrap(err, "[utils.GenerateJWT]: failed to generate jwt")
 	}
 	return tokenString, nil
 }
 
-func ValidateJWT(tokenString string, secret []byte) (*jwt.MapClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return secret, nil
-	})
+// ParseJWT verifies tokenString and decodes its claims into claims, a
+// pointer to a claims struct such as *entity.UserJWT. The token is accepted
+// only when its kid names a configured key, its alg is the one of that key,
+// and it was issued by us for audience and has not expired. Claims
+// implementing jwt.ClaimsValidator get their Validate called as well.
+func ParseJWT(tokenString string, audience string, claims jwt.Claims) error {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return errors.Wrap(err, "[utils.ParseJWT]: failed to parse jwt")
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.keys[kid]
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(keys.issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30*time.Second),
+	)
 	if err != nil {
-		return nil, errors.Wrap(err, "[utils.ValidateShopJWT]: failed to parse shop jwt")
+		return errors.Wrap(err, "[utils.ParseJWT]: failed to parse jwt")
+	}
+	if !token.Valid {
+		return errors.New("[utils.ParseJWT]: invalid token")
 	}
-	claims, ok := token.Claims.(*jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, errors.Wrap(err, "[utils.ValidateShopJWT]: invalid token")
+	return nil
+}
+
+// JWKS lists the public half of every configured key, so other services can
+// verify our tokens through any rotation
+func JWKS() (entity.JWKS, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return entity.JWKS{}, errors.Wrap(err, "[utils.JWKS]: failed to list keys")
+	}
+
+	jwks := entity.JWKS{Keys: make([]entity.JWK, 0, len(keys.ordered))}
+	for _, key := range keys.ordered {
+		jwk := entity.JWK{
+			Kid: key.kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// JWTTTL is how long the tokens issued now stay valid
+func JWTTTL() time.Duration {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return 0
 	}
-	return claims, nil
+	return keys.ttl
 }
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveNames are matched against log field keys and struct field names,
+// lowercased and without dashes or underscores
+var sensitiveNames = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "apikey"}
+
+// ScrubHook redacts credentials from every log entry before it is written.
+// Fields whose key looks sensitive are replaced outright, structs, maps and
+// slices are copied with their sensitive string fields replaced, so logging a
+// whole request or entity never leaks a password or hash.
+type ScrubHook struct{}
+
+func (ScrubHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (ScrubHook) Fire(entry *log.Entry) error {
+	// Data may be shared with the Entry the caller keeps, so never modify it
+	// in place
+	data := make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if value == nil {
+			data[key] = value
+			continue
+		}
+		if isSensitive(key) {
+			data[key] = redacted
+			continue
+		}
+		if _, ok := value.(error); ok {
+			data[key] = value
+			continue
+		}
+		data[key] = scrub(reflect.ValueOf(value), 0).Interface()
+	}
+	entry.Data = data
+	return nil
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+	for _, sensitive := range sensitiveNames {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrub returns v, or a copy of it with sensitive fields redacted. Values
+// whose type cannot hold a sensitive field are returned as they are.
+func scrub(v reflect.Value, depth int) reflect.Value {
+	if depth > 5 || !v.IsValid() {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		return scrub(v.Elem(), depth+1)
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct || !containsSensitive(v.Elem().Type(), 0) {
+			return v
+		}
+		p := reflect.New(v.Elem().Type())
+		p.Elem().Set(scrub(v.Elem(), depth+1))
+		return p
+	case reflect.Struct:
+		if !containsSensitive(v.Type(), 0) {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if isSensitive(field.Name) {
+				if field.Type.Kind() == reflect.String && v.Field(i).Len() > 0 {
+					out.Field(i).SetString(redacted)
+				}
+				continue
+			}
+			scrubbed := scrub(v.Field(i), depth+1)
+			if scrubbed.Type().AssignableTo(field.Type) {
+				out.Field(i).Set(scrubbed)
+			}
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if (v.Kind() == reflect.Slice && v.IsNil()) || !containsSensitive(v.Type().Elem(), 0) {
+			return v
+		}
+		var out reflect.Value
+		if v.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		} else {
+			out = reflect.New(v.Type()).Elem()
+		}
+		for i := 0; i < v.Len(); i++ {
+			if scrubbed := scrub(v.Index(i), depth+1); scrubbed.Type().AssignableTo(v.Type().Elem()) {
+				out.Index(i).Set(scrubbed)
+			} else {
+				out.Index(i).Set(v.Index(i))
+			}
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value := iter.Value()
+			if isSensitive(iter.Key().String()) && (v.Type().Elem().Kind() == reflect.String || v.Type().Elem().Kind() == reflect.Interface) {
+				value = reflect.ValueOf(redacted)
+				if v.Type().Elem().Kind() == reflect.String {
+					value = value.Convert(v.Type().Elem())
+				}
+			} else if scrubbed := scrub(value, depth+1); scrubbed.Type().AssignableTo(v.Type().Elem()) {
+				value = scrubbed
+			}
+			out.SetMapIndex(iter.Key(), value)
+		}
+		return out
+	}
+	return v
+}
+
+// containsSensitive reports whether values of t can hold a sensitive struct
+// field, so values without any are logged untouched
+func containsSensitive(t reflect.Type, depth int) bool {
+	if depth > 5 {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return containsSensitive(t.Elem(), depth+1)
+	case reflect.Interface:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if isSensitive(field.Name) || containsSensitive(field.Type, depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxRounds bounds InRounds, so a backlog cannot hold a job forever
+const maxRounds = 20
+
+// DurationOr is the duration at key, fallback when it is unset or not
+// positive
+func DurationOr(key string, fallback time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// IntOr is the number at key, fallback when it is unset or not positive
+func IntOr(key string, fallback int) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// Backoff doubles base with every failed attempt after the first up to
+// max, with up to 10% jitter so retries spread out
+func Backoff(base time.Duration, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
+}
+
+// InRounds calls round until it handles fewer than batch items, ctx is done
+// or maxRounds passed, and returns how many it handled in all
+func InRounds(ctx context.Context, batch int, round func() (int, error)) (int, error) {
+	total := 0
+	for i := 0; i < maxRounds && ctx.Err() == nil; i++ {
+		handled, err := round()
+		total += handled
+		if err != nil {
+			return total, err
+		}
+		if handled < batch {
+			break
+		}
+	}
+	return total, nil
+}
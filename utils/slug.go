@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Slugify lowercases s and joins its words with dashes. Letters, digits and
+// combining marks of any script are kept, so Thai names keep their vowels
+// and tone marks instead of collapsing to an empty slug.
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// UniqueSlug returns base, or base with the lowest numeric suffix from 2 on
+// that taken does not report.
+func UniqueSlug(base string, taken func(slug string) bool) string {
+	slug := base
+	for n := 2; taken(slug); n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}